@@ -0,0 +1,168 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"net/http"
+)
+
+// Kind groups errors by how a caller should react to them, each kind maps to one HTTP status
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindForbidden
+	KindConflict
+	KindValidation
+	KindUnavailable
+	KindUnauthorized
+	KindTimeout
+	KindRateLimited
+)
+
+var statuses = map[Kind]int{
+	KindInternal:     http.StatusInternalServerError,
+	KindNotFound:     http.StatusNotFound,
+	KindForbidden:    http.StatusForbidden,
+	KindConflict:     http.StatusConflict,
+	KindValidation:   http.StatusUnprocessableEntity,
+	KindUnavailable:  http.StatusServiceUnavailable,
+	KindUnauthorized: http.StatusUnauthorized,
+	KindTimeout:      http.StatusGatewayTimeout,
+	KindRateLimited:  http.StatusTooManyRequests,
+}
+
+// Status is the HTTP status for the kind
+func (k Kind) Status() int {
+	return statuses[k]
+}
+
+// Error is a domain error with a stable code clients can switch on, Message is safe to show them.
+// Fields holds per field problems for validation errors, Extensions extra members for the problem body
+// and Err the underlying cause, which isn't shown.
+type Error struct {
+	Kind       Kind
+	Code       string
+	Message    string
+	Fields     map[string]string
+	Extensions map[string]interface{}
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status is the HTTP status for the error's kind
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func Validation(code, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+func Unavailable(code, message string) *Error {
+	return &Error{Kind: KindUnavailable, Code: code, Message: message}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+func Timeout(code, message string) *Error {
+	return &Error{Kind: KindTimeout, Code: code, Message: message}
+}
+
+func RateLimited(code, message string) *Error {
+	return &Error{Kind: KindRateLimited, Code: code, Message: message}
+}
+
+// WithField adds a problem with one field of the request, for validation errors
+func (e *Error) WithField(field, problem string) *Error {
+	if e.Fields == nil {
+		e.Fields = map[string]string{}
+	}
+	e.Fields[field] = problem
+	return e
+}
+
+// WithExtension adds a member to the problem body, like the counts of a purge that stopped part way
+func (e *Error) WithExtension(key string, value interface{}) *Error {
+	if e.Extensions == nil {
+		e.Extensions = map[string]interface{}{}
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+// Wrap keeps err as the cause
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// Internal wraps an unexpected error. A deadline becomes a timeout and a lost database becomes unavailable,
+// an error that's already typed is returned as it is.
+func Internal(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout("timeout", "the operation timed out").Wrap(err)
+	case mongo.IsNetworkError(err), isServerSelection(err):
+		return Unavailable("database_unavailable", "the database is unavailable").Wrap(err)
+	}
+
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: "error processing data", Err: err}
+}
+
+func isServerSelection(err error) bool {
+	var e topology.ServerSelectionError
+	return errors.As(err, &e)
+}
+
+// From returns err as an *Error, anything untyped is treated as internal
+func From(err error) *Error {
+	var e *Error
+	if errors.As(Internal(err), &e) {
+		return e
+	}
+	return nil
+}
+
+// Is reports whether err is, or wraps, an error of the kind
+func Is(err error, kind Kind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == kind
+}
@@ -1,17 +1,255 @@
 package config
 
 import (
+	"encoding/json"
+	"example.com/app/logger"
 	"fmt"
-	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is the service configuration. It's loaded once, each key is looked up in order of precedence:
+// the environment, the .env file, the YAML or TOML file named by CONFIG_FILE and finally the default.
+type Config struct {
+	Port     string `key:"PORT" default:"8084"`
+	LogLevel string `key:"LOG_LEVEL" default:"info"`
+
+	Secret     string `key:"SECRET" required:"true" secret:"true"`
+	Expiration int    `key:"EXPIRATION" required:"true"` // minutes
+
+	// joined into the connection string, like mongodb:// + localhost + :27017
+	DBName                   string `key:"DB_NAME" required:"true"`
+	DBHost                   string `key:"DB_HOST" required:"true" secret:"true"`
+	DBPort                   string `key:"DB_PORT" required:"true"`
+	DBMaxPoolSize            uint64 `key:"DB_MAX_POOL_SIZE" default:"100"`
+	DBMinPoolSize            uint64 `key:"DB_MIN_POOL_SIZE" default:"0"`
+	DBConnectTimeout         int    `key:"DB_CONNECT_TIMEOUT" default:"20"`          // seconds
+	DBServerSelectionTimeout int    `key:"DB_SERVER_SELECTION_TIMEOUT" default:"10"` // seconds
+	DBHealthCheckInterval    int    `key:"DB_HEALTH_CHECK_INTERVAL" default:"30"`    // seconds
+	MigrateOnStart           bool   `key:"MIGRATE_ON_START" default:"true"`
+
+	KafkaBrokers  []string `key:"KAFKA_BROKERS" default:"localhost:19092,localhost:29092,localhost:39092,localhost:49092,localhost:59092"`
+	ConsumerGroup string   `key:"KAFKA_CONSUMER_GROUP" default:"go-kafka-control-consumer"`
+	ConsumerTopic string   `key:"KAFKA_CONSUMER_TOPIC" default:"user"`
+	// messages are sent with this codec, msgpack, json or protobuf, and read with the one their header names
+	MessageCodec string `key:"KAFKA_MESSAGE_CODEC" default:"msgpack"`
+
+	TracingExporter    string  `key:"TRACING_EXPORTER" default:"none"` // none, stdout or otlp
+	TracingEndpoint    string  `key:"OTLP_ENDPOINT" default:"http://localhost:4318/v1/traces"`
+	TracingServiceName string  `key:"TRACING_SERVICE_NAME" default:"control-service"`
+	TracingSampleRatio float64 `key:"TRACING_SAMPLE_RATIO" default:"1"`
+
+	RequestTimeout         int `key:"REQUEST_TIMEOUT" default:"30"` // seconds
+	ShutdownDrain          int `key:"SHUTDOWN_DRAIN" default:"5"`   // seconds
+	ErasureCoolingOffHours int `key:"ERASURE_COOLING_OFF_HOURS" default:"72"`
+
+	// the unversioned routes answer with Deprecation and Sunset headers pointing clients at /v1
+	LegacyDeprecation time.Time `key:"API_LEGACY_DEPRECATION" default:"2026-10-19"`
+	LegacySunset      time.Time `key:"API_LEGACY_SUNSET" default:"2027-04-19"`
 
-	"github.com/joho/godotenv"
+	// token buckets written as <requests>/<duration>, the store is memory, mongo to share them between
+	// instances or off
+	RateLimitStore       string `key:"RATE_LIMIT_STORE" default:"memory"`
+	RateLimitLogin       string `key:"RATE_LIMIT_LOGIN" default:"5/1m"`
+	RateLimitRead        string `key:"RATE_LIMIT_READ" default:"300/1m"`
+	RateLimitWrite       string `key:"RATE_LIMIT_WRITE" default:"60/1m"`
+	RateLimitDestructive string `key:"RATE_LIMIT_DESTRUCTIVE" default:"20/1m"`
+
+	WebhookTimeout     int `key:"WEBHOOK_TIMEOUT" default:"10"` // seconds
+	WebhookMaxAttempts int `key:"WEBHOOK_MAX_ATTEMPTS" default:"8"`
+}
+
+// ValidationError lists every key that's missing or can't be parsed
+type ValidationError []string
+
+func (v ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(v, "; ")
+}
+
+var (
+	once    sync.Once
+	current *Config
+	values  map[string]string
+	loadErr error
 )
 
-func Config(key string) string {
-	// load .env file
-	err := godotenv.Load(".env")
+// Load reads the configuration the first time it's called, later calls return the same result
+func Load() (*Config, error) {
+	once.Do(func() {
+		values, loadErr = readSources()
+		if loadErr != nil {
+			return
+		}
+		current, loadErr = parse(values)
+	})
+	return current, loadErr
+}
+
+// Get returns the loaded configuration and exits when it's invalid, call Load first to handle the error
+func Get() *Config {
+	c, err := Load()
 	if err != nil {
-		fmt.Print("Error loading .env file")
+		logger.L().Fatal("invalid configuration", "error", err)
+	}
+	return c
+}
+
+// Value returns a raw key for settings that don't have a field, like FLAG_THRESHOLD_<TYPE>
+func Value(key string) string {
+	Get()
+	return values[key]
+}
+
+func parse(values map[string]string) (*Config, error) {
+	c := new(Config)
+	var errs ValidationError
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("key")
+
+		raw, ok := values[key]
+		if !ok || raw == "" {
+			raw = field.Tag.Get("default")
+		}
+
+		if raw == "" {
+			if field.Tag.Get("required") == "true" {
+				errs = append(errs, key+" is required")
+			}
+			continue
+		}
+
+		if err := set(v.Field(i), raw); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	if c.Expiration < 0 {
+		errs = append(errs, "EXPIRATION must not be negative")
+	}
+
+	// a max pool size of 0 is no limit
+	if c.DBMaxPoolSize > 0 && c.DBMinPoolSize > c.DBMaxPoolSize {
+		errs = append(errs, "DB_MIN_POOL_SIZE must not be more than DB_MAX_POOL_SIZE")
 	}
-	return os.Getenv(key)
+
+	if c.DBConnectTimeout < 1 || c.DBServerSelectionTimeout < 1 || c.DBHealthCheckInterval < 1 {
+		errs = append(errs, "DB_CONNECT_TIMEOUT, DB_SERVER_SELECTION_TIMEOUT and DB_HEALTH_CHECK_INTERVAL must be at least 1")
+	}
+
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	if !c.LegacySunset.After(c.LegacyDeprecation) {
+		errs = append(errs, "API_LEGACY_SUNSET must be after API_LEGACY_DEPRECATION")
+	}
+
+	if c.WebhookTimeout < 1 || c.WebhookMaxAttempts < 1 {
+		errs = append(errs, "WEBHOOK_TIMEOUT and WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+
+	switch c.MessageCodec {
+	case "msgpack", "json", "protobuf":
+	default:
+		errs = append(errs, "KAFKA_MESSAGE_CODEC must be msgpack, json or protobuf")
+	}
+
+	switch c.RateLimitStore {
+	case "memory", "mongo", "off":
+	default:
+		errs = append(errs, "RATE_LIMIT_STORE must be memory, mongo or off")
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return c, nil
+}
+
+func set(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, raw); err != nil {
+				return fmt.Errorf("%q is not a date or an RFC 3339 time", raw)
+			}
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		field.SetInt(int64(n))
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a positive number", raw)
+		}
+		field.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Kind())
+	}
+	return nil
+}
+
+// Redacted returns the configuration by key with secrets masked, for dumping it
+func (c Config) Redacted() map[string]interface{} {
+	dump := map[string]interface{}{}
+
+	v := reflect.ValueOf(c)
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i).Interface()
+
+		if field.Tag.Get("secret") == "true" && !v.Field(i).IsZero() {
+			value = "********"
+		}
+
+		dump[field.Tag.Get("key")] = value
+	}
+
+	return dump
+}
+
+// String dumps the configuration as JSON with secrets masked
+func (c Config) String() string {
+	b, _ := json.MarshalIndent(c.Redacted(), "", "  ")
+	return string(b)
 }
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// minimal is the least a deployment has to set
+func minimal() map[string]string {
+	return map[string]string{"SECRET": "s", "EXPIRATION": "5", "DB_NAME": "mongodb://", "DB_HOST": "localhost",
+		"DB_PORT": ":27017"}
+}
+
+func TestParseRequiresDatabase(t *testing.T) {
+	if _, err := parse(minimal()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"DB_NAME", "DB_HOST", "DB_PORT"} {
+		values := minimal()
+		delete(values, key)
+
+		_, err := parse(values)
+		if err == nil || !strings.Contains(err.Error(), key+" is required") {
+			t.Fatalf("parse without %s = %v, want it required", key, err)
+		}
+	}
+}
+
+func TestParseDatabaseLimits(t *testing.T) {
+	cases := map[string]string{
+		"DB_MIN_POOL_SIZE":            "200",
+		"DB_CONNECT_TIMEOUT":          "0",
+		"DB_SERVER_SELECTION_TIMEOUT": "-1",
+		"DB_HEALTH_CHECK_INTERVAL":    "0",
+	}
+
+	for key, value := range cases {
+		values := minimal()
+		values[key] = value
+
+		_, err := parse(values)
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Fatalf("parse with %s=%s = %v, want it turned down", key, value, err)
+		}
+	}
+
+	values := minimal()
+	values["DB_MAX_POOL_SIZE"], values["DB_MIN_POOL_SIZE"] = "0", "10"
+	if _, err := parse(values); err != nil {
+		t.Fatalf("parse with no max pool size = %v, want it allowed", err)
+	}
+}
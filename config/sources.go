@@ -0,0 +1,209 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readSources merges the config file, .env and the environment, later sources win
+func readSources() (map[string]string, error) {
+	dotEnv, err := godotenv.Read(".env")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading .env: %v", err)
+	}
+
+	values := map[string]string{}
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = dotEnv["CONFIG_FILE"]
+	}
+
+	if path != "" {
+		file, err := readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		for k, v := range file {
+			values[k] = v
+		}
+	}
+
+	for k, v := range dotEnv {
+		values[k] = v
+	}
+
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			values[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	return values, nil
+}
+
+// readFile flattens a YAML or TOML file into keys, a nested db.host becomes DB_HOST
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc map[string]interface{}
+		if err = yaml.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		values := map[string]string{}
+		flatten("", doc, values)
+		return values, nil
+	case ".toml":
+		return readTOML(string(b))
+	default:
+		return nil, fmt.Errorf("unsupported config file type %q", filepath.Ext(path))
+	}
+}
+
+func flatten(prefix string, doc map[string]interface{}, values map[string]string) {
+	for k, v := range doc {
+		key := strings.ToUpper(prefix + k)
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flatten(key+"_", v, values)
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// readTOML handles the part of TOML a config file needs: tables, strings, numbers, booleans and arrays of them
+func readTOML(doc string) (map[string]string, error) {
+	values := map[string]string{}
+	prefix := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(doc))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 || !endOfLine(line[end+1:]) {
+				return nil, fmt.Errorf("line %d: expected [table]", n)
+			}
+			prefix = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(line[1:end]), ".", "_")) + "_"
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		key := strings.ToUpper(strings.TrimSpace(line[:i]))
+		if key == "" {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		value, rest, err := tomlValue(strings.TrimSpace(line[i+1:]))
+		if err == nil && !endOfLine(rest) {
+			err = fmt.Errorf("unexpected %q after the value", strings.TrimSpace(rest))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		if _, ok := values[prefix+key]; ok {
+			return nil, fmt.Errorf("line %d: %s is set twice", n, prefix+key)
+		}
+		values[prefix+key] = value
+	}
+
+	return values, scanner.Err()
+}
+
+// endOfLine reports whether only blanks or a comment follow a value
+func endOfLine(rest string) bool {
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// tomlValue reads the value at the start of raw and returns what follows it. Arrays are joined with commas
+// like the list settings in the environment.
+func tomlValue(raw string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		for i := 1; i < len(raw); i++ {
+			switch raw[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(raw[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string %s", raw[:i+1])
+				}
+				return value, raw[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return raw[1 : end+1], raw[end+2:], nil
+	case strings.HasPrefix(raw, "["):
+		var items []string
+		rest := strings.TrimSpace(raw[1:])
+		for !strings.HasPrefix(rest, "]") {
+			if endOfLine(rest) {
+				return "", "", fmt.Errorf("unterminated array")
+			}
+			item, after, err := tomlValue(rest)
+			if err != nil {
+				return "", "", err
+			}
+			items = append(items, item)
+
+			rest = strings.TrimSpace(after)
+			switch {
+			case strings.HasPrefix(rest, ","):
+				rest = strings.TrimSpace(rest[1:])
+			case endOfLine(rest):
+				return "", "", fmt.Errorf("unterminated array")
+			case !strings.HasPrefix(rest, "]"):
+				return "", "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+		return strings.Join(items, ","), rest[1:], nil
+	default:
+		// a bare value runs to a comment, or in an array to the next item
+		end := strings.IndexAny(raw, "#,]")
+		if end < 0 {
+			end = len(raw)
+		}
+		value := strings.TrimSpace(raw[:end])
+		if value == "" {
+			return "", "", fmt.Errorf("missing value")
+		}
+		return value, raw[end:], nil
+	}
+}
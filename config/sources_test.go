@@ -0,0 +1,73 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTOML(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		want map[string]string
+	}{
+		{"bare values", "port = 8084\nmigrate_on_start = true\nratio = 0.5",
+			map[string]string{"PORT": "8084", "MIGRATE_ON_START": "true", "RATIO": "0.5"}},
+		{"tables", "[db]\nhost = \"localhost\"\n[rate.limit]\nlogin = \"5/1m\"",
+			map[string]string{"DB_HOST": "localhost", "RATE_LIMIT_LOGIN": "5/1m"}},
+		{"basic string escapes", `secret = "a \"quoted\" \\ value\t"`,
+			map[string]string{"SECRET": "a \"quoted\" \\ value\t"}},
+		{"literal string", `path = 'C:\config\app'`, map[string]string{"PATH": `C:\config\app`}},
+		{"hash in a string", `host = "mongo#1" # the first replica`, map[string]string{"HOST": "mongo#1"}},
+		{"quote in a comment", `name = "app" # it's "quoted" here`, map[string]string{"NAME": "app"}},
+		{"comments", "# header\n\n  # indented\nport = 1 # trailing\n[db] # the database\nname = 'x' # again",
+			map[string]string{"PORT": "1", "DB_NAME": "x"}},
+		{"arrays", `brokers = ["a:1", 'b:2', "c,3"] # the cluster`, map[string]string{"BROKERS": "a:1,b:2,c,3"}},
+		{"bare array", "ids = [1, 2, 3,]", map[string]string{"IDS": "1,2,3"}},
+		{"empty array", "ids = []", map[string]string{"IDS": ""}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readTOML(tc.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("readTOML = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadTOMLErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"no equals", "port 8084", "line 1: expected key = value"},
+		{"no key", "= 1", "line 1: expected key = value"},
+		{"no value", "port =", "line 1: missing value"},
+		{"unterminated string", "a = 1\nname = \"app", "line 2: unterminated string"},
+		{"unterminated literal", "name = 'app", "line 1: unterminated string"},
+		{"bad escape", `name = "\q"`, "line 1: invalid string"},
+		{"unterminated array", `ids = [1, 2 # ]`, "line 1: unterminated array"},
+		{"missing comma", `ids = ["a" "b"]`, "line 1: expected , or ] in array"},
+		{"text after a string", `name = "app" extra`, `line 1: unexpected "extra" after the value`},
+		{"two values", "port = 1, 2", "line 1: unexpected"},
+		{"unterminated table", "[db", "line 1: expected [table]"},
+		{"array of tables", "[[servers]]", "line 1: expected [table]"},
+		{"set twice", "[db]\nname = 'a'\nname = 'b'", "line 3: DB_NAME is set twice"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readTOML(tc.doc)
+			if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+				t.Fatalf("readTOML error = %v, want %s", err, tc.want)
+			}
+		})
+	}
+}
@@ -3,45 +3,153 @@ package database
 import (
 	"context"
 	"example.com/app/config"
+	"example.com/app/logger"
+	"example.com/app/metrics"
+	"example.com/app/tracing"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"time"
 )
 
+// Connection wraps the one mongo client the service uses for its whole lifetime,
+// the driver pools the underlying connections itself
 type Connection struct {
 	*mongo.Client
-	UserCollection *mongo.Collection
-	StoryCollection *mongo.Collection
-	CommentsCollection *mongo.Collection
-	FlagCollection     *mongo.Collection
-	RepliesCollection *mongo.Collection
-	AdminCollection *mongo.Collection
+	UserCollection      *mongo.Collection
+	StoryCollection     *mongo.Collection
+	CommentsCollection  *mongo.Collection
+	FlagCollection      *mongo.Collection
+	RepliesCollection   *mongo.Collection
+	AdminCollection     *mongo.Collection
+	ReviewCollection    *mongo.Collection
+	ActionCollection    *mongo.Collection
+	AppealCollection    *mongo.Collection
+	CaseCollection      *mongo.Collection
+	JobCollection       *mongo.Collection
+	ErasureCollection   *mongo.Collection
+	RateLimitCollection *mongo.Collection
+	FeedCollection      *mongo.Collection
+	WebhookCollection   *mongo.Collection
+	DeliveryCollection  *mongo.Collection
+	OutboxCollection    *mongo.Collection
+	RebuildCollection   *mongo.Collection
+	ProcessedCollection *mongo.Collection
 	*mongo.Database
 }
 
-func ConnectToDB() (*Connection,error) {
-	p := config.Config("DB_PORT")
-	n := config.Config("DB_NAME")
-	h := config.Config("DB_HOST")
+// ConnectToDB connects and pings the server so a bad configuration fails at startup.
+// Pool size and timeouts come from the DB_ settings in the config.
+func ConnectToDB() (*Connection, error) {
+	cfg := config.Get()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	connectTimeout := time.Duration(cfg.DBConnectTimeout) * time.Second
+
+	opts := options.Client().ApplyURI(cfg.DBName + cfg.DBHost + cfg.DBPort).
+		SetMaxPoolSize(cfg.DBMaxPoolSize).
+		SetMinPoolSize(cfg.DBMinPoolSize).
+		SetConnectTimeout(connectTimeout).
+		SetServerSelectionTimeout(time.Duration(cfg.DBServerSelectionTimeout) * time.Second).
+		SetMonitor(combineMonitors(metrics.MongoMonitor(), tracing.MongoMonitor()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(n + h + p))
-	if err != nil { return nil, err }
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, err
+	}
 
 	// create database
 	db := client.Database("control-services")
 
-	// create collection
-	userCollection := db.Collection("users")
-	storiesCollection := db.Collection("stories")
-	commentsCollection := db.Collection("comments")
-	repliesCollection := db.Collection("replies")
-	flagCollection := db.Collection("flags")
-	adminCollection := db.Collection("admin")
-
-	dbConnection := &Connection{client, userCollection, storiesCollection, commentsCollection, flagCollection, repliesCollection, adminCollection, db}
+	dbConnection := &Connection{
+		Client:              client,
+		UserCollection:      db.Collection("users"),
+		StoryCollection:     db.Collection("stories"),
+		CommentsCollection:  db.Collection("comments"),
+		FlagCollection:      db.Collection("flags"),
+		RepliesCollection:   db.Collection("replies"),
+		AdminCollection:     db.Collection("admin"),
+		ReviewCollection:    db.Collection("review_queue"),
+		ActionCollection:    db.Collection("moderation_actions"),
+		AppealCollection:    db.Collection("appeals"),
+		CaseCollection:      db.Collection("cases"),
+		JobCollection:       db.Collection("bulk_jobs"),
+		ErasureCollection:   db.Collection("erasure_requests"),
+		RateLimitCollection: db.Collection("rate_limits"),
+		FeedCollection:      db.Collection("feed_events"),
+		WebhookCollection:   db.Collection("webhooks"),
+		DeliveryCollection:  db.Collection("webhook_deliveries"),
+		OutboxCollection:    db.Collection("outbox"),
+		RebuildCollection:   db.Collection("rebuild_jobs"),
+		ProcessedCollection: db.Collection("processed_messages"),
+		Database:            db,
+	}
 
 	return dbConnection, nil
-}
\ No newline at end of file
+}
+
+// combineMonitors calls each monitor in turn, the driver only takes one
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				m.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				m.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				m.Failed(ctx, e)
+			}
+		},
+	}
+}
+
+// Ping checks that the primary is reachable
+func (c *Connection) Ping(ctx context.Context) error {
+	return c.Client.Ping(ctx, readpref.Primary())
+}
+
+// MonitorHealth pings the server every DB_HEALTH_CHECK_INTERVAL seconds and logs when it stops
+// or starts answering, it returns when ctx is cancelled
+func (c *Connection) MonitorHealth(ctx context.Context) {
+	interval := time.Duration(config.Get().DBHealthCheckInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	healthy := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := c.Ping(pingCtx)
+			cancel()
+
+			if err != nil && healthy {
+				logger.L().Error("mongo health check failed", "error", err)
+			} else if err == nil && !healthy {
+				logger.L().Info("mongo is reachable again")
+			}
+			healthy = err == nil
+		}
+	}
+}
+
+// Close disconnects the client, waiting for in use connections up to the deadline of ctx
+func (c *Connection) Close(ctx context.Context) error {
+	return c.Client.Disconnect(ctx)
+}
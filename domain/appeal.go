@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// appeal statuses, an upheld appeal keeps the moderation action and an overturned one undoes it
+const (
+	AppealPending    = "pending"
+	AppealUpheld     = "upheld"
+	AppealOverturned = "overturned"
+)
+
+// Appeal is validated by its validate tags whether it comes in over rest or on the user topic
+type Appeal struct {
+	Id                primitive.ObjectID `bson:"_id" json:"id"`
+	ActionId          primitive.ObjectID `bson:"actionId" json:"actionId" validate:"required"`
+	AppellantUsername string             `bson:"appellantUsername" json:"appellantUsername" validate:"required,max=100"`
+	Statement         string             `bson:"statement" json:"statement" validate:"required,max=2000"`
+	Status            string             `bson:"status" json:"status"`
+	OriginalActor     string             `bson:"originalActor" json:"originalActor"`
+	ReviewerUsername  string             `bson:"reviewerUsername" json:"reviewerUsername"`
+	Decision          string             `bson:"decision" json:"decision"`
+	Source            string             `bson:"source" json:"source"`
+	CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
+	DecidedAt         time.Time          `bson:"decidedAt" json:"decidedAt"`
+}
+
+type AppealDecision struct {
+	Decision string `json:"decision" validate:"max=2000"`
+}
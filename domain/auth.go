@@ -3,11 +3,11 @@ package domain
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"example.com/app/apperrors"
 	"example.com/app/config"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -17,10 +17,11 @@ type Authentication struct {
 	Username string `bson:"username" json:"username"`
 }
 
-// LoginDetails todo validate struct
+// LoginDetails Email holds the admin's username, admins are looked up by username and the seeded admin has no
+// email. Password is capped at 72 characters, bcrypt ignores anything longer.
 type LoginDetails struct {
-	Email    string `bson:"email" json:"email"`
-	Password string `bson:"password" json:"password"`
+	Email    string `bson:"email" json:"email" validate:"required,max=100"`
+	Password string `bson:"password" json:"password" validate:"required,max=72"`
 }
 
 type Claims struct {
@@ -29,14 +30,8 @@ type Claims struct {
 	Username string
 }
 
-var k = config.Config("SECRET")
-
 func (l Authentication) GenerateJWT(msg Admin) (string, error) {
-	e, err := strconv.Atoi(config.Config("EXPIRATION"))
-
-	if err != nil {
-		return "", err
-	}
+	e := config.Get().Expiration
 
 	claims := Claims{
 		StandardClaims: jwt.StandardClaims{
@@ -47,7 +42,7 @@ func (l Authentication) GenerateJWT(msg Admin) (string, error) {
 	}
 	// always better to use a pointer with JSON
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
-	signedString, err := token.SignedString([]byte(k))
+	signedString, err := token.SignedString([]byte(config.Get().Secret))
 
 	if err != nil {
 		return "", err
@@ -56,12 +51,16 @@ func (l Authentication) GenerateJWT(msg Admin) (string, error) {
 }
 
 func (l Authentication) SignToken(token []byte) ([]byte, error) {
-	// second arg is a private key, key needs to be the same size as hasher
-	// sha512 is 64 bits
-	h := hmac.New(sha256.New, []byte(k))
+	return Sign([]byte(config.Get().Secret), token)
+}
+
+// Sign is the hex encoded HMAC-SHA256 of message, it signs tokens with the service secret and webhook
+// deliveries with the secret of their webhook
+func Sign(key, message []byte) ([]byte, error) {
+	h := hmac.New(sha256.New, key)
 
 	// hash is a writer
-	_, err := h.Write(token)
+	_, err := h.Write(message)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +77,7 @@ func (l Authentication) VerifySignature(token, sig []byte) (bool, error) {
 
 func (l Authentication) IsLoggedIn(tokenValue string) (*Authentication, bool, error) {
 	if tokenValue == "" {
-		return nil, false, fmt.Errorf("no token")
+		return nil, false, apperrors.Unauthorized("missing_token", "no token")
 	}
 
 	data, err := ExtractData(tokenValue)
@@ -100,7 +99,7 @@ func (l Authentication) IsLoggedIn(tokenValue string) (*Authentication, bool, er
 	token, err := jwt.ParseWithClaims(data[0], &Claims{}, func(t *jwt.Token) (interface{}, error) {
 		if t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
 			//verify token(we pass in our key to be verified)
-			return []byte(k), nil
+			return []byte(config.Get().Secret), nil
 		}
 		return nil, err
 	})
@@ -121,5 +120,5 @@ func (l Authentication) IsLoggedIn(tokenValue string) (*Authentication, bool, er
 		return &l, true, nil
 	}
 
-	return nil, false, fmt.Errorf("token is not valid")
+	return nil, false, apperrors.Unauthorized("invalid_token", "token is not valid")
 }
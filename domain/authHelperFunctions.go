@@ -1,18 +1,18 @@
 package domain
 
 import (
-	"fmt"
+	"example.com/app/apperrors"
 	"strings"
 )
 
 func ExtractData(token string) ([]string, error) {
 	if token == "" {
-		return nil, fmt.Errorf("no token provided")
+		return nil, apperrors.Unauthorized("missing_token", "no token provided")
 	}
 	xs := strings.Split(token, " ")
 
 	if len(xs) != 2 {
-		return nil, fmt.Errorf("invalid token provided")
+		return nil, apperrors.Unauthorized("invalid_token", "invalid token provided")
 	}
 
 	tokenValue := strings.Split(xs[1], "|")
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// bulk actions
+const (
+	BulkDelete     = "delete"
+	BulkHide       = "hide"
+	BulkLockAuthor = "lock_author"
+)
+
+// job statuses of bulk jobs and rebuilds, only a rebuild fails as a whole
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled"
+	JobFailed    = "failed"
+)
+
+// BulkRequest selects content either by Ids or by Filter and applies one action to all of it
+type BulkRequest struct {
+	ResourceType string               `json:"resourceType" validate:"required,oneof=story comment reply"`
+	Ids          []primitive.ObjectID `json:"ids" validate:"max=1000"`
+	Filter       *BulkFilter          `json:"filter"`
+	Action       string               `json:"action" validate:"required,oneof=delete hide lock_author"`
+	DryRun       bool                 `json:"dryRun"`
+}
+
+// BulkFilter fields are combined, Tag only applies to stories and Text is a case insensitive match on the content
+type BulkFilter struct {
+	AuthorUsername string    `json:"authorUsername" validate:"max=100"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Tag            string    `json:"tag" validate:"oneof=creepypasta truescarystory campfire ghoststory paranormal other"`
+	Text           string    `json:"text" validate:"max=200"`
+}
+
+// BulkTarget is one resource a bulk request would act on
+type BulkTarget struct {
+	Id             primitive.ObjectID `bson:"_id" json:"id"`
+	AuthorUsername string             `bson:"authorUsername" json:"authorUsername"`
+}
+
+type BulkItemResult struct {
+	ResourceId primitive.ObjectID `bson:"resourceId" json:"resourceId"`
+	Status     string             `bson:"status" json:"status"`
+	Error      string             `bson:"error" json:"error"`
+}
+
+type BulkJob struct {
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	ResourceType string             `bson:"resourceType" json:"resourceType"`
+	Action       string             `bson:"action" json:"action"`
+	Status       string             `bson:"status" json:"status"`
+	CreatedBy    string             `bson:"createdBy" json:"createdBy"`
+	Total        int                `bson:"total" json:"total"`
+	Processed    int                `bson:"processed" json:"processed"`
+	Progress     float64            `bson:"progress" json:"progress"`
+	Results      []BulkItemResult   `bson:"results" json:"results"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+	FinishedAt   time.Time          `bson:"finishedAt" json:"finishedAt"`
+}
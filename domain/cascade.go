@@ -0,0 +1,11 @@
+package domain
+
+// CascadeResult counts what a cascade delete removed from each collection
+type CascadeResult struct {
+	Stories  int64 `json:"stories"`
+	Comments int64 `json:"comments"`
+	Replies  int64 `json:"replies"`
+	Flags    int64 `json:"flags"`
+	// Transactional is false when the deployment doesn't support transactions and the deletes ran one after another
+	Transactional bool `json:"transactional"`
+}
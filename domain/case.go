@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// case statuses
+const (
+	CaseOpen          = "open"
+	CaseInvestigating = "investigating"
+	CaseResolved      = "resolved"
+)
+
+// caseTransitions lists the statuses a case can move to from each status
+var caseTransitions = map[string][]string{
+	CaseOpen:          {CaseInvestigating, CaseResolved},
+	CaseInvestigating: {CaseOpen, CaseResolved},
+	CaseResolved:      {CaseOpen},
+}
+
+// Case groups the flags, content and users involved in one moderation problem, like a harassment campaign
+type Case struct {
+	Id               primitive.ObjectID   `bson:"_id" json:"id"`
+	Title            string               `bson:"title" json:"title" validate:"required,max=200"`
+	Description      string               `bson:"description" json:"description" validate:"max=5000"`
+	Status           string               `bson:"status" json:"status"`
+	AssigneeUsername string               `bson:"assigneeUsername" json:"assigneeUsername"`
+	CreatedBy        string               `bson:"createdBy" json:"createdBy"`
+	FlagIds          []primitive.ObjectID `bson:"flagIds" json:"flagIds" validate:"max=500"`
+	Resources        []CaseResource       `bson:"resources" json:"resources" validate:"max=500"`
+	Usernames        []string             `bson:"usernames" json:"usernames" validate:"max=500,unique"`
+	Notes            []CaseNote           `bson:"notes" json:"notes"`
+	Evidence         []CaseEvidence       `bson:"evidence" json:"evidence"`
+	CreatedAt        time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt        time.Time            `bson:"updatedAt" json:"updatedAt"`
+}
+
+type CaseResource struct {
+	ResourceType string             `bson:"resourceType" json:"resourceType" validate:"required,oneof=story comment reply user"`
+	ResourceId   primitive.ObjectID `bson:"resourceId" json:"resourceId" validate:"required"`
+}
+
+// CaseNote is an internal note, replies point at the note they answer with ParentId
+type CaseNote struct {
+	Id             primitive.ObjectID `bson:"_id" json:"id"`
+	ParentId       primitive.ObjectID `bson:"parentId" json:"parentId"`
+	AuthorUsername string             `bson:"authorUsername" json:"authorUsername"`
+	Body           string             `bson:"body" json:"body" validate:"required,max=5000"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// CaseEvidence is a copy of a resource taken when it was attached, so it survives edits and deletes
+type CaseEvidence struct {
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	ResourceType string             `bson:"resourceType" json:"resourceType" validate:"required,oneof=story comment reply user"`
+	ResourceId   primitive.ObjectID `bson:"resourceId" json:"resourceId" validate:"required"`
+	Snapshot     bson.M             `bson:"snapshot" json:"snapshot"`
+	CapturedBy   string             `bson:"capturedBy" json:"capturedBy"`
+	CapturedAt   time.Time          `bson:"capturedAt" json:"capturedAt"`
+}
+
+// CaseUpdate holds the fields of a case that can be edited directly
+type CaseUpdate struct {
+	Title       string               `json:"title" validate:"max=200"`
+	Description string               `json:"description" validate:"max=5000"`
+	FlagIds     []primitive.ObjectID `json:"flagIds" validate:"max=500"`
+	Resources   []CaseResource       `json:"resources" validate:"max=500"`
+	Usernames   []string             `json:"usernames" validate:"max=500,unique"`
+}
+
+type CaseAssignment struct {
+	AssigneeUsername string `json:"assigneeUsername" validate:"required,max=100"`
+}
+
+type CaseStatusUpdate struct {
+	Status string `json:"status" validate:"required,oneof=open investigating resolved"`
+}
+
+// CanTransition reports whether the case is allowed to move to the given status
+func (c Case) CanTransition(status string) bool {
+	for _, s := range caseTransitions[c.Status] {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
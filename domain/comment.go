@@ -5,13 +5,14 @@ import (
 	"time"
 )
 
-// Comment todo validate struct
+// Comment is validated by its validate tags
 type Comment struct {
 	Id             primitive.ObjectID `bson:"_id" json:"-"`
 	ResourceId     primitive.ObjectID `bson:"resourceId" json:"-"`
-	Content        string             `bson:"content" json:"content"`
-	AuthorUsername string             `bson:"authorUsername" json:"-"`
+	Content        string             `bson:"content" json:"content" validate:"required,max=2000"`
+	AuthorUsername string             `bson:"authorUsername" json:"-" validate:"required,max=100"`
 	Edited         bool               `bson:"edited" json:"-"`
+	Hidden         bool               `bson:"hidden" json:"-"`
 	Likes          []string           `bson:"likes" json:"-"`
 	Dislikes       []string           `bson:"dislikes" json:"-"`
 	LikeCount      int                `bson:"likeCount" json:"-"`
@@ -31,6 +32,7 @@ type CommentDto struct {
 	LikeCount           int                `bson:"likeCount" json:"likeCount"`
 	DislikeCount        int                `bson:"dislikeCount" json:"dislikeCount"`
 	Edited              bool               `bson:"edited" json:"edited"`
+	Hidden              bool               `bson:"hidden" json:"hidden"`
 	Replies             *[]Reply      `bson:"replies" json:"replies"`
 	CurrentUserLiked    bool               `bson:"currentUserLiked" json:"currentUserLiked"`
 	CurrentUserDisLiked bool               `bson:"currentUserDisLiked" json:"currentUserDisLiked"`
@@ -0,0 +1,20 @@
+package domain
+
+// CursorQuery is what a v2 list endpoint was asked for. Cursor is the next_cursor of the previous page,
+// empty for the first one.
+type CursorQuery struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit" validate:"min=1,max=100"`
+}
+
+// UserPage is one page of a v2 user list, NextCursor is empty on the last page
+type UserPage struct {
+	Items      []UserDto `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// StoryPage is one page of a v2 story list, NextCursor is empty on the last page
+type StoryPage struct {
+	Items      []Story `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
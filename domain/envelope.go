@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// MessageSchemaVersion is the envelope version messages are sent with. Version 1 is the bare Message without an
+// envelope, it's upgraded when it's read.
+const MessageSchemaVersion = 2
+
+// message types name the resource and what happened to it, the envelope carries the matching payload
+const (
+	MessageUserCreated   = "user.created"
+	MessageUserUpdated   = "user.updated"
+	MessageUserDeleted   = "user.deleted"
+	MessageStoryCreated  = "story.created"
+	MessageStoryUpdated  = "story.updated"
+	MessageStoryDeleted  = "story.deleted"
+	MessageFlagCreated   = "flag.created"
+	MessageAppealCreated = "appeal.created"
+	MessageEventCreated  = "event.created"
+)
+
+// Envelope is a kafka message. Id is unique per message and stays the same when a message is sent again,
+// Producer is the service that sent it. Only the payload of the resource Type names is set.
+type Envelope struct {
+	SchemaVersion int
+	Type          string
+	Id            string
+	Timestamp     time.Time
+	Producer      string
+
+	User   *User
+	Story  *Story
+	Flag   *Flag
+	Appeal *Appeal
+	Event  *Event
+}
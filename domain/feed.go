@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+// feed event types, moderation actions are "moderation." and the action, like moderation.auto-hide
+const (
+	FeedUserCreated   = "user.created"
+	FeedUserUpdated   = "user.updated"
+	FeedUserDeleted   = "user.deleted"
+	FeedFlagCreated   = "flag.created"
+	FeedAppealCreated = "appeal.created"
+	FeedModeration    = "moderation."
+)
+
+// FeedEvent is one entry of the live moderation feed. Data holds a few display fields of the resource,
+// never anything private like an email or a password. Tags are the tags of the story the event is about.
+type FeedEvent struct {
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	Type         string             `bson:"type" json:"type"`
+	ResourceType string             `bson:"resourceType" json:"resourceType"`
+	ResourceId   primitive.ObjectID `bson:"resourceId" json:"resourceId"`
+	Actor        string             `bson:"actor,omitempty" json:"actor,omitempty"`
+	Message      string             `bson:"message,omitempty" json:"message,omitempty"`
+	Tags         []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	Data         map[string]string  `bson:"data,omitempty" json:"data,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// FeedFilter is what a feed subscriber wants. An empty list matches everything, a type ending in .* matches
+// every type that starts with it, like moderation.*
+type FeedFilter struct {
+	Types []string `json:"types" validate:"max=20"`
+	Tags  []string `json:"tags" validate:"max=20"`
+}
+
+func (f FeedFilter) Matches(e FeedEvent) bool {
+	return f.matchesType(e.Type) && f.matchesTags(e.Tags)
+}
+
+func (f FeedFilter) matchesType(t string) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, want := range f.Types {
+		if want == t || (strings.HasSuffix(want, ".*") && strings.HasPrefix(t, strings.TrimSuffix(want, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f FeedFilter) matchesTags(tags []string) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, want := range f.Tags {
+		for _, tag := range tags {
+			if strings.EqualFold(want, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// userFeedTypes are the feed types of the user messages by message type
+var userFeedTypes = map[string]string{
+	MessageUserCreated: FeedUserCreated,
+	MessageUserUpdated: FeedUserUpdated,
+	MessageUserDeleted: FeedUserDeleted,
+}
+
+// FeedEventOf describes a message the consumer processed, ok is false for messages the feed doesn't show
+func FeedEventOf(m Envelope) (FeedEvent, bool) {
+	e := FeedEvent{CreatedAt: time.Now()}
+
+	switch {
+	case m.User != nil && userFeedTypes[m.Type] != "":
+		e.Type, e.ResourceType, e.ResourceId, e.Actor = userFeedTypes[m.Type], "user", m.User.Id, m.User.Username
+		e.Data = map[string]string{"username": m.User.Username}
+	case m.Flag != nil && m.Type == MessageFlagCreated:
+		e.Type, e.ResourceType, e.ResourceId = FeedFlagCreated, "flag", m.Flag.FlaggedResource
+		e.Data = map[string]string{"flaggedType": m.Flag.ResourceType, "reason": m.Flag.Reason}
+	case m.Appeal != nil && m.Type == MessageAppealCreated:
+		e.Type, e.ResourceType, e.ResourceId, e.Actor = FeedAppealCreated, "appeal", m.Appeal.ActionId, m.Appeal.AppellantUsername
+	default:
+		return e, false
+	}
+
+	return e, true
+}
@@ -1,11 +1,16 @@
 package domain
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
 
-// Flag todo validate struct
+// Flag is a report against a story, comment, reply or user, it's validated when it arrives on the user topic
 type Flag struct {
 	Id              primitive.ObjectID `bson:"_id" json:"-"`
-	FlaggerID       primitive.ObjectID `bson:"flaggerID" json:"-"`
-	FlaggedResource primitive.ObjectID `bson:"flaggedResource" json:"-"`
-	Reason          string             `bson:"reason" json:"reason"`
+	FlaggerID       primitive.ObjectID `bson:"flaggerID" json:"-" validate:"required"`
+	FlaggedResource primitive.ObjectID `bson:"flaggedResource" json:"-" validate:"required"`
+	ResourceType    string             `bson:"resourceType" json:"resourceType" validate:"required,oneof=story comment reply user"`
+	Reason          string             `bson:"reason" json:"reason" validate:"required,max=500"`
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
 }
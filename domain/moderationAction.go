@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// moderation action types
+const (
+	ActionDelete    = "delete"
+	ActionLock      = "lock"
+	ActionExport    = "export"
+	ActionErase     = "erase"
+	ActionAnonymise = "anonymise"
+)
+
+// ModerationAction records what a moderator did so it can be appealed and undone.
+// Snapshot holds the document as it was before the action, Cascade what a delete removed along with it.
+type ModerationAction struct {
+	Id             primitive.ObjectID `bson:"_id" json:"id"`
+	Action         string             `bson:"action" json:"action"`
+	ResourceType   string             `bson:"resourceType" json:"resourceType"`
+	ResourceId     primitive.ObjectID `bson:"resourceId" json:"resourceId"`
+	ActorUsername  string             `bson:"actorUsername" json:"actorUsername"`
+	TargetUsername string             `bson:"targetUsername" json:"targetUsername"`
+	Snapshot       bson.Raw           `bson:"snapshot" json:"-"`
+	Cascade        []CascadedDocument `bson:"cascade,omitempty" json:"-"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// CascadedDocument is a comment, reply or flag a delete removed along with the resource
+type CascadedDocument struct {
+	Collection string   `bson:"collection"`
+	Document   bson.Raw `bson:"document"`
+}
+
+// Undoable reports whether overturning an appeal can put things back, only deletes and locks keep what they changed
+func (a ModerationAction) Undoable() bool {
+	return a.Action == ActionDelete || a.Action == ActionLock
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// outbox entry statuses
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+)
+
+// OutboxEntry is a kafka message waiting to be sent. It's written together with the change it announces and the
+// relay sends it from there, so a message is never lost when kafka is down or the process dies. Headers keep the
+// request id and trace of the request that wrote it.
+type OutboxEntry struct {
+	Id            primitive.ObjectID `bson:"_id" json:"id"`
+	Topic         string             `bson:"topic" json:"topic"`
+	Payload       []byte             `bson:"payload" json:"payload"`
+	Headers       map[string]string  `bson:"headers,omitempty" json:"headers,omitempty"`
+	Status        string             `bson:"status" json:"status"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	LastError     string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	SentAt        *time.Time         `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+}
@@ -0,0 +1,6 @@
+package domain
+
+// PageQuery is the page a list endpoint was asked for, pages start at 1
+type PageQuery struct {
+	Page int `query:"page" validate:"min=1,max=10000"`
+}
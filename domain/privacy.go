@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// erasure request statuses
+const (
+	ErasureScheduled  = "scheduled"
+	ErasureProcessing = "processing"
+	ErasureCancelled  = "cancelled"
+	ErasureCompleted  = "completed"
+	ErasureFailed     = "failed"
+)
+
+// UserExport is everything stored about a user, collected for a data access request
+type UserExport struct {
+	User         bson.M   `json:"user"`
+	Stories      []bson.M `json:"stories"`
+	Comments     []bson.M `json:"comments"`
+	Replies      []bson.M `json:"replies"`
+	FlagsFiled   []bson.M `json:"flagsFiled"`
+	FlagsAgainst []bson.M `json:"flagsAgainst"`
+	Audit        []bson.M `json:"audit"`
+}
+
+// ErasureRequest is a right to erasure request, it's carried out once ScheduledFor passes
+// unless it's cancelled during the cooling off period
+type ErasureRequest struct {
+	Id           primitive.ObjectID  `bson:"_id" json:"id"`
+	UserId       primitive.ObjectID  `bson:"userId" json:"userId"`
+	RequestedBy  string              `bson:"requestedBy" json:"requestedBy"`
+	Status       string              `bson:"status" json:"status"`
+	Error        string              `bson:"error" json:"error"`
+	ScheduledFor time.Time           `bson:"scheduledFor" json:"scheduledFor"`
+	CreatedAt    time.Time           `bson:"createdAt" json:"createdAt"`
+	CompletedAt  time.Time           `bson:"completedAt" json:"completedAt"`
+	Certificate  *ErasureCertificate `bson:"certificate" json:"certificate"`
+}
+
+// ErasureCertificate records that an erasure was completed. The subject is identified by id and an
+// HMAC of the old username keyed with the server secret, since the username itself is erased. Digest is a
+// sha256 of the other fields.
+type ErasureCertificate struct {
+	RequestId    primitive.ObjectID `bson:"requestId" json:"requestId"`
+	UserId       primitive.ObjectID `bson:"userId" json:"userId"`
+	UsernameHash string             `bson:"usernameHash" json:"usernameHash"`
+	ErasedFields []string           `bson:"erasedFields" json:"erasedFields"`
+	Anonymised   PurgeResult        `bson:"anonymised" json:"anonymised"`
+	CompletedAt  time.Time          `bson:"completedAt" json:"completedAt"`
+	Digest       string             `bson:"digest" json:"digest"`
+}
@@ -0,0 +1,20 @@
+package domain
+
+// purge modes
+const (
+	PurgeDelete    = "delete"
+	PurgeAnonymise = "anonymise"
+)
+
+// AnonymousUsername replaces the author of anonymised content
+const AnonymousUsername = "[deleted]"
+
+// PurgeResult counts what a user purge removed or anonymised in each collection
+type PurgeResult struct {
+	Mode     string `json:"mode"`
+	Users    int64  `json:"users"`
+	Stories  int64  `json:"stories"`
+	Comments int64  `json:"comments"`
+	Replies  int64  `json:"replies"`
+	Flags    int64  `json:"flags"`
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// rebuild phases, a running rebuild replays into the shadow collection, catches up with what arrived since it
+// started and then swaps the shadow in
+const (
+	RebuildReplaying  = "replaying"
+	RebuildCatchingUp = "catching_up"
+	RebuildSwapping   = "swapping"
+)
+
+// RebuildRequest says where on the topics a rebuild starts, at an offset of every partition, at the first
+// message from a time, or at the oldest message when neither is set. Topics defaults to the consumer topic.
+type RebuildRequest struct {
+	Topics     []string   `json:"topics" validate:"max=10,unique"`
+	FromOffset *int64     `json:"fromOffset"`
+	FromTime   *time.Time `json:"fromTime"`
+}
+
+// RebuildPartition is the part of one partition a rebuild reads, from Start up to End. Offset is the next
+// message to apply.
+type RebuildPartition struct {
+	Topic     string `bson:"topic" json:"topic"`
+	Partition int32  `bson:"partition" json:"partition"`
+	Start     int64  `bson:"start" json:"start"`
+	End       int64  `bson:"end" json:"end"`
+	Offset    int64  `bson:"offset" json:"offset"`
+}
+
+// RebuildJob rebuilds the users collection from kafka into Shadow and swaps it in. Applied counts the messages
+// written to the shadow, Skipped the ones about something other than users or that failed validation and Failed
+// the ones that couldn't be applied, with the latest of their errors in Errors. CancelRequested stops it on
+// whichever instance runs it, and DeletedUsers are the users deleted while it runs, kept out of the shadow.
+type RebuildJob struct {
+	Id              primitive.ObjectID   `bson:"_id" json:"id"`
+	Status          string               `bson:"status" json:"status"`
+	Phase           string               `bson:"phase" json:"phase"`
+	Topics          []string             `bson:"topics" json:"topics"`
+	FromOffset      *int64               `bson:"fromOffset,omitempty" json:"fromOffset,omitempty"`
+	FromTime        *time.Time           `bson:"fromTime,omitempty" json:"fromTime,omitempty"`
+	Shadow          string               `bson:"shadow" json:"shadow"`
+	Partitions      []RebuildPartition   `bson:"partitions" json:"partitions"`
+	Applied         int64                `bson:"applied" json:"applied"`
+	Skipped         int64                `bson:"skipped" json:"skipped"`
+	Failed          int64                `bson:"failed" json:"failed"`
+	Errors          []string             `bson:"errors" json:"errors"`
+	Progress        float64              `bson:"progress" json:"progress"`
+	Error           string               `bson:"error,omitempty" json:"error,omitempty"`
+	CancelRequested bool                 `bson:"cancelRequested" json:"cancelRequested"`
+	DeletedUsers    []primitive.ObjectID `bson:"deletedUsers,omitempty" json:"-"`
+	CreatedBy       string               `bson:"createdBy" json:"createdBy"`
+	CreatedAt       time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time            `bson:"updatedAt" json:"updatedAt"`
+	FinishedAt      time.Time            `bson:"finishedAt" json:"finishedAt"`
+}
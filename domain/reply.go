@@ -15,6 +15,7 @@ type Reply struct {
 	LikeCount           int                `bson:"likeCount" json:"likeCount"`
 	DislikeCount        int                `bson:"dislikeCount" json:"dislikeCount"`
 	Edited              bool               `bson:"edited" json:"edited"`
+	Hidden              bool               `bson:"hidden" json:"hidden"`
 	CurrentUserLiked    bool               `bson:"currentUserLiked" json:"currentUserLiked"`
 	CurrentUserDisLiked bool               `bson:"currentUserDisLiked" json:"currentUserDisLiked"`
 	CreatedAt           time.Time          `bson:"createdAt" json:"createdAt"`
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// review statuses
+const (
+	ReviewPending   = "pending"
+	ReviewConfirmed = "confirmed"
+	ReviewReversed  = "reversed"
+)
+
+// ReviewItem is a flagged resource waiting in the moderation review queue.
+// AutoHidden items crossed their flag threshold and were hidden without a moderator.
+type ReviewItem struct {
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	ResourceId   primitive.ObjectID `bson:"resourceId" json:"resourceId"`
+	ResourceType string             `bson:"resourceType" json:"resourceType"`
+	FlagCount    int                `bson:"flagCount" json:"flagCount"`
+	Reasons      []string           `bson:"reasons" json:"reasons"`
+	AutoHidden   bool               `bson:"autoHidden" json:"autoHidden"`
+	Status       string             `bson:"status" json:"status"`
+	ReviewedBy   string             `bson:"reviewedBy" json:"reviewedBy"`
+	ReviewedAt   time.Time          `bson:"reviewedAt" json:"reviewedAt"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
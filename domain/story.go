@@ -5,19 +5,20 @@ import (
 	"time"
 )
 
-// Story todo validate struct
+// Story is validated by its validate tags, tags are one of the known ones and not repeated
 type Story struct {
 	Id             primitive.ObjectID `bson:"_id" json:"id"`
-	Title          string             `bson:"title" json:"title"`
-	Content        string             `bson:"content" json:"content"`
-	AuthorUsername string             `bson:"authorUsername" json:"authorUsername"`
+	Title          string             `bson:"title" json:"title" validate:"required,max=150"`
+	Content        string             `bson:"content" json:"content" validate:"required,max=50000"`
+	AuthorUsername string             `bson:"authorUsername" json:"authorUsername" validate:"required,max=100"`
 	Likes          []string           `bson:"likes" json:"-"`
 	Dislikes       []string           `bson:"dislikes" json:"-"`
 	LikeCount      int                `bson:"likeCount" json:"likeCount"`
 	DislikeCount   int                `bson:"dislikeCount" json:"dislikeCount"`
 	Score          int                `bson:"score" json:"-"`
-	Tags           []Tag              `bson:"tags" json:"tags"`
+	Tags           []Tag              `bson:"tags" json:"tags" validate:"max=5,unique"`
 	Updated        bool               `bson:"updated" json:"updated"`
+	Hidden         bool               `bson:"hidden" json:"hidden"`
 	CreatedDate    string             `bson:"createdDate" json:"createdDate"`
 	UpdatedDate    string             `bson:"updatedDate" json:"updatedDate"`
 }
@@ -37,6 +38,7 @@ type StoryDto struct {
 	CurrentUserLiked    bool               `json:"currentUserLiked"`
 	CurrentUserDisLiked bool               `json:"currentUserDisLiked"`
 	Updated             bool               `json:"updated"`
+	Hidden              bool               `json:"hidden"`
 	CreatedAt           time.Time          `json:"createdAt"`
 	UpdatedAt           time.Time          `json:"updatedAt"`
 	CreatedDate         string             `json:"createdDate"`
@@ -1,12 +1,12 @@
 package domain
 
 import (
-	"fmt"
+	"example.com/app/apperrors"
 	"strings"
 )
 
 type Tag struct {
-	Value          string `bson:"value" json:"value"`
+	Value          string `bson:"value" json:"value" validate:"required,oneof=creepypasta truescarystory campfire ghoststory paranormal other"`
 	CreepyPasta    bool   `bson:"-" json:"-"`
 	TrueScaryStory bool   `bson:"-" json:"-"`
 	CampFire       bool   `bson:"-" json:"-"`
@@ -22,38 +22,38 @@ func (t Tag) ValidateTag(tagValidator *Tag) error {
 			tagValidator.CreepyPasta = true
 			return nil
 		}
-		return fmt.Errorf("no duplicate tags")
+		return apperrors.Validation("duplicate_tag", "no duplicate tags")
 	case "truescarystory":
 		if !tagValidator.TrueScaryStory {
 			tagValidator.TrueScaryStory = true
 			return nil
 		}
-		return fmt.Errorf("no duplicate tags")
+		return apperrors.Validation("duplicate_tag", "no duplicate tags")
 	case "campfire":
 		if !tagValidator.CampFire {
 			tagValidator.CampFire = true
 			return nil
 		}
-		return fmt.Errorf("no duplicate tags")
+		return apperrors.Validation("duplicate_tag", "no duplicate tags")
 	case "ghoststory":
 		if !tagValidator.GhostStory {
 			tagValidator.GhostStory = true
 			return nil
 		}
-		return fmt.Errorf("no duplicate tags")
+		return apperrors.Validation("duplicate_tag", "no duplicate tags")
 	case "paranormal":
 		if !tagValidator.Paranormal {
 			tagValidator.Paranormal = true
 			return nil
 		}
-		return fmt.Errorf("no duplicate tags")
+		return apperrors.Validation("duplicate_tag", "no duplicate tags")
 	case "other":
 		if !tagValidator.Other {
 			tagValidator.Other = true
 			return nil
 		}
-		return fmt.Errorf("no duplicate tags")
+		return apperrors.Validation("duplicate_tag", "no duplicate tags")
 	default:
-		return fmt.Errorf("invalid tag")
+		return apperrors.Validation("invalid_tag", "invalid tag")
 	}
 }
@@ -7,10 +7,10 @@ import (
 
 type User struct {
 	Id                          primitive.ObjectID   `bson:"_id" json:"id"`
-	Username                    string               `bson:"username" json:"username"`
-	Email                       string               `bson:"email" json:"email"`
+	Username                    string               `bson:"username" json:"username" validate:"required,max=100"`
+	Email                       string               `bson:"email" json:"email" validate:"required,email,max=254"`
 	Password                    string               `bson:"password" json:"-"`
-	CurrentTagLine              string               `bson:"currentTagLine" json:"CurrentTagLine"`
+	CurrentTagLine              string               `bson:"currentTagLine" json:"CurrentTagLine" validate:"max=200"`
 	ProfilePictureUrl           string               `bson:"profilePictureUrl" json:"profilePictureUrl"`
 	ProfileBackgroundPictureUrl string               `bson:"profileBackgroundPictureUrl" json:"profileBackgroundPictureUrl"`
 	CurrentBadgeUrl             string               `bson:"currentBadgeUrl" json:"currentBadgeUrl"`
@@ -24,6 +24,7 @@ type User struct {
 	DisplayFollowerCount        bool                 `bson:"displayFollowerCount" json:"displayFollowerCount"`
 	ProfileIsViewable           bool                 `bson:"profileIsViewable" json:"profileIsViewable"`
 	IsLocked                    bool                 `bson:"isLocked" json:"-"`
+	Hidden                      bool                 `bson:"hidden" json:"-"`
 	IsVerified                  bool                 `bson:"isVerified" json:"isVerified"`
 	AcceptMessages              bool                 `bson:"acceptMessages" json:"acceptMessages"`
 	LastLoginIp					string				 `bson:"lastLoginIp" json:"-"`
@@ -1,11 +1,16 @@
 package domain
 
-// Message messageType 201 user created
+// Message is version 1 of the kafka messages, sent before the Envelope. It's only read to upgrade it.
+// messageType 201 user created
 // messageType 200 user updated
+// resourceType "flag" with messageType 201 is a newly filed flag
+// resourceType "appeal" with messageType 201 is a newly submitted appeal
 type Message struct {
 	User         User   `form:"User" json:"User"`
 	Story        Story  `form:"Story" json:"Story"`
 	Event        Event  `form:"Event" json:"Event"`
+	Flag         Flag   `form:"Flag" json:"Flag"`
+	Appeal       Appeal `form:"Appeal" json:"Appeal"`
 	MessageType  int    `form:"messageType" json:"messageType"`
 	ResourceType string `form:"resourceType" json:"resourceType"`
 }
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint that's sent the moderation feed events its filter matches. Secret signs every delivery,
+// it's generated when it's left out and only shown in the response that created the webhook.
+type Webhook struct {
+	Id          primitive.ObjectID `bson:"_id" json:"id"`
+	URL         string             `bson:"url" json:"url" validate:"required,url,max=2000"`
+	Description string             `bson:"description" json:"description" validate:"max=500"`
+	Filter      FeedFilter         `bson:"filter" json:"filter"`
+	Secret      string             `bson:"secret" json:"secret,omitempty" validate:"min=16,max=200"`
+	Active      bool               `bson:"active" json:"active"`
+	CreatedBy   string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// WebhookDelivery is one event on its way to one webhook, Attempts logs every try
+type WebhookDelivery struct {
+	Id            primitive.ObjectID `bson:"_id" json:"id"`
+	WebhookId     primitive.ObjectID `bson:"webhookId" json:"webhookId"`
+	Event         FeedEvent          `bson:"event" json:"event"`
+	Status        string             `bson:"status" json:"status"`
+	Attempts      []DeliveryAttempt  `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// DeliveryAttempt is one try at sending a delivery. StatusCode is 0 when there was no response, Error says why.
+// Response holds the start of the response body.
+type DeliveryAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"statusCode" json:"statusCode"`
+	DurationMs int64     `bson:"durationMs" json:"durationMs"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	Response   string    `bson:"response,omitempty" json:"response,omitempty"`
+}
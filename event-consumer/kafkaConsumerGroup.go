@@ -2,42 +2,96 @@ package event_consumer
 
 import (
 	"context"
+	"example.com/app/apperrors"
+	appConfig "example.com/app/config"
+	"example.com/app/database"
 	"example.com/app/domain"
+	"example.com/app/events"
+	"example.com/app/logger"
+	"example.com/app/metrics"
 	"example.com/app/repo"
+	"example.com/app/tracing"
+	"example.com/app/validation"
+	"fmt"
 	"github.com/Shopify/sarama"
-	"github.com/vmihailenco/msgpack/v5"
-	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
+// member is 1 while the consumer holds a session in the group
+var member int32
+
+// Ping reports whether the consumer is currently a member of its group
+func Ping(ctx context.Context) error {
+	if atomic.LoadInt32(&member) == 0 {
+		return fmt.Errorf("consumer is not a member of group %s", appConfig.Get().ConsumerGroup)
+	}
+	return ctx.Err()
+}
+
 // Consumer represents a Sarama consumer group consumer
 type Consumer struct {
-	ready chan bool
+	ready   chan bool
+	handler messageHandler
+}
+
+// messageHandler is what the consumer does with a message, the repo functions outside of tests
+type messageHandler interface {
+	Processed(ctx context.Context, id string) (bool, error)
+	Process(ctx context.Context, message domain.Envelope) error
+	MarkProcessed(ctx context.Context, message domain.Envelope) error
+	Publish(ctx context.Context, message domain.Envelope)
 }
 
-func KafkaConsumerGroup() {
+type repoHandler struct {
+	conn *database.Connection
+}
+
+func (h repoHandler) Processed(ctx context.Context, id string) (bool, error) {
+	return repo.MessageProcessed(ctx, h.conn, id)
+}
+
+func (h repoHandler) Process(ctx context.Context, message domain.Envelope) error {
+	return repo.ProcessMessage(ctx, h.conn, message)
+}
+
+func (h repoHandler) MarkProcessed(ctx context.Context, message domain.Envelope) error {
+	return repo.MarkProcessed(ctx, h.conn, message)
+}
+
+func (h repoHandler) Publish(ctx context.Context, message domain.Envelope) {
+	repo.PublishMessage(ctx, h.conn, message)
+}
+
+func KafkaConsumerGroup(conn *database.Connection) {
 	config := sarama.NewConfig()
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-	group := "go-kafka-control-consumer"
-	brokers := []string{"localhost:19092", "localhost:29092", "localhost:39092", "localhost:49092", "localhost:59092"}
+	group := appConfig.Get().ConsumerGroup
+	brokers := appConfig.Get().KafkaBrokers
 
 	consumer := Consumer{
-		ready: make(chan bool),
+		ready:   make(chan bool),
+		handler: repoHandler{conn: conn},
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// keep trying while kafka is unreachable, readiness reports the consumer as down meanwhile
 	client, err := sarama.NewConsumerGroup(brokers, group, config)
 
-	if err != nil {
-		log.Panicf("Error creating consumer group client: %v", err)
+	for err != nil {
+		logger.L().Warn("error creating consumer group client, retrying", "error", err)
+		time.Sleep(5 * time.Second)
+		client, err = sarama.NewConsumerGroup(brokers, group, config)
 	}
 
-	topics := []string{"user"}
+	topics := []string{appConfig.Get().ConsumerTopic}
 
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
@@ -45,7 +99,7 @@ func KafkaConsumerGroup() {
 		defer wg.Done()
 		for {
 			if err := client.Consume(ctx, topics, &consumer); err != nil {
-				log.Panicf("Error from consumer: %v", err)
+				logger.L().Fatal("error from consumer", "error", err)
 			}
 			// check if context was cancelled, signaling that the consumer should stop
 			if ctx.Err() != nil {
@@ -56,54 +110,151 @@ func KafkaConsumerGroup() {
 	}()
 
 	<-consumer.ready // Await till the consumer has been set up
-	log.Println("Sarama consumer up and running!...")
+	logger.L().Info("consumer up and running", "group", group, "topics", topics)
 
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
 	select {
 	case <-ctx.Done():
-		log.Println("terminating: context cancelled")
+		logger.L().Info("consumer terminating: context cancelled")
 	case <-sigterm:
-		log.Println("terminating: via signal")
+		logger.L().Info("consumer terminating: via signal")
 	}
 	cancel()
 	wg.Wait()
 	if err = client.Close(); err != nil {
-		log.Panicf("Error closing client: %v", err)
+		logger.L().Error("error closing consumer client", "error", err)
 	}
 }
 
 // Setup is run at the beginning of a new session, before ConsumeClaim
 func (consumer *Consumer) Setup(sarama.ConsumerGroupSession) error {
 	// Mark the consumer as ready
+	atomic.StoreInt32(&member, 1)
 	close(consumer.ready)
 	return nil
 }
 
 // Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
 func (consumer *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	atomic.StoreInt32(&member, 0)
 	return nil
 }
 
 // ConsumeClaim must start a consumer loop of ConsumerGroupClaim's Messages().
 func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for message := range claim.Messages() {
-		user := new(domain.Message)
-		err := msgpack.Unmarshal(message.Value, user)
-		log.Printf("Message claimed: value = %v, timestamp = %v, topic = %s", user, message.Timestamp, message.Topic)
+		start := time.Now()
+		metrics.KafkaConsumerLag.Set(float64(claim.HighWaterMarkOffset()-message.Offset-1),
+			message.Topic, strconv.Itoa(int(message.Partition)))
+
+		// carry the request id and trace of whoever produced the message into processing it
+		ctx := logger.WithRequestID(session.Context(), header(message, logger.RequestIDHeader, logger.NewRequestID()))
+		log := logger.FromContext(ctx)
+
+		ctx = tracing.Extract(ctx, func(key string) string { return header(message, key, "") })
+		ctx, span := tracing.Start(ctx, "kafka.consume "+message.Topic, tracing.KindConsumer,
+			"messaging.system", "kafka", "messaging.source", message.Topic,
+			"messaging.kafka.partition", int(message.Partition), "messaging.kafka.offset", message.Offset)
+
+		// the content type header names the codec, and older schema versions are upgraded as they're read
+		envelope, err := events.DecodeMessage(message)
 
 		if err != nil {
+			log.Error("error decoding message", "topic", message.Topic, "offset", message.Offset, "error", err)
+			span.RecordError(err)
+			span.End()
 			return err
 		}
 
-		err = repo.ProcessMessage(*user)
+		log = log.With("message_type", envelope.Type, "message_id", envelope.Id)
+		log.Debug("message claimed", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset,
+			"producer", envelope.Producer, "timestamp", envelope.Timestamp)
+
+		// an invalid message would fail the same way every time it's redelivered, so it's logged and skipped
+		if err = validation.Message(*envelope); err != nil {
+			log.Error("invalid message skipped", "offset", message.Offset, "error", err, "fields", apperrors.From(err).Fields)
+			span.RecordError(err)
+			span.End()
+			metrics.KafkaProcessing.Observe(time.Since(start).Seconds(), envelope.Type, "invalid")
+			session.MarkMessage(message, "")
+			continue
+		}
+
+		// the relay sends a message again when it dies before marking it sent, the copy has the same id
+		if envelope.Id != "" {
+			processed, err := consumer.handler.Processed(ctx, envelope.Id)
+
+			if err != nil {
+				log.Error("error checking whether the message was processed", "error", err)
+				span.RecordError(err)
+				span.End()
+				return err
+			}
+
+			if processed {
+				log.Info("message already processed, skipped", "offset", message.Offset)
+				span.End()
+				metrics.KafkaProcessing.Observe(time.Since(start).Seconds(), envelope.Type, "duplicate")
+				session.MarkMessage(message, "")
+				continue
+			}
+		}
+
+		// a rebuild swapping the users collection holds off applying messages until it's done
+		done := events.Consuming()
+		err = consumer.handler.Process(ctx, *envelope)
+		// a message refused for what it says, like an appeal that exists already or a flag on something deleted
+		// since, is refused again every time, it's recorded and skipped like an invalid one
+		rejected := err != nil && !retryable(err)
+		if (err == nil || rejected) && envelope.Id != "" {
+			if markErr := consumer.handler.MarkProcessed(ctx, *envelope); markErr != nil {
+				err, rejected = markErr, false
+			}
+		}
+		done()
+		span.RecordError(err)
+		span.End()
+
+		if rejected {
+			log.Warn("message rejected, skipped", "offset", message.Offset, "error", err,
+				"fields", apperrors.From(err).Fields)
+			metrics.KafkaProcessing.Observe(time.Since(start).Seconds(), envelope.Type, "rejected")
+			session.MarkMessage(message, "")
+			continue
+		}
+
+		metrics.KafkaProcessing.Observe(time.Since(start).Seconds(), envelope.Type, metrics.Result(err))
 
 		if err != nil {
+			log.Error("error processing message", "error", err)
 			return err
 		}
 
+		consumer.handler.Publish(ctx, *envelope)
+
 		session.MarkMessage(message, "")
 	}
 
 	return nil
 }
+
+// retryable reports whether a message failed for a reason that can pass, Mongo failing or timing out, so the
+// session ends and the message is delivered again
+func retryable(err error) bool {
+	switch apperrors.From(err).Kind {
+	case apperrors.KindInternal, apperrors.KindTimeout, apperrors.KindUnavailable:
+		return true
+	}
+	return false
+}
+
+// header returns the value of a message header, or def when the message was sent without it
+func header(message *sarama.ConsumerMessage, key string, def string) string {
+	for _, h := range message.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return def
+}
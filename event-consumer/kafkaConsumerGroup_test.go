@@ -0,0 +1,178 @@
+package event_consumer
+
+import (
+	"context"
+	"errors"
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"example.com/app/events"
+	"github.com/Shopify/sarama"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("SECRET", "test-secret")
+	os.Setenv("EXPIRATION", "5")
+	os.Setenv("DB_NAME", "mongodb://")
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", ":27017")
+	os.Exit(m.Run())
+}
+
+// memoryHandler answers each message with the error for its id and keeps what the consumer did with it
+type memoryHandler struct {
+	errs      map[string]error
+	processed map[string]bool
+	published []string
+}
+
+func (h *memoryHandler) Processed(_ context.Context, id string) (bool, error) {
+	return h.processed[id], nil
+}
+
+func (h *memoryHandler) Process(_ context.Context, message domain.Envelope) error {
+	return h.errs[message.Id]
+}
+
+func (h *memoryHandler) MarkProcessed(_ context.Context, message domain.Envelope) error {
+	h.processed[message.Id] = true
+	return nil
+}
+
+func (h *memoryHandler) Publish(_ context.Context, message domain.Envelope) {
+	h.published = append(h.published, message.Id)
+}
+
+// memorySession keeps the offsets the consumer marks
+type memorySession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *memorySession) Context() context.Context { return context.Background() }
+
+func (s *memorySession) MarkMessage(message *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, message.Offset)
+}
+
+type memoryClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c memoryClaim) HighWaterMarkOffset() int64               { return int64(cap(c.messages)) }
+func (c memoryClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func appealMessage(t *testing.T, id string, offset int64) *sarama.ConsumerMessage {
+	envelope := &domain.Envelope{
+		SchemaVersion: domain.MessageSchemaVersion, Type: domain.MessageAppealCreated, Id: id, Timestamp: time.Now(),
+		Appeal: &domain.Appeal{ActionId: primitive.NewObjectID(), AppellantUsername: "someone", Statement: "not me"},
+	}
+	return encoded(t, envelope, offset)
+}
+
+func encoded(t *testing.T, envelope *domain.Envelope, offset int64) *sarama.ConsumerMessage {
+	codec, _ := events.CodecNamed("json")
+	b, err := events.Encode(codec, envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sarama.ConsumerMessage{Topic: "user", Offset: offset, Value: b,
+		Headers: []*sarama.RecordHeader{{Key: []byte(events.ContentTypeHeader), Value: []byte(codec.ContentType())}}}
+}
+
+func consume(t *testing.T, handler *memoryHandler, messages ...*sarama.ConsumerMessage) (*memorySession, error) {
+	claim := memoryClaim{messages: make(chan *sarama.ConsumerMessage, len(messages))}
+	for _, m := range messages {
+		claim.messages <- m
+	}
+	close(claim.messages)
+
+	session := &memorySession{}
+	consumer := &Consumer{handler: handler}
+	return session, consumer.ConsumeClaim(session, claim)
+}
+
+func TestConsumerSkipsRejectedAppeal(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+	}{
+		{"duplicate appeal", apperrors.Conflict("appeal_exists", "appeal already exists")},
+		{"appeal by someone else", apperrors.Forbidden("not_affected_user", "only the affected user can appeal this action")},
+		{"unknown action", apperrors.NotFound("action_not_found", "cannot find moderation action")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &memoryHandler{errs: map[string]error{"a": tc.err}, processed: map[string]bool{}}
+
+			session, err := consume(t, handler, appealMessage(t, "a", 0), appealMessage(t, "b", 1))
+			if err != nil {
+				t.Fatalf("ConsumeClaim = %v, want the rejected appeal skipped", err)
+			}
+
+			if len(session.marked) != 2 || session.marked[0] != 0 || session.marked[1] != 1 {
+				t.Fatalf("marked offsets %v, want [0 1]", session.marked)
+			}
+			if !handler.processed["a"] || !handler.processed["b"] {
+				t.Fatalf("processed %v, want both recorded", handler.processed)
+			}
+			if len(handler.published) != 1 || handler.published[0] != "b" {
+				t.Fatalf("published %v, want only the applied appeal", handler.published)
+			}
+		})
+	}
+}
+
+func TestConsumerStopsOnFailure(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+	}{
+		{"internal", apperrors.Internal(errors.New("boom"))},
+		{"timeout", apperrors.Timeout("db_timeout", "the database took too long")},
+		{"unavailable", apperrors.Unavailable("db_unavailable", "cannot reach the database")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &memoryHandler{errs: map[string]error{"a": tc.err}, processed: map[string]bool{}}
+
+			session, err := consume(t, handler, appealMessage(t, "a", 0), appealMessage(t, "b", 1))
+			if err == nil {
+				t.Fatal("ConsumeClaim = nil, want the session ended so the message is delivered again")
+			}
+
+			if len(session.marked) != 0 || handler.processed["a"] || len(handler.published) != 0 {
+				t.Fatalf("marked %v processed %v published %v, want nothing", session.marked, handler.processed,
+					handler.published)
+			}
+		})
+	}
+}
+
+func TestConsumerSkipsFlagOnDeletedResource(t *testing.T) {
+	flag := encoded(t, &domain.Envelope{
+		SchemaVersion: domain.MessageSchemaVersion, Type: domain.MessageFlagCreated, Id: "f", Timestamp: time.Now(),
+		Flag: &domain.Flag{FlaggerID: primitive.NewObjectID(), FlaggedResource: primitive.NewObjectID(),
+			ResourceType: "story", Reason: "spam"},
+	}, 0)
+	handler := &memoryHandler{errs: map[string]error{"f": apperrors.NotFound("resource_not_found", "resource not found")},
+		processed: map[string]bool{}}
+
+	session, err := consume(t, handler, flag, appealMessage(t, "a", 1))
+	if err != nil {
+		t.Fatalf("ConsumeClaim = %v, want the flag skipped", err)
+	}
+
+	if len(session.marked) != 2 || !handler.processed["f"] {
+		t.Fatalf("marked offsets %v processed %v, want the flag marked and recorded", session.marked, handler.processed)
+	}
+	if len(handler.published) != 1 || handler.published[0] != "a" {
+		t.Fatalf("published %v, want only the appeal after it", handler.published)
+	}
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"encoding/json"
+	"example.com/app/domain"
+	"fmt"
+	"github.com/Shopify/sarama"
+	"github.com/vmihailenco/msgpack/v5"
+	"strconv"
+)
+
+// ContentTypeHeader names the codec a message was written with, a message without it is msgpack
+const ContentTypeHeader = "content-type"
+
+// Codec turns the envelope into the bytes of a kafka message and back
+type Codec interface {
+	Name() string
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string                               { return "msgpack" }
+func (msgpackCodec) ContentType() string                        { return "application/msgpack" }
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) ContentType() string                        { return "application/json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string                               { return "protobuf" }
+func (protobufCodec) ContentType() string                        { return "application/x-protobuf" }
+func (protobufCodec) Marshal(v interface{}) ([]byte, error)      { return marshalProto(v) }
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error { return unmarshalProto(data, v) }
+
+var codecs = []Codec{msgpackCodec{}, jsonCodec{}, protobufCodec{}}
+
+// CodecNamed returns the codec called name, msgpack, json or protobuf
+func CodecNamed(name string) (Codec, bool) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// codecFor returns the codec of a content type header. Messages from before the header are msgpack.
+func codecFor(contentType string) (Codec, error) {
+	if contentType == "" {
+		return msgpackCodec{}, nil
+	}
+	for _, c := range codecs {
+		if c.ContentType() == contentType {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported content type %q", contentType)
+}
+
+// Encode writes the envelope with codec
+func Encode(codec Codec, envelope *domain.Envelope) ([]byte, error) {
+	return codec.Marshal(toWire(envelope))
+}
+
+// Decode reads a message written with the codec of contentType. An older schema version is upgraded to the
+// current one, a newer one is refused rather than read with fields missing.
+func Decode(contentType string, data []byte) (*domain.Envelope, error) {
+	codec, err := codecFor(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// every version from 2 has the schema version in the same place, version 1 has none
+	var probe struct {
+		SchemaVersion int `json:"schemaVersion" msgpack:"schemaVersion" proto:"1"`
+	}
+	if err = codec.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case probe.SchemaVersion == 0 && codec.Name() != "protobuf":
+		message := new(domain.Message)
+		if err = codec.Unmarshal(data, message); err != nil {
+			return nil, err
+		}
+		return upgradeV1(*message), nil
+	case probe.SchemaVersion < 2 || probe.SchemaVersion > domain.MessageSchemaVersion:
+		return nil, fmt.Errorf("unsupported schema version %d", probe.SchemaVersion)
+	}
+
+	w := new(wireEnvelope)
+	if err = codec.Unmarshal(data, w); err != nil {
+		return nil, err
+	}
+
+	return fromWire(w)
+}
+
+// DecodeMessage decodes a kafka message with the codec its content type header names. Version 1 messages have
+// neither an id nor a timestamp, their place in the topic and the time kafka took them fill in for them.
+func DecodeMessage(message *sarama.ConsumerMessage) (*domain.Envelope, error) {
+	contentType := ""
+	for _, h := range message.Headers {
+		if string(h.Key) == ContentTypeHeader {
+			contentType = string(h.Value)
+		}
+	}
+
+	envelope, err := Decode(contentType, message.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.Id == "" {
+		envelope.Id = fmt.Sprintf("%s/%d/%d", message.Topic, message.Partition, message.Offset)
+	}
+	if envelope.Timestamp.IsZero() {
+		envelope.Timestamp = message.Timestamp
+	}
+
+	return envelope, nil
+}
+
+// v1Types are the message types of the version 1 resource and message types
+var v1Types = map[string]map[int]string{
+	"user":   {201: domain.MessageUserCreated, 200: domain.MessageUserUpdated, 204: domain.MessageUserDeleted},
+	"story":  {201: domain.MessageStoryCreated, 200: domain.MessageStoryUpdated, 204: domain.MessageStoryDeleted},
+	"flag":   {201: domain.MessageFlagCreated},
+	"appeal": {201: domain.MessageAppealCreated},
+	"event":  {0: domain.MessageEventCreated},
+}
+
+// upgradeV1 moves the payload a version 1 message was about into an envelope. Version 1 had no id, timestamp or
+// producer, the consumer fills in the first two from the kafka message. A message of an unknown type keeps it as
+// resource.number so validation can name it.
+func upgradeV1(m domain.Message) *domain.Envelope {
+	t, ok := v1Types[m.ResourceType][m.MessageType]
+	if !ok {
+		t = m.ResourceType + "." + strconv.Itoa(m.MessageType)
+	}
+
+	e := &domain.Envelope{SchemaVersion: domain.MessageSchemaVersion, Type: t}
+
+	switch m.ResourceType {
+	case "user":
+		e.User = &m.User
+	case "story":
+		e.Story = &m.Story
+	case "flag":
+		e.Flag = &m.Flag
+	case "appeal":
+		e.Appeal = &m.Appeal
+	case "event":
+		e.Event = &m.Event
+	}
+
+	return e
+}
@@ -0,0 +1,225 @@
+package events
+
+import (
+	"bytes"
+	"example.com/app/domain"
+	"flag"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// update rewrites the golden files in testdata from what the codecs write now. Only run it for a change to the
+// schema that is meant to change the bytes, like a new field.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+var (
+	at    = time.Date(2026, 3, 14, 15, 9, 26, 535897000, time.UTC)
+	later = at.Add(90 * time.Minute)
+)
+
+// oid is a fixed id, so the fixtures encode the same every time
+func oid(n int) primitive.ObjectID {
+	id, _ := primitive.ObjectIDFromHex(fmt.Sprintf("%024x", n))
+	return id
+}
+
+// fixtures are one envelope of every message type with every field of its payload set, so a field the wire
+// types leave out shows up as a difference
+func fixtures(t *testing.T) []*domain.Envelope {
+	user := &domain.User{
+		Id: oid(1), Username: "someone", Email: "someone@example.com", Password: "hash",
+		CurrentTagLine: "tag line", ProfilePictureUrl: "https://example.com/p.png",
+		ProfileBackgroundPictureUrl: "https://example.com/b.png", CurrentBadgeUrl: "https://example.com/badge.png",
+		UnlockedBadgesUrls: []string{"https://example.com/1.png"}, BlockList: []string{"troll"},
+		BlockByList: []string{"other"}, FlagCount: []primitive.ObjectID{oid(2)},
+		Followers: []string{"a", "b"}, Following: []string{"c"}, FollowerCount: 2, DisplayFollowerCount: true,
+		ProfileIsViewable: true, IsLocked: true, Hidden: true, IsVerified: true, AcceptMessages: true,
+		LastLoginIp: "203.0.113.7", LastLoginIps: []string{"203.0.113.7", "198.51.100.2"}, CreatedAt: at, UpdatedAt: later,
+	}
+	story := &domain.Story{
+		Id: oid(3), Title: "title", Content: "content", AuthorUsername: "someone",
+		Likes: []string{"a"}, Dislikes: []string{"b"}, LikeCount: 1, DislikeCount: 1, Score: -3,
+		Tags: []domain.Tag{{Value: "campfire"}}, Updated: true, Hidden: true, CreatedDate: "2026-03-14",
+		UpdatedDate: "2026-03-15",
+	}
+	flag := &domain.Flag{
+		Id: oid(4), FlaggerID: oid(5), FlaggedResource: oid(3), ResourceType: "story", Reason: "spam", CreatedAt: at,
+	}
+	appeal := &domain.Appeal{
+		Id: oid(6), ActionId: oid(7), AppellantUsername: "someone", Statement: "statement", Status: "pending",
+		OriginalActor: "admin", ReviewerUsername: "reviewer", Decision: "decision", Source: "kafka", CreatedAt: at,
+		DecidedAt: later,
+	}
+	event := &domain.Event{Action: "deleted", Target: "story", ResourceId: oid(3), ActorUsername: "admin", Message: "message"}
+
+	for _, payload := range []interface{}{user, story, flag, appeal, event} {
+		v := reflect.ValueOf(payload).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).IsZero() {
+				t.Fatalf("%s.%s is not set", v.Type().Name(), v.Type().Field(i).Name)
+			}
+		}
+	}
+
+	envelopes := []*domain.Envelope{
+		{Type: domain.MessageUserCreated, User: user},
+		{Type: domain.MessageUserUpdated, User: user},
+		{Type: domain.MessageUserDeleted, User: user},
+		{Type: domain.MessageStoryCreated, Story: story},
+		{Type: domain.MessageStoryUpdated, Story: story},
+		{Type: domain.MessageStoryDeleted, Story: story},
+		{Type: domain.MessageFlagCreated, Flag: flag},
+		{Type: domain.MessageAppealCreated, Appeal: appeal},
+		{Type: domain.MessageEventCreated, Event: event},
+	}
+
+	for i, e := range envelopes {
+		e.SchemaVersion = domain.MessageSchemaVersion
+		e.Id = oid(100 + i).Hex()
+		e.Timestamp = at
+		e.Producer = "control-service"
+	}
+
+	return envelopes
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range codecs {
+		for _, envelope := range fixtures(t) {
+			envelope := envelope
+			t.Run(codec.Name()+"/"+envelope.Type, func(t *testing.T) {
+				b, err := Encode(codec, envelope)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := Decode(codec.ContentType(), b)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(inUTC(got), envelope) {
+					t.Fatalf("decoded\n%+v\nwant\n%+v", describe(got), describe(envelope))
+				}
+			})
+		}
+	}
+}
+
+// TestCodecGolden pins the bytes of every message type in every codec. A changed field name, msgpack key or
+// protobuf number changes what other services read, it fails here rather than in production.
+func TestCodecGolden(t *testing.T) {
+	for _, codec := range codecs {
+		for _, envelope := range fixtures(t) {
+			envelope := envelope
+			path := filepath.Join("testdata", envelope.Type+"."+codec.Name())
+
+			t.Run(codec.Name()+"/"+envelope.Type, func(t *testing.T) {
+				b, err := Encode(codec, envelope)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if *update {
+					if err = ioutil.WriteFile(path, b, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				golden, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(b, golden) {
+					t.Fatalf("%s encodes as\n%q\nwant\n%q", codec.Name(), b, golden)
+				}
+
+				got, err := Decode(codec.ContentType(), golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(inUTC(got), envelope) {
+					t.Fatalf("golden decodes as\n%+v\nwant\n%+v", describe(got), describe(envelope))
+				}
+			})
+		}
+	}
+}
+
+// TestDecodeV1 reads a version 1 message, the bare msgpack Message sent before the envelope, and upgrades it
+func TestDecodeV1(t *testing.T) {
+	path := filepath.Join("testdata", "v1.user.updated.msgpack")
+
+	user := domain.User{
+		Id: oid(1), Username: "someone", Email: "someone@example.com", CurrentTagLine: "tag line",
+		Followers: []string{"a"}, FollowerCount: 1, IsVerified: true, CreatedAt: at, UpdatedAt: later,
+	}
+
+	if *update {
+		b, err := msgpack.Marshal(domain.Message{User: user, MessageType: 200, ResourceType: "user"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the consumer reads messages without a content type header as msgpack
+	got, err := Decode("", golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.SchemaVersion != domain.MessageSchemaVersion || got.Type != domain.MessageUserUpdated {
+		t.Fatalf("upgraded to version %d type %q, want version %d type %q", got.SchemaVersion, got.Type,
+			domain.MessageSchemaVersion, domain.MessageUserUpdated)
+	}
+	if got.Id != "" || !got.Timestamp.IsZero() || got.Story != nil || got.Flag != nil || got.Appeal != nil || got.Event != nil {
+		t.Fatalf("upgraded to %+v, want only the user", describe(got))
+	}
+
+	got.User.CreatedAt, got.User.UpdatedAt = got.User.CreatedAt.UTC(), got.User.UpdatedAt.UTC()
+	if !reflect.DeepEqual(*got.User, user) {
+		t.Fatalf("user\n%+v\nwant\n%+v", *got.User, user)
+	}
+}
+
+// inUTC moves the times of a decoded envelope to UTC, msgpack reads them in the local zone
+func inUTC(e *domain.Envelope) *domain.Envelope {
+	e.Timestamp = e.Timestamp.UTC()
+	if e.User != nil {
+		e.User.CreatedAt, e.User.UpdatedAt = e.User.CreatedAt.UTC(), e.User.UpdatedAt.UTC()
+	}
+	if e.Flag != nil {
+		e.Flag.CreatedAt = e.Flag.CreatedAt.UTC()
+	}
+	if e.Appeal != nil {
+		e.Appeal.CreatedAt, e.Appeal.DecidedAt = e.Appeal.CreatedAt.UTC(), e.Appeal.DecidedAt.UTC()
+	}
+	return e
+}
+
+// describe spells out the payloads, which %+v would print as pointers
+func describe(e *domain.Envelope) []interface{} {
+	d := []interface{}{e.SchemaVersion, e.Type, e.Id, e.Timestamp, e.Producer}
+	for _, p := range []interface{}{e.User, e.Story, e.Flag, e.Appeal, e.Event} {
+		if v := reflect.ValueOf(p); !v.IsNil() {
+			d = append(d, v.Elem().Interface())
+		}
+	}
+	return d
+}
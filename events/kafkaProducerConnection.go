@@ -1,43 +1,92 @@
 package events
 
 import (
+	"context"
+	appConfig "example.com/app/config"
 	"github.com/Shopify/sarama"
 	"sync"
 )
 
 type Connection struct {
 	sarama.SyncProducer
+	client sarama.Client
 }
 
 var kafkaConnection *Connection
-var once sync.Once
+var mu sync.Mutex
 
 // GetInstance creates one instance and always returns that one instance
 func GetInstance() *Connection {
-	// only executes this once
-	once.Do(func() {
-		_, err := connectProducer()
+	conn, err := instance()
+	if err != nil {
+		panic(err)
+	}
+	return conn
+}
+
+// Producer returns the shared producer, connecting first when it isn't connected yet
+func Producer() (*Connection, error) {
+	return instance()
+}
+
+// instance connects on first use, and again on the next call when connecting failed
+func instance() (*Connection, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if kafkaConnection == nil {
+		if _, err := connectProducer(); err != nil {
+			return nil, err
+		}
+	}
+	return kafkaConnection, nil
+}
+
+// Ping checks that the producer can reach the brokers by refreshing the cluster metadata
+func Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+
+	go func() {
+		conn, err := instance()
 		if err != nil {
-			panic(err)
+			done <- err
+			return
 		}
-	})
-	return kafkaConnection
+		done <- conn.client.RefreshMetadata()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func connectProducer() (sarama.SyncProducer,error) {
-	brokersUrl := []string{"localhost:19092", "localhost:29092", "localhost:39092", "localhost:49092", "localhost:59092"}
+	brokersUrl := appConfig.Get().KafkaBrokers
 
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 7
-	// NewSyncProducer creates a new SyncProducer using the given broker addresses and configuration.
-	conn, err := sarama.NewSyncProducer(brokersUrl, config)
+	// the broker drops the copies the producer's own retries would otherwise write, idempotence needs one
+	// request in flight per broker
+	config.Producer.Idempotent = true
+	config.Net.MaxOpenRequests = 1
+	// the producer is built on a client we keep so Ping can reach the brokers
+	client, err := sarama.NewClient(brokersUrl, config)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	conn, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
 	}
 
-	kafkaConnection = &Connection{conn}
+	kafkaConnection = &Connection{conn, client}
 
 	return kafkaConnection, nil
 }
@@ -0,0 +1,239 @@
+package events
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// The protobuf codec writes the wire types in the protobuf binary format without generated code. A field is
+// numbered by its proto tag: strings and nested messages are length delimited, ints and bools are varints and
+// times are google.protobuf.Timestamp messages. Fields at their zero value are left out like proto3 does, and
+// unknown fields are skipped when reading so newer producers can add fields.
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func marshalProto(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("protobuf: cannot marshal %T", v)
+	}
+	return appendMessage(nil, rv.Elem())
+}
+
+func unmarshalProto(b []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("protobuf: cannot unmarshal into %T", v)
+	}
+	return readMessage(b, rv.Elem())
+}
+
+// fieldNumber is the number in the proto tag of a struct field, 0 when it has none
+func fieldNumber(f reflect.StructField) int {
+	n, _ := strconv.Atoi(f.Tag.Get("proto"))
+	return n
+}
+
+func appendVarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+func appendKey(b []byte, number int, wireType int) []byte {
+	return appendVarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+func appendBytes(b []byte, number int, data []byte) []byte {
+	b = appendKey(b, number, wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendMessage(b []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		number := fieldNumber(t.Field(i))
+		if number == 0 {
+			continue
+		}
+
+		f := v.Field(i)
+		switch {
+		case f.Type() == timeType:
+			ts := f.Interface().(time.Time)
+			if ts.IsZero() {
+				continue
+			}
+			var m []byte
+			if s := ts.Unix(); s != 0 {
+				m = appendVarint(appendKey(m, 1, wireVarint), uint64(s))
+			}
+			if n := ts.Nanosecond(); n != 0 {
+				m = appendVarint(appendKey(m, 2, wireVarint), uint64(n))
+			}
+			b = appendBytes(b, number, m)
+		case f.Kind() == reflect.String:
+			if f.Len() > 0 {
+				b = appendBytes(b, number, []byte(f.String()))
+			}
+		case f.Kind() == reflect.Bool:
+			if f.Bool() {
+				b = appendVarint(appendKey(b, number, wireVarint), 1)
+			}
+		case f.Kind() == reflect.Int || f.Kind() == reflect.Int64:
+			if f.Int() != 0 {
+				b = appendVarint(appendKey(b, number, wireVarint), uint64(f.Int()))
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+			for j := 0; j < f.Len(); j++ {
+				b = appendBytes(b, number, []byte(f.Index(j).String()))
+			}
+		case f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct:
+			if f.IsNil() {
+				continue
+			}
+			m, err := appendMessage(nil, f.Elem())
+			if err != nil {
+				return nil, err
+			}
+			b = appendBytes(b, number, m)
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported field %s.%s", t.Name(), t.Field(i).Name)
+		}
+	}
+
+	return b, nil
+}
+
+// protoField is one field read from a message, data holds the bytes of a length delimited one
+type protoField struct {
+	number   int
+	wireType int
+	varint   uint64
+	data     []byte
+}
+
+// nextField reads the field at the start of b and returns the rest
+func nextField(b []byte) (protoField, []byte, error) {
+	key, n := binary.Uvarint(b)
+	if n <= 0 {
+		return protoField{}, nil, fmt.Errorf("protobuf: invalid field key")
+	}
+	b = b[n:]
+	f := protoField{number: int(key >> 3), wireType: int(key & 7)}
+
+	switch f.wireType {
+	case wireVarint:
+		f.varint, n = binary.Uvarint(b)
+		if n <= 0 {
+			return f, nil, fmt.Errorf("protobuf: invalid varint in field %d", f.number)
+		}
+		return f, b[n:], nil
+	case wireBytes:
+		size, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < size {
+			return f, nil, fmt.Errorf("protobuf: truncated field %d", f.number)
+		}
+		f.data = b[n : n+int(size)]
+		return f, b[n+int(size):], nil
+	case wireFixed64, wireFixed32:
+		size := 8
+		if f.wireType == wireFixed32 {
+			size = 4
+		}
+		if len(b) < size {
+			return f, nil, fmt.Errorf("protobuf: truncated field %d", f.number)
+		}
+		return f, b[size:], nil
+	default:
+		return f, nil, fmt.Errorf("protobuf: unsupported wire type %d in field %d", f.wireType, f.number)
+	}
+}
+
+func readMessage(b []byte, v reflect.Value) error {
+	t := v.Type()
+	fields := map[int]int{}
+	for i := 0; i < t.NumField(); i++ {
+		if number := fieldNumber(t.Field(i)); number != 0 {
+			fields[number] = i
+		}
+	}
+
+	for len(b) > 0 {
+		pf, rest, err := nextField(b)
+		if err != nil {
+			return err
+		}
+		b = rest
+
+		i, ok := fields[pf.number]
+		if !ok {
+			continue
+		}
+
+		if err = setField(v.Field(i), pf); err != nil {
+			return fmt.Errorf("protobuf: %s.%s: %w", t.Name(), t.Field(i).Name, err)
+		}
+	}
+
+	return nil
+}
+
+func setField(f reflect.Value, pf protoField) error {
+	want := wireBytes
+	if f.Kind() == reflect.Bool || f.Kind() == reflect.Int || f.Kind() == reflect.Int64 {
+		want = wireVarint
+	}
+	if pf.wireType != want {
+		return fmt.Errorf("wire type %d, expected %d", pf.wireType, want)
+	}
+
+	switch {
+	case f.Type() == timeType:
+		var seconds, nanos int64
+		for b := pf.data; len(b) > 0; {
+			tf, rest, err := nextField(b)
+			if err != nil {
+				return err
+			}
+			b = rest
+			switch tf.number {
+			case 1:
+				seconds = int64(tf.varint)
+			case 2:
+				nanos = int64(tf.varint)
+			}
+		}
+		f.Set(reflect.ValueOf(time.Unix(seconds, nanos).UTC()))
+	case f.Kind() == reflect.String:
+		f.SetString(string(pf.data))
+	case f.Kind() == reflect.Bool:
+		f.SetBool(pf.varint != 0)
+	case f.Kind() == reflect.Int || f.Kind() == reflect.Int64:
+		f.SetInt(int64(pf.varint))
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		f.Set(reflect.Append(f, reflect.ValueOf(string(pf.data))))
+	case f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct:
+		// a message sent more than once is merged into what was read before
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		return readMessage(pf.data, f.Elem())
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
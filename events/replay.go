@@ -0,0 +1,160 @@
+package events
+
+import (
+	"context"
+	appConfig "example.com/app/config"
+	"example.com/app/domain"
+	"github.com/Shopify/sarama"
+	"sync"
+	"time"
+)
+
+// replayIdle is how long a partition can go quiet before the replay takes it as read, the last offsets before
+// the end can be compacted away or be markers that are never delivered
+const replayIdle = 10 * time.Second
+
+// consuming is held for reading while the consumer group applies a message, a rebuild takes it to stop this
+// instance applying anything while it swaps collections
+var consuming sync.RWMutex
+
+// Consuming waits for any swap to finish and returns the function to call once the message is applied
+func Consuming() func() {
+	consuming.RLock()
+	return consuming.RUnlock
+}
+
+// PauseConsuming waits for the message being applied and holds off the next one until resume is called
+func PauseConsuming() (resume func()) {
+	consuming.Lock()
+	return consuming.Unlock
+}
+
+// Replayer reads partitions from a chosen offset outside the consumer group, so the group's offsets stay
+// where they are
+type Replayer struct {
+	client   sarama.Client
+	consumer sarama.Consumer
+}
+
+func NewReplayer() (*Replayer, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	client, err := sarama.NewClient(appConfig.Get().KafkaBrokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	return &Replayer{client, consumer}, nil
+}
+
+// Plan lists every partition of the topics from where the replay starts to their current end. from is an
+// offset in every partition, moved into the range the partition still holds, at is the first message from
+// that time, and the oldest message is the start when both are nil.
+func (r *Replayer) Plan(topics []string, from *int64, at *time.Time) ([]domain.RebuildPartition, error) {
+	var plan []domain.RebuildPartition
+
+	for _, topic := range topics {
+		partitions, err := r.client.Partitions(topic)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range partitions {
+			oldest, err := r.client.GetOffset(topic, p, sarama.OffsetOldest)
+			if err != nil {
+				return nil, err
+			}
+
+			end, err := r.client.GetOffset(topic, p, sarama.OffsetNewest)
+			if err != nil {
+				return nil, err
+			}
+
+			start := oldest
+			switch {
+			case at != nil:
+				start, err = r.client.GetOffset(topic, p, at.UnixNano()/int64(time.Millisecond))
+				if err != nil {
+					return nil, err
+				}
+				// nothing was written since then
+				if start < 0 {
+					start = end
+				}
+			case from != nil && *from > end:
+				start = end
+			case from != nil && *from > oldest:
+				start = *from
+			}
+
+			plan = append(plan, domain.RebuildPartition{Topic: topic, Partition: p, Start: start, End: end, Offset: start})
+		}
+	}
+
+	return plan, nil
+}
+
+// Extend moves the end of every partition to where it is now, for a replay to catch up with what was
+// written since it was planned
+func (r *Replayer) Extend(plan []domain.RebuildPartition) error {
+	for i := range plan {
+		end, err := r.client.GetOffset(plan[i].Topic, plan[i].Partition, sarama.OffsetNewest)
+		if err != nil {
+			return err
+		}
+		plan[i].End = end
+	}
+	return nil
+}
+
+// Replay passes every message of a partition from its offset up to its end to apply, moving the offset on as
+// it goes. It stops at the first error apply returns, or when ctx is done.
+func (r *Replayer) Replay(ctx context.Context, part *domain.RebuildPartition, apply func(*sarama.ConsumerMessage) error) error {
+	if part.Offset >= part.End {
+		return nil
+	}
+
+	pc, err := r.consumer.ConsumePartition(part.Topic, part.Partition, part.Offset)
+	if err != nil {
+		return err
+	}
+	defer pc.AsyncClose()
+
+	idle := time.NewTimer(replayIdle)
+	defer idle.Stop()
+
+	for part.Offset < part.End {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-pc.Errors():
+			return err
+		case <-idle.C:
+			part.End = part.Offset
+			return nil
+		case message := <-pc.Messages():
+			if err := apply(message); err != nil {
+				return err
+			}
+			part.Offset = message.Offset + 1
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(replayIdle)
+		}
+	}
+
+	return nil
+}
+
+func (r *Replayer) Close() error {
+	_ = r.consumer.Close()
+	return r.client.Close()
+}
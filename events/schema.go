@@ -0,0 +1,256 @@
+package events
+
+import (
+	"example.com/app/domain"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// The wire types are the schema of the envelope, apart from the domain types so their json tags and fields can
+// change without changing what's sent. Every field has the same name in json and msgpack and a number for
+// protobuf, message.proto describes the same schema. Fields are only ever added, with a new number.
+
+type wireEnvelope struct {
+	SchemaVersion int       `json:"schemaVersion" msgpack:"schemaVersion" proto:"1"`
+	Type          string    `json:"type" msgpack:"type" proto:"2"`
+	Id            string    `json:"id" msgpack:"id" proto:"3"`
+	Timestamp     time.Time `json:"timestamp" msgpack:"timestamp" proto:"4"`
+	Producer      string    `json:"producer" msgpack:"producer" proto:"5"`
+
+	User   *wireUser   `json:"user,omitempty" msgpack:"user,omitempty" proto:"10"`
+	Story  *wireStory  `json:"story,omitempty" msgpack:"story,omitempty" proto:"11"`
+	Flag   *wireFlag   `json:"flag,omitempty" msgpack:"flag,omitempty" proto:"12"`
+	Appeal *wireAppeal `json:"appeal,omitempty" msgpack:"appeal,omitempty" proto:"13"`
+	Event  *wireEvent  `json:"event,omitempty" msgpack:"event,omitempty" proto:"14"`
+}
+
+type wireUser struct {
+	Id                          string    `json:"id" msgpack:"id" proto:"1"`
+	Username                    string    `json:"username" msgpack:"username" proto:"2"`
+	Email                       string    `json:"email" msgpack:"email" proto:"3"`
+	Password                    string    `json:"password" msgpack:"password" proto:"4"`
+	CurrentTagLine              string    `json:"currentTagLine" msgpack:"currentTagLine" proto:"5"`
+	ProfilePictureUrl           string    `json:"profilePictureUrl" msgpack:"profilePictureUrl" proto:"6"`
+	ProfileBackgroundPictureUrl string    `json:"profileBackgroundPictureUrl" msgpack:"profileBackgroundPictureUrl" proto:"7"`
+	CurrentBadgeUrl             string    `json:"currentBadgeUrl" msgpack:"currentBadgeUrl" proto:"8"`
+	UnlockedBadgesUrls          []string  `json:"unlockedBadgesUrls" msgpack:"unlockedBadgesUrls" proto:"9"`
+	BlockList                   []string  `json:"blockList" msgpack:"blockList" proto:"10"`
+	BlockByList                 []string  `json:"blockByList" msgpack:"blockByList" proto:"11"`
+	FlagCount                   []string  `json:"flagCount" msgpack:"flagCount" proto:"12"`
+	Followers                   []string  `json:"followers" msgpack:"followers" proto:"13"`
+	Following                   []string  `json:"following" msgpack:"following" proto:"14"`
+	FollowerCount               int       `json:"followerCount" msgpack:"followerCount" proto:"15"`
+	DisplayFollowerCount        bool      `json:"displayFollowerCount" msgpack:"displayFollowerCount" proto:"16"`
+	ProfileIsViewable           bool      `json:"profileIsViewable" msgpack:"profileIsViewable" proto:"17"`
+	IsLocked                    bool      `json:"isLocked" msgpack:"isLocked" proto:"18"`
+	Hidden                      bool      `json:"hidden" msgpack:"hidden" proto:"19"`
+	IsVerified                  bool      `json:"isVerified" msgpack:"isVerified" proto:"20"`
+	AcceptMessages              bool      `json:"acceptMessages" msgpack:"acceptMessages" proto:"21"`
+	CreatedAt                   time.Time `json:"createdAt" msgpack:"createdAt" proto:"22"`
+	UpdatedAt                   time.Time `json:"updatedAt" msgpack:"updatedAt" proto:"23"`
+	LastLoginIp                 string    `json:"lastLoginIp" msgpack:"lastLoginIp" proto:"24"`
+	LastLoginIps                []string  `json:"lastLoginIps" msgpack:"lastLoginIps" proto:"25"`
+}
+
+type wireStory struct {
+	Id             string   `json:"id" msgpack:"id" proto:"1"`
+	Title          string   `json:"title" msgpack:"title" proto:"2"`
+	Content        string   `json:"content" msgpack:"content" proto:"3"`
+	AuthorUsername string   `json:"authorUsername" msgpack:"authorUsername" proto:"4"`
+	Likes          []string `json:"likes" msgpack:"likes" proto:"5"`
+	Dislikes       []string `json:"dislikes" msgpack:"dislikes" proto:"6"`
+	LikeCount      int      `json:"likeCount" msgpack:"likeCount" proto:"7"`
+	DislikeCount   int      `json:"dislikeCount" msgpack:"dislikeCount" proto:"8"`
+	Score          int      `json:"score" msgpack:"score" proto:"9"`
+	Tags           []string `json:"tags" msgpack:"tags" proto:"10"`
+	Updated        bool     `json:"updated" msgpack:"updated" proto:"11"`
+	Hidden         bool     `json:"hidden" msgpack:"hidden" proto:"12"`
+	CreatedDate    string   `json:"createdDate" msgpack:"createdDate" proto:"13"`
+	UpdatedDate    string   `json:"updatedDate" msgpack:"updatedDate" proto:"14"`
+}
+
+type wireFlag struct {
+	Id              string    `json:"id" msgpack:"id" proto:"1"`
+	FlaggerId       string    `json:"flaggerId" msgpack:"flaggerId" proto:"2"`
+	FlaggedResource string    `json:"flaggedResource" msgpack:"flaggedResource" proto:"3"`
+	ResourceType    string    `json:"resourceType" msgpack:"resourceType" proto:"4"`
+	Reason          string    `json:"reason" msgpack:"reason" proto:"5"`
+	CreatedAt       time.Time `json:"createdAt" msgpack:"createdAt" proto:"6"`
+}
+
+type wireAppeal struct {
+	Id                string    `json:"id" msgpack:"id" proto:"1"`
+	ActionId          string    `json:"actionId" msgpack:"actionId" proto:"2"`
+	AppellantUsername string    `json:"appellantUsername" msgpack:"appellantUsername" proto:"3"`
+	Statement         string    `json:"statement" msgpack:"statement" proto:"4"`
+	Status            string    `json:"status" msgpack:"status" proto:"5"`
+	OriginalActor     string    `json:"originalActor" msgpack:"originalActor" proto:"6"`
+	ReviewerUsername  string    `json:"reviewerUsername" msgpack:"reviewerUsername" proto:"7"`
+	Decision          string    `json:"decision" msgpack:"decision" proto:"8"`
+	Source            string    `json:"source" msgpack:"source" proto:"9"`
+	CreatedAt         time.Time `json:"createdAt" msgpack:"createdAt" proto:"10"`
+	DecidedAt         time.Time `json:"decidedAt" msgpack:"decidedAt" proto:"11"`
+}
+
+type wireEvent struct {
+	Action        string `json:"action" msgpack:"action" proto:"1"`
+	Target        string `json:"target" msgpack:"target" proto:"2"`
+	ResourceId    string `json:"resourceId" msgpack:"resourceId" proto:"3"`
+	ActorUsername string `json:"actorUsername" msgpack:"actorUsername" proto:"4"`
+	Message       string `json:"message" msgpack:"message" proto:"5"`
+}
+
+func toWire(e *domain.Envelope) *wireEnvelope {
+	w := &wireEnvelope{SchemaVersion: e.SchemaVersion, Type: e.Type, Id: e.Id, Timestamp: e.Timestamp, Producer: e.Producer}
+
+	if u := e.User; u != nil {
+		w.User = &wireUser{
+			Id: hexOf(u.Id), Username: u.Username, Email: u.Email, Password: u.Password, CurrentTagLine: u.CurrentTagLine,
+			ProfilePictureUrl: u.ProfilePictureUrl, ProfileBackgroundPictureUrl: u.ProfileBackgroundPictureUrl,
+			CurrentBadgeUrl: u.CurrentBadgeUrl, UnlockedBadgesUrls: u.UnlockedBadgesUrls, BlockList: u.BlockList,
+			BlockByList: u.BlockByList, FlagCount: hexesOf(u.FlagCount), Followers: u.Followers, Following: u.Following,
+			FollowerCount: u.FollowerCount, DisplayFollowerCount: u.DisplayFollowerCount, ProfileIsViewable: u.ProfileIsViewable,
+			IsLocked: u.IsLocked, Hidden: u.Hidden, IsVerified: u.IsVerified, AcceptMessages: u.AcceptMessages,
+			CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt, LastLoginIp: u.LastLoginIp, LastLoginIps: u.LastLoginIps,
+		}
+	}
+
+	if s := e.Story; s != nil {
+		tags := make([]string, len(s.Tags))
+		for i, t := range s.Tags {
+			tags[i] = t.Value
+		}
+		w.Story = &wireStory{
+			Id: hexOf(s.Id), Title: s.Title, Content: s.Content, AuthorUsername: s.AuthorUsername, Likes: s.Likes,
+			Dislikes: s.Dislikes, LikeCount: s.LikeCount, DislikeCount: s.DislikeCount, Score: s.Score, Tags: tags,
+			Updated: s.Updated, Hidden: s.Hidden, CreatedDate: s.CreatedDate, UpdatedDate: s.UpdatedDate,
+		}
+	}
+
+	if f := e.Flag; f != nil {
+		w.Flag = &wireFlag{
+			Id: hexOf(f.Id), FlaggerId: hexOf(f.FlaggerID), FlaggedResource: hexOf(f.FlaggedResource),
+			ResourceType: f.ResourceType, Reason: f.Reason, CreatedAt: f.CreatedAt,
+		}
+	}
+
+	if a := e.Appeal; a != nil {
+		w.Appeal = &wireAppeal{
+			Id: hexOf(a.Id), ActionId: hexOf(a.ActionId), AppellantUsername: a.AppellantUsername, Statement: a.Statement,
+			Status: a.Status, OriginalActor: a.OriginalActor, ReviewerUsername: a.ReviewerUsername, Decision: a.Decision,
+			Source: a.Source, CreatedAt: a.CreatedAt, DecidedAt: a.DecidedAt,
+		}
+	}
+
+	if ev := e.Event; ev != nil {
+		w.Event = &wireEvent{
+			Action: ev.Action, Target: ev.Target, ResourceId: hexOf(ev.ResourceId), ActorUsername: ev.ActorUsername,
+			Message: ev.Message,
+		}
+	}
+
+	return w
+}
+
+func fromWire(w *wireEnvelope) (*domain.Envelope, error) {
+	e := &domain.Envelope{SchemaVersion: w.SchemaVersion, Type: w.Type, Id: w.Id, Timestamp: w.Timestamp, Producer: w.Producer}
+	ids := idParser{}
+
+	if u := w.User; u != nil {
+		e.User = &domain.User{
+			Id: ids.parse("user.id", u.Id), Username: u.Username, Email: u.Email, Password: u.Password,
+			CurrentTagLine: u.CurrentTagLine, ProfilePictureUrl: u.ProfilePictureUrl,
+			ProfileBackgroundPictureUrl: u.ProfileBackgroundPictureUrl, CurrentBadgeUrl: u.CurrentBadgeUrl,
+			UnlockedBadgesUrls: u.UnlockedBadgesUrls, BlockList: u.BlockList, BlockByList: u.BlockByList,
+			FlagCount: ids.parseAll("user.flagCount", u.FlagCount), Followers: u.Followers, Following: u.Following,
+			FollowerCount: u.FollowerCount, DisplayFollowerCount: u.DisplayFollowerCount, ProfileIsViewable: u.ProfileIsViewable,
+			IsLocked: u.IsLocked, Hidden: u.Hidden, IsVerified: u.IsVerified, AcceptMessages: u.AcceptMessages,
+			CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt, LastLoginIp: u.LastLoginIp, LastLoginIps: u.LastLoginIps,
+		}
+	}
+
+	if s := w.Story; s != nil {
+		tags := make([]domain.Tag, len(s.Tags))
+		for i, t := range s.Tags {
+			tags[i] = domain.Tag{Value: t}
+		}
+		e.Story = &domain.Story{
+			Id: ids.parse("story.id", s.Id), Title: s.Title, Content: s.Content, AuthorUsername: s.AuthorUsername,
+			Likes: s.Likes, Dislikes: s.Dislikes, LikeCount: s.LikeCount, DislikeCount: s.DislikeCount, Score: s.Score,
+			Tags: tags, Updated: s.Updated, Hidden: s.Hidden, CreatedDate: s.CreatedDate, UpdatedDate: s.UpdatedDate,
+		}
+	}
+
+	if f := w.Flag; f != nil {
+		e.Flag = &domain.Flag{
+			Id: ids.parse("flag.id", f.Id), FlaggerID: ids.parse("flag.flaggerId", f.FlaggerId),
+			FlaggedResource: ids.parse("flag.flaggedResource", f.FlaggedResource), ResourceType: f.ResourceType,
+			Reason: f.Reason, CreatedAt: f.CreatedAt,
+		}
+	}
+
+	if a := w.Appeal; a != nil {
+		e.Appeal = &domain.Appeal{
+			Id: ids.parse("appeal.id", a.Id), ActionId: ids.parse("appeal.actionId", a.ActionId),
+			AppellantUsername: a.AppellantUsername, Statement: a.Statement, Status: a.Status, OriginalActor: a.OriginalActor,
+			ReviewerUsername: a.ReviewerUsername, Decision: a.Decision, Source: a.Source, CreatedAt: a.CreatedAt,
+			DecidedAt: a.DecidedAt,
+		}
+	}
+
+	if ev := w.Event; ev != nil {
+		e.Event = &domain.Event{
+			Action: ev.Action, Target: ev.Target, ResourceId: ids.parse("event.resourceId", ev.ResourceId),
+			ActorUsername: ev.ActorUsername, Message: ev.Message,
+		}
+	}
+
+	return e, ids.err
+}
+
+// hexOf writes an id as hex, a missing id is left empty
+func hexOf(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+func hexesOf(ids []primitive.ObjectID) []string {
+	if ids == nil {
+		return nil
+	}
+	hexes := make([]string, len(ids))
+	for i, id := range ids {
+		hexes[i] = hexOf(id)
+	}
+	return hexes
+}
+
+// idParser reads hex ids and keeps the first one that isn't an id
+type idParser struct {
+	err error
+}
+
+func (p *idParser) parse(field string, hex string) primitive.ObjectID {
+	if hex == "" {
+		return primitive.NilObjectID
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s: %q is not an id", field, hex)
+	}
+	return id
+}
+
+func (p *idParser) parseAll(field string, hexes []string) []primitive.ObjectID {
+	if hexes == nil {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(hexes))
+	for i, hex := range hexes {
+		ids[i] = p.parse(field, hex)
+	}
+	return ids
+}
@@ -11,4 +11,5 @@ require (
 	go.mongodb.org/mongo-driver v1.6.0
 	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e
 	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"example.com/app/domain"
+	"example.com/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AppealHandler struct {
+	AppealService services.AppealService
+}
+
+func (ah *AppealHandler) Create(c *fiber.Ctx) error {
+	c.Accepts("application/json")
+	appeal := new(domain.Appeal)
+	err := parseBody(c, appeal)
+
+	if err != nil {
+		return err
+	}
+
+	appeal.Source = "rest"
+
+	err = ah.AppealService.Create(c.UserContext(), appeal)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 201, appeal)
+}
+
+func (ah *AppealHandler) FindAll(c *fiber.Ctx) error {
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+
+	appeals, err := ah.AppealService.FindAll(c.UserContext(), page)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, appeals)
+}
+
+func (ah *AppealHandler) FindById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	appeal, err := ah.AppealService.FindById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, appeal)
+}
+
+func (ah *AppealHandler) Uphold(c *fiber.Ctx) error {
+	return ah.decide(c, domain.AppealUpheld)
+}
+
+func (ah *AppealHandler) Overturn(c *fiber.Ctx) error {
+	return ah.decide(c, domain.AppealOverturned)
+}
+
+func (ah *AppealHandler) decide(c *fiber.Ctx, status string) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	c.Accepts("application/json")
+	decision := new(domain.AppealDecision)
+	err = parseBody(c, decision)
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	appeal, err := ah.AppealService.Decide(c.UserContext(), id, status, admin.Username, decision.Decision)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, appeal)
+}
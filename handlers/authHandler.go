@@ -1,11 +1,10 @@
 package handlers
 
 import (
+	"example.com/app/apperrors"
 	"example.com/app/domain"
 	"example.com/app/services"
-	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 	"strings"
 )
 
@@ -16,21 +15,18 @@ type AuthHandler struct {
 func (ah *AuthHandler) Login(c *fiber.Ctx) error {
 	c.Accepts("application/json")
 	details := new(domain.LoginDetails)
-	err := c.BodyParser(details)
+	err := parseBody(c, details)
 
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
 	var auth domain.Authentication
 
-	user, token, err := ah.AuthService.Login(strings.ToLower(details.Email), details.Password, c.IP(), c.IPs())
+	user, token, err := ah.AuthService.Login(c.UserContext(), strings.ToLower(details.Email), details.Password, c.IP(), c.IPs())
 
 	if err != nil {
-		if err == bcrypt.ErrMismatchedHashAndPassword {
-			return c.Status(401).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
-		}
-		return c.Status(401).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("Authentication failure")})
+		return err
 	}
 
 	signedToken := make([]byte, 0, 100)
@@ -38,12 +34,12 @@ func (ah *AuthHandler) Login(c *fiber.Ctx) error {
 	t, err := auth.SignToken([]byte(token))
 
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return apperrors.Internal(err)
 	}
 
 	signedToken = append(signedToken, t...)
 
 	c.Set("Authorization", string(signedToken))
 
-	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "success", "data": user})
+	return respond(c, 200, user)
 }
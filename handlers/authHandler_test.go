@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("SECRET", "test-secret")
+	os.Setenv("EXPIRATION", "5")
+	os.Setenv("DB_NAME", "mongodb://")
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", ":27017")
+	os.Exit(m.Run())
+}
+
+// seededAuthService holds the admin main.go seeds, found by username like the auth repo
+type seededAuthService struct {
+	hash []byte
+}
+
+func (s seededAuthService) Login(_ context.Context, username string, password string, _ string, _ []string) (*domain.Admin, string, error) {
+	if username != "admin" || bcrypt.CompareHashAndPassword(s.hash, []byte(password)) != nil {
+		return nil, "", apperrors.Unauthorized("invalid_credentials", "invalid username or password")
+	}
+	admin := &domain.Admin{Username: "admin"}
+	token, err := domain.Authentication{}.GenerateJWT(*admin)
+	return admin, token, err
+}
+
+func TestLoginSeededAdmin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ah := &AuthHandler{AuthService: seededAuthService{hash: hash}}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/login", ah.Login)
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"seeded admin", `{"email": "admin", "password": "password"}`, 200},
+		{"username in capitals", `{"email": "Admin", "password": "password"}`, 200},
+		{"wrong password", `{"email": "admin", "password": "wrong"}`, 401},
+		{"missing username", `{"password": "password"}`, 422},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tc.status {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tc.status)
+			}
+			if tc.status == 200 && !strings.HasPrefix(res.Header.Get("Authorization"), "Bearer ") {
+				t.Fatalf("Authorization = %q, want a bearer token", res.Header.Get("Authorization"))
+			}
+		})
+	}
+}
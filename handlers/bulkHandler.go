@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"example.com/app/domain"
+	"example.com/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BulkHandler struct {
+	BulkService services.BulkService
+}
+
+// Start runs the bulk request in the background and returns the job to poll,
+// a dry run returns the affected resources straight away instead
+func (bh *BulkHandler) Start(c *fiber.Ctx) error {
+	c.Accepts("application/json")
+	request := new(domain.BulkRequest)
+	err := parseBody(c, request)
+
+	if err != nil {
+		return err
+	}
+
+	if request.DryRun {
+		targets, err := bh.BulkService.DryRun(c.UserContext(), request)
+
+		if err != nil {
+			return err
+		}
+
+		return respond(c, 200, fiber.Map{"total": len(*targets), "items": targets})
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	job, err := bh.BulkService.Start(c.UserContext(), request, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 202, job)
+}
+
+func (bh *BulkHandler) FindById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	job, err := bh.BulkService.FindById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, job)
+}
+
+func (bh *BulkHandler) Cancel(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	err = bh.BulkService.Cancel(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 202, "cancelling")
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"example.com/app/domain"
+	"example.com/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CaseHandler struct {
+	CaseService services.CaseService
+}
+
+func (ch *CaseHandler) Create(c *fiber.Ctx) error {
+	c.Accepts("application/json")
+	moderationCase := new(domain.Case)
+	err := parseBody(c, moderationCase)
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+	moderationCase.CreatedBy = admin.Username
+
+	err = ch.CaseService.Create(c.UserContext(), moderationCase)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 201, moderationCase)
+}
+
+func (ch *CaseHandler) FindAll(c *fiber.Ctx) error {
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+	status := c.Query("status")
+
+	cases, err := ch.CaseService.FindAll(c.UserContext(), page, status)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, cases)
+}
+
+// FindMine returns the open cases assigned to the logged in admin
+func (ch *CaseHandler) FindMine(c *fiber.Ctx) error {
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	cases, err := ch.CaseService.FindByAssignee(c.UserContext(), page, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, cases)
+}
+
+func (ch *CaseHandler) FindById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	moderationCase, err := ch.CaseService.FindById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, moderationCase)
+}
+
+func (ch *CaseHandler) UpdateById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	c.Accepts("application/json")
+	update := new(domain.CaseUpdate)
+	err = parseBody(c, update)
+
+	if err != nil {
+		return err
+	}
+
+	moderationCase, err := ch.CaseService.UpdateById(c.UserContext(), id, update)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, moderationCase)
+}
+
+func (ch *CaseHandler) DeleteById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	err = ch.CaseService.DeleteById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 204, "success")
+}
+
+func (ch *CaseHandler) Assign(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	c.Accepts("application/json")
+	assignment := new(domain.CaseAssignment)
+	err = parseBody(c, assignment)
+
+	if err != nil {
+		return err
+	}
+
+	moderationCase, err := ch.CaseService.Assign(c.UserContext(), id, assignment.AssigneeUsername)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, moderationCase)
+}
+
+func (ch *CaseHandler) UpdateStatus(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	c.Accepts("application/json")
+	status := new(domain.CaseStatusUpdate)
+	err = parseBody(c, status)
+
+	if err != nil {
+		return err
+	}
+
+	moderationCase, err := ch.CaseService.UpdateStatus(c.UserContext(), id, status.Status)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, moderationCase)
+}
+
+func (ch *CaseHandler) AddNote(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	c.Accepts("application/json")
+	note := new(domain.CaseNote)
+	err = parseBody(c, note)
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+	note.AuthorUsername = admin.Username
+
+	moderationCase, err := ch.CaseService.AddNote(c.UserContext(), id, note)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 201, moderationCase)
+}
+
+func (ch *CaseHandler) AddEvidence(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	c.Accepts("application/json")
+	evidence := new(domain.CaseEvidence)
+	err = parseBody(c, evidence)
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+	evidence.CapturedBy = admin.Username
+
+	moderationCase, err := ch.CaseService.AddEvidence(c.UserContext(), id, evidence)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 201, moderationCase)
+}
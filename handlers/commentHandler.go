@@ -1,10 +1,9 @@
 package handlers
 
 import (
+	"example.com/app/domain"
 	"example.com/app/services"
-	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CommentHandler struct {
@@ -12,18 +11,20 @@ type CommentHandler struct {
 }
 
 func (ch *CommentHandler) DeleteById(c *fiber.Ctx) error {
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	id, err := paramID(c, "id")
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	err = ch.CommentService.DeleteById(id)
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	result, err := ch.CommentService.DeleteById(c.UserContext(), id, admin.Username)
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	return c.Status(204).JSON(fiber.Map{"status": "success", "message": "success", "data": "success"})
+	return respond(c, 200, result)
 }
 
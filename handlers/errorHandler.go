@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"example.com/app/apperrors"
+	"example.com/app/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// ProblemContentType is the media type of an RFC 7807 problem details body
+const ProblemContentType = "application/problem+json"
+
+// ErrorHandler is the app's fiber error handler, it turns any error a handler or middleware returns into
+// a problem details body. Typed errors keep their status and code, anything else is a 500 that doesn't
+// leak its cause.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := apperrors.From(err)
+	status := problem.Status()
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		problem = &apperrors.Error{Code: codeOf(fe.Code), Message: fe.Message}
+		status = fe.Code
+	}
+
+	body := fiber.Map{}
+	for k, v := range problem.Extensions {
+		body[k] = v
+	}
+
+	body["type"] = "/problems/" + problem.Code
+	body["title"] = utils.StatusMessage(status)
+	body["status"] = status
+	body["detail"] = problem.Message
+	body["code"] = problem.Code
+	body["instance"] = c.OriginalURL()
+
+	if id := logger.RequestID(c.UserContext()); id != "" {
+		body["request_id"] = id
+	}
+
+	if len(problem.Fields) > 0 {
+		body["errors"] = problem.Fields
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	c.Status(status)
+	c.Set(fiber.HeaderContentType, ProblemContentType)
+
+	return c.Send(b)
+}
+
+// NotFound answers requests no route matched, it's registered last so unknown paths get a problem body too
+func NotFound(c *fiber.Ctx) error {
+	return apperrors.NotFound("route_not_found", "Cannot "+c.Method()+" "+c.Path())
+}
+
+// codeOf names the errors fiber raises itself, like a route that doesn't exist
+func codeOf(status int) string {
+	switch status {
+	case fiber.StatusNotFound:
+		return "route_not_found"
+	case fiber.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case fiber.StatusRequestEntityTooLarge:
+		return "body_too_large"
+	}
+	if status >= 500 {
+		return "internal_error"
+	}
+	return "bad_request"
+}
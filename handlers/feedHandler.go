@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/services"
+	"example.com/app/validation"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"time"
+)
+
+// feedHeartbeat keeps proxies from closing an idle stream and notices clients that went away
+const feedHeartbeat = 15 * time.Second
+
+type FeedHandler struct {
+	FeedService services.FeedService
+}
+
+// Stream sends the moderation feed as server-sent events. types and tags are comma separated filters, a
+// reconnecting EventSource sends Last-Event-ID by itself and picks up where it stopped. A reset event means
+// the stream couldn't resume and the client should reload what it shows.
+func (fh *FeedHandler) Stream(c *fiber.Ctx) error {
+	filter := domain.FeedFilter{Types: list(c.Query("types")), Tags: list(c.Query("tags"))}
+
+	if err := validation.Struct(filter); err != nil {
+		return err
+	}
+
+	sub, err := fh.FeedService.Subscribe(c.UserContext(), filter, c.Get("Last-Event-ID", c.Query("last_event_id")))
+
+	if err != nil {
+		return err
+	}
+
+	log := logger.FromContext(c.UserContext())
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// nginx buffers responses unless told not to
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		fmt.Fprintf(w, "retry: 3000\n\n")
+
+		if sub.Reset {
+			fmt.Fprintf(w, "event: reset\ndata: {\"reason\":\"the last event id is no longer in the feed\"}\n\n")
+		}
+
+		for _, e := range sub.Backlog {
+			writeFeedEvent(w, e)
+		}
+
+		if w.Flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(feedHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case e, ok := <-sub.Events:
+				if !ok {
+					log.Info("feed subscriber fell behind, closing its stream")
+					return
+				}
+				if !sub.Fresh(e) {
+					continue
+				}
+				writeFeedEvent(w, e)
+			case <-heartbeat.C:
+				fmt.Fprintf(w, ": heartbeat\n\n")
+			}
+
+			// a failed flush means the client is gone
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+func writeFeedEvent(w *bufio.Writer, e domain.FeedEvent) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.Id.Hex(), e.Type, data)
+}
+
+// list splits a comma separated query parameter, leaving out empty items
+func list(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
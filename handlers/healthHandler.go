@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"example.com/app/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthHandler struct {
+	Components []health.Component
+}
+
+// Live answers as long as the process is serving requests
+func (hh *HealthHandler) Live(c *fiber.Ctx) error {
+	return c.Status(200).JSON(fiber.Map{"status": "ok"})
+}
+
+// Ready checks every dependency and answers 503 when one of them is down or the service is shutting down
+func (hh *HealthHandler) Ready(c *fiber.Ctx) error {
+	report := health.Check(c.UserContext(), hh.Components)
+
+	if !report.Ready {
+		return c.Status(503).JSON(report)
+	}
+
+	return c.Status(200).JSON(report)
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"example.com/app/metrics"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics serves every metric in the Prometheus text format
+func Metrics(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return metrics.Write(c)
+}
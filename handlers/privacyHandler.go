@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"example.com/app/domain"
+	"example.com/app/services"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PrivacyHandler struct {
+	PrivacyService services.PrivacyService
+}
+
+func (ph *PrivacyHandler) Export(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	archive, err := ph.PrivacyService.Export(c.UserContext(), id, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"user-%s-export.zip\"", id.Hex()))
+
+	return c.Status(200).Send(archive)
+}
+
+func (ph *PrivacyHandler) RequestErasure(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	request, err := ph.PrivacyService.RequestErasure(c.UserContext(), id, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 202, request)
+}
+
+func (ph *PrivacyHandler) FindErasure(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	request, err := ph.PrivacyService.FindErasureById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, request)
+}
+
+func (ph *PrivacyHandler) CancelErasure(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	err = ph.PrivacyService.CancelErasure(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, "cancelled")
+}
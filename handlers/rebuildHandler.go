@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"example.com/app/domain"
+	"example.com/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type RebuildHandler struct {
+	RebuildService services.RebuildService
+}
+
+// Start begins rebuilding the users collection from kafka in the background and returns the job to poll
+func (rh *RebuildHandler) Start(c *fiber.Ctx) error {
+	c.Accepts("application/json")
+	request := new(domain.RebuildRequest)
+	err := parseBody(c, request)
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	job, err := rh.RebuildService.Start(c.UserContext(), request, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 202, job)
+}
+
+func (rh *RebuildHandler) FindById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	job, err := rh.RebuildService.FindById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, job)
+}
+
+func (rh *RebuildHandler) Cancel(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	err = rh.RebuildService.Cancel(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 202, "cancelling")
+}
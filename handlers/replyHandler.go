@@ -1,11 +1,10 @@
 package handlers
 
 import (
+	"example.com/app/apperrors"
 	"example.com/app/domain"
 	"example.com/app/services"
-	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ReplyHandler struct {
@@ -19,21 +18,21 @@ func (rh *ReplyHandler) DeleteById(c *fiber.Ctx) error {
 	u, loggedIn, err := auth.IsLoggedIn(token)
 
 	if err != nil || loggedIn == false {
-		return c.Status(401).JSON(fiber.Map{"status": "error", "message": "error...", "data": "Unauthorized user"})
+		return apperrors.Unauthorized("unauthorized", "unauthorized user").Wrap(err)
 	}
 
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	id, err := paramID(c, "id")
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	err = rh.ReplyService.DeleteById(id, u.Username)
+	result, err := rh.ReplyService.DeleteById(c.UserContext(), id, u.Username)
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	return c.Status(204).JSON(fiber.Map{"status": "success", "message": "success", "data": "success"})
+	return respond(c, 200, result)
 }
 
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"example.com/app/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// respond sends data in the envelope of the route's api version. v1 wraps it as {"status", "message", "data"},
+// v2 drops the status and message, which only ever said success, and sends no body with a 204.
+func respond(c *fiber.Ctx, status int, data interface{}) error {
+	if middleware.APIVersionOf(c) == "v1" {
+		return c.Status(status).JSON(fiber.Map{"status": "success", "message": "success", "data": data})
+	}
+
+	if status == fiber.StatusNoContent {
+		return c.SendStatus(status)
+	}
+
+	return c.Status(status).JSON(fiber.Map{"data": data})
+}
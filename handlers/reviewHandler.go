@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"example.com/app/domain"
+	"example.com/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReviewHandler struct {
+	ReviewService services.ReviewService
+}
+
+func (rh *ReviewHandler) FindAll(c *fiber.Ctx) error {
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+
+	items, err := rh.ReviewService.FindAll(c.UserContext(), page)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, items)
+}
+
+func (rh *ReviewHandler) Confirm(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	err = rh.ReviewService.Confirm(c.UserContext(), id, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, "success")
+}
+
+func (rh *ReviewHandler) Reverse(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	err = rh.ReviewService.Reverse(c.UserContext(), id, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, "success")
+}
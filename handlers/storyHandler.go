@@ -1,10 +1,10 @@
 package handlers
 
 import (
+	"example.com/app/apperrors"
+	"example.com/app/domain"
 	"example.com/app/services"
-	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"strconv"
 )
 
@@ -13,52 +13,76 @@ type StoryHandler struct {
 }
 
 func (s *StoryHandler) FindAll(c *fiber.Ctx) error {
-	page := c.Query("page", "1")
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
+
 	newStoriesQuery := c.Query("new", "false")
 
 	isNew, err := strconv.ParseBool(newStoriesQuery)
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("must provide a valid value")})
+		return apperrors.Validation("invalid_query", "must provide a valid value").WithField("new", "must be true or false")
 	}
 
-	stories, err := s.StoryService.FindAll(page, isNew)
+	stories, err := s.StoryService.FindAll(c.UserContext(), page, isNew)
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "success", "data": stories})
+	return respond(c, 200, stories)
+}
+
+// List is the v2 story list, newest first and paged by cursor instead of page number
+func (s *StoryHandler) List(c *fiber.Ctx) error {
+	q, err := cursorQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+	stories, err := s.StoryService.ListStories(c.UserContext(), q.Cursor, q.Limit)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, stories)
 }
 
 func (s *StoryHandler) FindStory(c *fiber.Ctx) error {
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	id, err := paramID(c, "id")
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	story, err := s.StoryService.FindById(id)
+	story, err := s.StoryService.FindById(c.UserContext(), id)
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "success", "data": &story})
+	return respond(c, 200, &story)
 }
 
 func (s *StoryHandler) DeleteStory(c *fiber.Ctx) error {
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	id, err := paramID(c, "id")
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	err = s.StoryService.DeleteById(id)
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	result, err := s.StoryService.DeleteById(c.UserContext(), id, admin.Username)
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	return c.Status(204).JSON(fiber.Map{"status": "success", "message": "success", "data": "success"})
+	return respond(c, 200, result)
 }
@@ -1,11 +1,10 @@
 package handlers
 
 import (
+	"example.com/app/apperrors"
+	"example.com/app/domain"
 	"example.com/app/services"
-	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type UserHandler struct {
@@ -13,31 +12,84 @@ type UserHandler struct {
 }
 
 func (uh *UserHandler) GetAllUsers(c *fiber.Ctx) error {
-	page := c.Query("page", "1")
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
 
-	users, err := uh.UserService.GetAllUsers(page, c.Context())
+
+	users, err := uh.UserService.GetAllUsers(c.UserContext(), page)
 
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "success", "data": users})
+	return respond(c, 200, users)
 }
 
-func (uh *UserHandler) DeleteByID(c *fiber.Ctx) error {
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+// List is the v2 user list, paged by cursor instead of page number
+func (uh *UserHandler) List(c *fiber.Ctx) error {
+	q, err := cursorQuery(c)
 
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
 	}
 
-	err = uh.UserService.DeleteByID(id)
+	users, err := uh.UserService.ListUsers(c.UserContext(), q.Cursor, q.Limit)
 
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+		return err
+	}
+
+	return respond(c, 200, users)
+}
+
+func (uh *UserHandler) DeleteByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	if c.Query("purge") == "true" {
+		admin := c.Locals("admin").(*domain.Authentication)
+
+		result, err := uh.UserService.PurgeByID(c.UserContext(), id, c.Query("mode", domain.PurgeDelete), admin.Username)
+
+		if err != nil {
+			if result == nil {
+				return err
+			}
+			// the purge stopped part way, report what was already removed
+			return apperrors.From(err).WithExtension("counts", result)
 		}
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("%v", err)})
+
+		return respond(c, 200, result)
 	}
-	return c.Status(204).JSON(fiber.Map{"status": "success", "message": "success", "data": "success"})
-}
\ No newline at end of file
+
+	err = uh.UserService.DeleteByID(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+	return respond(c, 204, "success")
+}
+
+func (uh *UserHandler) LockByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+
+	err = uh.UserService.LockByID(c.UserContext(), id, admin.Username)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, "success")
+}
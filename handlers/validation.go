@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"example.com/app/validation"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strconv"
+)
+
+// parseBody reads the request body into v and checks it against its validate tags
+func parseBody(c *fiber.Ctx, v interface{}) error {
+	if err := c.BodyParser(v); err != nil {
+		return apperrors.Validation("invalid_body", "request body can't be parsed").Wrap(err)
+	}
+	return validation.Struct(v)
+}
+
+// paramID reads an object id from the path
+func paramID(c *fiber.Ctx, name string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params(name))
+	if err != nil {
+		return id, apperrors.Validation("invalid_id", "id must be a 24 character hex object id").
+			WithField(name, "must be a 24 character hex object id")
+	}
+	return id, nil
+}
+
+// cursorQuery reads the cursor and limit of a v2 list, limit defaults to 20
+func cursorQuery(c *fiber.Ctx) (domain.CursorQuery, error) {
+	q := domain.CursorQuery{Cursor: c.Query("cursor")}
+
+	var err error
+	if q.Limit, err = strconv.Atoi(c.Query("limit", "20")); err != nil {
+		return q, apperrors.Validation("invalid_request", "request failed validation").WithField("limit", "must be a number")
+	}
+
+	return q, validation.Struct(q)
+}
+
+// pageQuery reads the page query parameter, the services take it as a string
+func pageQuery(c *fiber.Ctx) (string, error) {
+	n, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return "", apperrors.Validation("invalid_request", "request failed validation").WithField("page", "must be a number")
+	}
+
+	q := domain.PageQuery{Page: n}
+	if err = validation.Struct(q); err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(q.Page), nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"example.com/app/domain"
+	"example.com/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookHandler struct {
+	WebhookService services.WebhookService
+}
+
+func (wh *WebhookHandler) Create(c *fiber.Ctx) error {
+	webhook := new(domain.Webhook)
+	err := parseBody(c, webhook)
+
+	if err != nil {
+		return err
+	}
+
+	admin := c.Locals("admin").(*domain.Authentication)
+	webhook.CreatedBy = admin.Username
+
+	err = wh.WebhookService.Create(c.UserContext(), webhook)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 201, webhook)
+}
+
+func (wh *WebhookHandler) FindAll(c *fiber.Ctx) error {
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := wh.WebhookService.FindAll(c.UserContext(), page)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, webhooks)
+}
+
+func (wh *WebhookHandler) FindById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	webhook, err := wh.WebhookService.FindById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, webhook)
+}
+
+func (wh *WebhookHandler) DeleteById(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	err = wh.WebhookService.DeleteById(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 204, "success")
+}
+
+// FindDeliveries is the delivery log of a webhook, with the response code of every attempt
+func (wh *WebhookHandler) FindDeliveries(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	page, err := pageQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := wh.WebhookService.FindDeliveries(c.UserContext(), id, page)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 200, deliveries)
+}
+
+func (wh *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+
+	if err != nil {
+		return err
+	}
+
+	delivery, err := wh.WebhookService.Redeliver(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return respond(c, 202, delivery)
+}
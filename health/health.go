@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Component is a dependency the service needs to serve traffic
+type Component struct {
+	Name    string
+	Timeout time.Duration
+	Check   func(ctx context.Context) error
+}
+
+// ComponentStatus is the outcome of checking one component
+type ComponentStatus struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the readiness of the service and each of its components
+type Report struct {
+	Ready      bool                       `json:"ready"`
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+var shuttingDown int32
+
+// ShutDown marks the service as not ready so traffic drains before the server stops
+func ShutDown() {
+	atomic.StoreInt32(&shuttingDown, 1)
+}
+
+// Check runs every component check at once, each bounded by its own timeout
+func Check(ctx context.Context, components []Component) Report {
+	report := Report{Ready: true, Status: "ready", Components: map[string]ComponentStatus{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, component := range components {
+		wg.Add(1)
+		go func(component Component) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, component.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := component.Check(checkCtx)
+			status := ComponentStatus{Status: "up", Duration: time.Since(start).String()}
+
+			if err != nil {
+				status.Status = "down"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Components[component.Name] = status
+			if err != nil {
+				report.Ready = false
+			}
+			mu.Unlock()
+		}(component)
+	}
+
+	wg.Wait()
+
+	if atomic.LoadInt32(&shuttingDown) == 1 {
+		report.Ready = false
+		report.Status = "shutting down"
+	} else if !report.Ready {
+		report.Status = "not ready"
+	}
+
+	return report
+}
@@ -0,0 +1,135 @@
+// Package logger writes leveled JSON logs, one object per line. Fields that look
+// sensitive are redacted before they're written, however deep they're nested.
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = map[Level]string{Debug: "debug", Info: "info", Warn: "warn", Error: "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel reads a level name, anything it doesn't know is info
+func ParseLevel(name string) Level {
+	for level, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return level
+		}
+	}
+	return Info
+}
+
+// Logger writes entries carrying its fields, it's safe to share between goroutines
+type Logger struct {
+	fields []interface{}
+}
+
+var (
+	mu       sync.Mutex
+	out      io.Writer = os.Stdout
+	minLevel           = Info
+)
+
+// SetLevel drops entries below level
+func SetLevel(level Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	minLevel = level
+}
+
+// With returns a logger that adds the key value pairs to every entry
+func (l *Logger) With(keyValues ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyValues))
+	fields = append(fields, l.fields...)
+	return &Logger{fields: append(fields, keyValues...)}
+}
+
+func (l *Logger) Debug(msg string, keyValues ...interface{}) { l.log(Debug, msg, keyValues) }
+func (l *Logger) Info(msg string, keyValues ...interface{})  { l.log(Info, msg, keyValues) }
+func (l *Logger) Warn(msg string, keyValues ...interface{})  { l.log(Warn, msg, keyValues) }
+func (l *Logger) Error(msg string, keyValues ...interface{}) { l.log(Error, msg, keyValues) }
+
+// Fatal logs at error level and exits
+func (l *Logger) Fatal(msg string, keyValues ...interface{}) {
+	l.log(Error, msg, keyValues)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level Level, msg string, keyValues []interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if level < minLevel {
+		return
+	}
+
+	entry := map[string]interface{}{}
+	addFields(entry, l.fields)
+	addFields(entry, keyValues)
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{"level": "error", "msg": "unloggable entry", "error": err.Error()})
+	}
+
+	_, _ = out.Write(append(b, '\n'))
+}
+
+func addFields(entry map[string]interface{}, keyValues []interface{}) {
+	for i := 0; i < len(keyValues); i += 2 {
+		key := fmt.Sprint(keyValues[i])
+
+		if i+1 == len(keyValues) {
+			entry[key] = nil
+			break
+		}
+
+		entry[key] = redact(key, keyValues[i+1])
+	}
+}
+
+var root = &Logger{}
+
+// L is the logger with no request attached, for startup and background work
+func L() *Logger {
+	return root
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger of the request ctx belongs to, or the root logger
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return root
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitive are the parts of a field name that mean its value must not be logged
+var sensitive = []string{"password", "token", "secret", "authorization", "email"}
+
+// sensitiveExact are field names that would match too much as parts, ip addresses identify people too
+var sensitiveExact = map[string]bool{"ip": true, "ips": true, "lastloginip": true, "lastloginips": true}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+
+	if sensitiveExact[key] {
+		return true
+	}
+
+	for _, s := range sensitive {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact masks sensitive values, structs and maps go through JSON so their nested fields are checked too
+func redact(key string, value interface{}) interface{} {
+	if isSensitive(key) {
+		return redacted
+	}
+
+	switch v := value.(type) {
+	case nil, string, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+		return v
+	case error:
+		return v.Error()
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	var generic interface{}
+	if err = json.Unmarshal(b, &generic); err != nil {
+		return string(b)
+	}
+
+	return redactValue(generic)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if isSensitive(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
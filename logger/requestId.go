@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDHeader carries the request id on HTTP requests and kafka messages
+const RequestIDHeader = "X-Request-ID"
+
+type requestIdKey struct{}
+
+// NewRequestID returns a random id for a request that arrived without one
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context carrying the request id and a logger that adds it to every entry
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIdKey{}, id)
+	return NewContext(ctx, FromContext(ctx).With("request_id", id))
+}
+
+// RequestID returns the request id ctx carries, or an empty string
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
@@ -2,37 +2,43 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"example.com/app/config"
 	"example.com/app/database"
 	"example.com/app/domain"
 	"example.com/app/event-consumer"
+	"example.com/app/health"
+	"example.com/app/logger"
+	"example.com/app/migrations"
+	"example.com/app/repo"
 	"example.com/app/router"
+	"example.com/app/services"
+	"example.com/app/tracing"
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
-	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
-func init() {
-	// create database connection instance for first time
-	go event_consumer.KafkaConsumerGroup()
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
-
+// seedAdmin creates the default admin account the first time the service runs
+func seedAdmin(ctx context.Context, conn *database.Connection) {
 	adminSearch := new(domain.Admin)
-	err := conn.AdminCollection.FindOne(context.TODO(), bson.M{"username": "admin"}).Decode(adminSearch)
+	err := conn.AdminCollection.FindOne(ctx, bson.M{"username": "admin"}).Decode(adminSearch)
 
-	if err != nil  {
+	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			admin := domain.Admin{Username: "admin", Password: "password"}
 			admin.Id = primitive.NewObjectID()
 			hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
 			admin.Password = string(hashedPassword)
 
-			_, err := conn.AdminCollection.InsertOne(context.TODO(), &admin)
+			_, err := conn.AdminCollection.InsertOne(ctx, &admin)
 
 			if err != nil {
 				panic("error processing data")
@@ -43,20 +49,144 @@ func init() {
 	}
 }
 
+// migrate runs the migrate subcommand: migrate [up | down [steps] | status]
+func migrate(conn *database.Connection, args []string) error {
+	runner := migrations.NewRunner(conn)
+	ctx := context.Background()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return runner.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+			steps = n
+		}
+		return runner.Down(ctx, steps)
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-30s %s\n", s.Version, s.Description, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
+
 func main() {
-	app := router.Setup()
+	// a missing or invalid setting stops the service before it connects to anything
+	cfg, err := config.Load()
+
+	if err != nil {
+		logger.L().Fatal("invalid configuration", "error", err)
+	}
+
+	logger.SetLevel(logger.ParseLevel(cfg.LogLevel))
+
+	if err = tracing.Init(cfg.TracingExporter, cfg.TracingEndpoint, cfg.TracingServiceName, cfg.TracingSampleRatio); err != nil {
+		logger.L().Fatal("tracing setup failed", "error", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		fmt.Println(cfg)
+		return
+	}
+
+	// openapi prints the api document, the router tests check it against the routes
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		b, _ := json.MarshalIndent(router.Spec(), "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+
+	conn, err := database.ConnectToDB()
+
+	if err != nil {
+		logger.L().Fatal("connection to DB failed", "error", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = migrate(conn, os.Args[2:])
+		_ = conn.Close(context.Background())
+
+		if err != nil {
+			logger.L().Fatal("migrate failed", "error", err)
+		}
+		return
+	}
+
+	// migrations run on every start unless MIGRATE_ON_START is false, then use the migrate subcommand
+	if cfg.MigrateOnStart {
+		if err = migrations.NewRunner(conn).Up(context.Background()); err != nil {
+			logger.L().Fatal("migrations failed", "error", err)
+		}
+	}
+
+	// background workers stop once the server has shut down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+
+	seedAdmin(workerCtx, conn)
+
+	go conn.MonitorHealth(workerCtx)
+
+	go event_consumer.KafkaConsumerGroup(conn)
+
+	app := router.Setup(conn)
+
+	// carry out erasure requests once their cooling off period is over
+	go services.NewPrivacyService(repo.NewPrivacyRepoImpl(conn)).RunErasures(workerCtx, time.Minute)
+
+	// send the kafka messages waiting in the outbox
+	go services.NewOutboxService(repo.NewOutboxRepoImpl(conn)).RunRelay(workerCtx, time.Second)
+
+	// send webhook deliveries and their retries
+	go services.NewWebhookService(repo.NewWebhookRepoImpl(conn)).RunDeliveries(workerCtx, 5*time.Second)
+
+	// hold off the consumer while a rebuild on another instance swaps the users collection
+	go services.NewRebuildService(repo.NewRebuildRepoImpl(conn)).HoldConsumer(workerCtx, time.Second)
 
 	// graceful shutdown on signal interrupts
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		_ = <-c
-		fmt.Println("Shutting down...")
+		logger.L().Info("shutting down")
+		// report not ready and give the orchestrator time to stop sending traffic
+		health.ShutDown()
+		time.Sleep(time.Duration(cfg.ShutdownDrain) * time.Second)
 		_ = app.Shutdown()
 	}()
 
-	if err := app.Listen(":8084"); err != nil {
-		log.Panic(err)
+	if err := app.Listen(":" + cfg.Port); err != nil {
+		logger.L().Fatal("server stopped", "error", err)
 	}
-}
\ No newline at end of file
+
+	stopWorkers()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := conn.Close(ctx); err != nil {
+		logger.L().Error("error disconnecting from DB", "error", err)
+	}
+
+	tracing.Shutdown(ctx)
+}
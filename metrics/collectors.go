@@ -0,0 +1,35 @@
+package metrics
+
+var (
+	HTTPRequests = NewCounterVec("http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	HTTPDuration = NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency by method, route and status.", DefaultBuckets, "method", "route", "status")
+
+	MongoDuration = NewHistogramVec("mongo_operation_duration_seconds",
+		"Mongo command duration by collection, operation and result.", DefaultBuckets, "collection", "operation", "result")
+
+	KafkaProduced = NewCounterVec("kafka_produced_messages_total",
+		"Messages sent to kafka by topic and result.", "topic", "result")
+	KafkaConsumerLag = NewGaugeVec("kafka_consumer_lag",
+		"Messages between the last one processed and the end of the partition.", "topic", "partition")
+	KafkaProcessing = NewHistogramVec("kafka_message_processing_seconds",
+		"Time to process a consumed message by message type and result.", DefaultBuckets, "type", "result")
+
+	ModerationActions = NewCounterVec("moderation_actions_total",
+		"Moderation actions taken by action and resource type.", "action", "resource_type")
+
+	RateLimitDecisions = NewCounterVec("rate_limit_decisions_total",
+		"Rate limit decisions by budget and result, allowed, limited or error.", "budget", "result")
+
+	WebhookAttempts = NewCounterVec("webhook_delivery_attempts_total",
+		"Webhook delivery attempts by result, succeeded, retrying or failed.", "result")
+)
+
+// Result labels an outcome by its error
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
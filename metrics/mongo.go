@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/event"
+	"sync"
+	"time"
+)
+
+type startedCommand struct {
+	collection string
+	operation  string
+}
+
+// MongoMonitor times every command the driver sends, by the collection it targets
+func MongoMonitor() *event.CommandMonitor {
+	var started sync.Map
+
+	finished := func(requestId int64, duration int64, result string) {
+		v, ok := started.LoadAndDelete(requestId)
+		if !ok {
+			return
+		}
+		c := v.(startedCommand)
+		MongoDuration.Observe(time.Duration(duration).Seconds(), c.collection, c.operation, result)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			collection, ok := e.Command.Lookup(e.CommandName).StringValueOK()
+			if !ok {
+				// getMore names the collection in its own field, commands like ping have none
+				if collection, ok = e.Command.Lookup("collection").StringValueOK(); !ok {
+					collection = "none"
+				}
+			}
+			started.Store(e.RequestID, startedCommand{collection, e.CommandName})
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.RequestID, e.DurationNanos, "success")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.RequestID, e.DurationNanos, "failure")
+		},
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersion records which version of the api a route group belongs to, handlers shape their responses by it
+func APIVersion(version string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("apiVersion", version)
+		return c.Next()
+	}
+}
+
+// APIVersionOf is the version of the route handling the request, routes outside a versioned group answer as v1
+func APIVersionOf(c *fiber.Ctx) string {
+	if version, ok := c.Locals("apiVersion").(string); ok {
+		return version
+	}
+	return "v1"
+}
+
+// Deprecated marks every response of a route group that's going away. Deprecation holds when it was
+// deprecated (RFC 9745), Sunset when it stops working (RFC 8594) and Link the same route under its successor,
+// the path with from replaced by to.
+func Deprecated(deprecation, sunset time.Time, from, to string) fiber.Handler {
+	deprecationHeader := "@" + strconv.FormatInt(deprecation.Unix(), 10)
+	sunsetHeader := sunset.UTC().Format(http.TimeFormat)
+
+	return func(c *fiber.Ctx) error {
+		c.Set("Deprecation", deprecationHeader)
+		c.Set("Sunset", sunsetHeader)
+		c.Set(fiber.HeaderLink, "<"+strings.Replace(c.Path(), from, to, 1)+`>; rel="successor-version"`)
+		return c.Next()
+	}
+}
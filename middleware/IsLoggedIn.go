@@ -1,8 +1,8 @@
 package middleware
 
 import (
+	"example.com/app/apperrors"
 	"example.com/app/domain"
-	"fmt"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -10,17 +10,14 @@ func IsLoggedIn(c *fiber.Ctx) error {
 	token := c.Get("Authorization")
 
 	var auth domain.Authentication
-	_, loggedIn, err := auth.IsLoggedIn(token)
+	u, loggedIn, err := auth.IsLoggedIn(token)
 
 	if err != nil || loggedIn == false {
-		return c.Status(401).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("Unauthorized user")})
+		return apperrors.Unauthorized("unauthorized", "unauthorized user").Wrap(err)
 	}
 
-	err = c.Next()
+	// handlers read the logged in admin from here
+	c.Locals("admin", u)
 
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"status": "error", "message": "error...", "data": fmt.Sprintf("Unauthorized user")})
-	}
-
-	return nil
+	return c.Next()
 }
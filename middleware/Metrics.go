@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"example.com/app/metrics"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+// Metrics counts and times each request by its route pattern, so ids in the path don't make new series
+func Metrics(c *fiber.Ctx) error {
+	start := time.Now()
+
+	err := c.Next()
+
+	status := responseStatus(c, err)
+
+	method := c.Method()
+	route := c.Route().Path
+	code := strconv.Itoa(status)
+
+	metrics.HTTPRequests.Inc(method, route, code)
+	metrics.HTTPDuration.Observe(time.Since(start).Seconds(), method, route, code)
+
+	return err
+}
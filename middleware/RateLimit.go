@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/metrics"
+	"example.com/app/ratelimit"
+	"github.com/gofiber/fiber/v2"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RateLimit takes a token from the caller's bucket for the budget and refuses the request with a 429 when it's
+// empty. Every response carries the RateLimit-* headers of the draft IETF standard. Put it after IsLoggedIn so
+// an admin is limited by who they are rather than where they connect from. When the store fails the request
+// is let through, a slow limiter shouldn't take the api down with it.
+func RateLimit(store ratelimit.Store, budget string, limit ratelimit.Limit) fiber.Handler {
+	policy := limit.Policy()
+
+	return func(c *fiber.Ctx) error {
+		r, err := store.Take(c.UserContext(), budget+":"+principal(c), limit, time.Now())
+
+		if err != nil {
+			metrics.RateLimitDecisions.Inc(budget, "error")
+			logger.FromContext(c.UserContext()).Warn("rate limit store failed, allowing the request", "budget", budget, "error", err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(r.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+		c.Set("RateLimit-Reset", ceilSeconds(r.Reset))
+		c.Set("RateLimit-Policy", policy)
+
+		if !r.Allowed {
+			metrics.RateLimitDecisions.Inc(budget, "limited")
+			c.Set(fiber.HeaderRetryAfter, ceilSeconds(r.RetryAfter))
+			return apperrors.RateLimited("rate_limited", "too many requests, slow down").
+				WithExtension("budget", budget).
+				WithExtension("retry_after", math.Ceil(r.RetryAfter.Seconds()))
+		}
+
+		metrics.RateLimitDecisions.Inc(budget, "allowed")
+		return c.Next()
+	}
+}
+
+// principal is who the request is counted against: the admin IsLoggedIn authenticated, otherwise the client's
+// address. Nothing the client sends unchecked counts, or a new value with every request would get a new bucket.
+// That's why there's no bucket per API key: this service issues none, so an X-API-Key header can't be checked
+// against anything and rotating it would get around the limit. Key on it here once keys are issued and verified.
+func principal(c *fiber.Ctx) string {
+	if admin, ok := c.Locals("admin").(*domain.Authentication); ok && admin != nil {
+		return "admin:" + admin.Id.Hex()
+	}
+
+	return "ip:" + c.IP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
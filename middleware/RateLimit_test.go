@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"example.com/app/ratelimit"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// limitedApp answers 200 behind the rate limit, as admin when one is given, and turns errors into their status
+func limitedApp(store ratelimit.Store, limit ratelimit.Limit, admin *domain.Authentication) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		return c.SendStatus(apperrors.From(err).Status())
+	}})
+	app.Use(func(c *fiber.Ctx) error {
+		if admin != nil {
+			c.Locals("admin", admin)
+		}
+		return c.Next()
+	})
+	app.Post("/login", RateLimit(store, "login", limit), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+	return app
+}
+
+func TestRateLimitIgnoresRotatedAPIKey(t *testing.T) {
+	app := limitedApp(ratelimit.NewMemoryStore(), ratelimit.Limit{Burst: 3, Per: time.Minute}, nil)
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.Header.Set("X-API-Key", fmt.Sprintf("random-%d", i))
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := 200
+		if i >= 3 {
+			want = fiber.StatusTooManyRequests
+		}
+		if res.StatusCode != want {
+			t.Fatalf("request %d: status = %d, want %d", i+1, res.StatusCode, want)
+		}
+	}
+}
+
+func TestRateLimitPerAdmin(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Burst: 1, Per: time.Minute}
+
+	for _, admin := range []*domain.Authentication{{Id: primitive.NewObjectID()}, {Id: primitive.NewObjectID()}} {
+		res, err := limitedApp(store, limit, admin).Test(httptest.NewRequest("POST", "/login", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != 200 {
+			t.Fatalf("admin %s: status = %d, want 200 from their own bucket", admin.Id.Hex(), res.StatusCode)
+		}
+	}
+}
+
+func TestRateLimitPrincipalOrder(t *testing.T) {
+	admin := &domain.Authentication{Id: primitive.NewObjectID()}
+
+	cases := []struct {
+		name   string
+		admin  *domain.Authentication
+		apiKey string
+		want   string
+	}{
+		{"admin", admin, "", "admin:" + admin.Id.Hex()},
+		{"admin with an API key", admin, "some-key", "admin:" + admin.Id.Hex()},
+		{"API key", nil, "some-key", "ip:0.0.0.0"},
+		{"address", nil, "", "ip:0.0.0.0"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				if tc.admin != nil {
+					c.Locals("admin", tc.admin)
+				}
+				got = principal(c)
+				return c.SendStatus(200)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-Key", tc.apiKey)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tc.want {
+				t.Fatalf("principal = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
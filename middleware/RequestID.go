@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"example.com/app/logger"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// RequestID gives every request an id, reusing the caller's X-Request-ID, and puts a logger carrying it
+// on the request context so repo and kafka logs can be tied back to the request. It logs each request once it's done.
+func RequestID(c *fiber.Ctx) error {
+	id := c.Get(logger.RequestIDHeader)
+	if id == "" || len(id) > 128 {
+		id = logger.NewRequestID()
+	}
+
+	c.Set(logger.RequestIDHeader, id)
+	c.SetUserContext(logger.WithRequestID(c.UserContext(), id))
+
+	start := time.Now()
+	err := c.Next()
+
+	status := responseStatus(c, err)
+
+	log := logger.FromContext(c.UserContext())
+	fields := []interface{}{"method", c.Method(), "path", c.Path(), "status", status, "duration_ms", time.Since(start).Milliseconds()}
+
+	if status >= 500 {
+		log.Error("request failed", append(fields, "error", err)...)
+	} else if err != nil {
+		log.Info("request", append(fields, "error", err)...)
+	} else {
+		log.Info("request", fields...)
+	}
+
+	return err
+}
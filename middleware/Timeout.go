@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/config"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// Timeout puts a deadline on the request context the handlers pass down to the database,
+// REQUEST_TIMEOUT is in seconds. A request that failed because the deadline passed gets a 504.
+func Timeout(c *fiber.Ctx) error {
+	timeout := time.Duration(config.Get().RequestTimeout) * time.Second
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+	defer cancel()
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	if ctx.Err() == context.DeadlineExceeded && (err != nil || c.Response().StatusCode() >= 400) {
+		return apperrors.Timeout("timeout", "request timed out").Wrap(err)
+	}
+
+	return err
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"example.com/app/tracing"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Tracing starts a server span for each request, continuing the caller's trace when it sends a traceparent header
+func Tracing(c *fiber.Ctx) error {
+	ctx := tracing.Extract(c.UserContext(), func(key string) string { return c.Get(key) })
+	ctx, span := tracing.Start(ctx, c.Method()+" "+c.Path(), tracing.KindServer,
+		"http.method", c.Method(), "http.target", c.OriginalURL())
+	defer span.End()
+
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	status := responseStatus(c, err)
+
+	// the route is only known once the router has matched it, naming the span by it keeps ids out of the name
+	span.SetName(c.Method() + " " + c.Route().Path)
+	span.SetAttribute("http.route", c.Route().Path)
+	span.SetAttribute("http.status_code", status)
+
+	// client errors aren't failures of the service, only a 5xx marks the span
+	if status >= 500 {
+		if err != nil {
+			span.RecordError(err)
+		} else {
+			span.RecordError(fmt.Errorf("%s", utils.StatusMessage(status)))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"errors"
+	"example.com/app/apperrors"
+	"github.com/gofiber/fiber/v2"
+)
+
+// responseStatus is the status the request ends with. The error handler writes the response after the
+// middleware has returned, so a failed request's status comes from its error rather than the response.
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code
+	}
+
+	return apperrors.From(err).Status()
+}
@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// hideableCollections are the collections that gained a hidden field with flag thresholds
+var hideableCollections = []string{"stories", "comments", "replies", "users"}
+
+// backfillHiddenUp gives documents written before auto-hide existed an explicit hidden field
+func backfillHiddenUp(ctx context.Context, db *mongo.Database) error {
+	for _, name := range hideableCollections {
+		_, err := db.Collection(name).UpdateMany(ctx, bson.M{"hidden": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"hidden": false}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillHiddenDown only removes the defaults, anything that was actually hidden stays hidden
+func backfillHiddenDown(ctx context.Context, db *mongo.Database) error {
+	for _, name := range hideableCollections {
+		_, err := db.Collection(name).UpdateMany(ctx, bson.M{"hidden": false},
+			bson.M{"$unset": bson.M{"hidden": ""}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePendingReviews keeps the oldest of the pending review items of a resource with the highest flag count, every
+// reason and the auto-hide of any of them, and removes the rest
+func mergePendingReviews(ctx context.Context, db *mongo.Database) error {
+	reviews := db.Collection("review_queue")
+
+	cur, err := reviews.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"status": "pending"}},
+		bson.M{"$sort": bson.M{"createdAt": 1}},
+		bson.M{"$group": bson.M{
+			"_id":        "$resourceId",
+			"ids":        bson.M{"$push": "$_id"},
+			"reasons":    bson.M{"$push": "$reasons"},
+			"flagCount":  bson.M{"$max": "$flagCount"},
+			"autoHidden": bson.M{"$max": "$autoHidden"},
+		}},
+		bson.M{"$match": bson.M{"ids.1": bson.M{"$exists": true}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var groups []struct {
+		Ids        []interface{} `bson:"ids"`
+		Reasons    [][]string    `bson:"reasons"`
+		FlagCount  int           `bson:"flagCount"`
+		AutoHidden bool          `bson:"autoHidden"`
+	}
+	if err = cur.All(ctx, &groups); err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		reasons := []string{}
+		seen := map[string]bool{}
+		for _, rs := range g.Reasons {
+			for _, r := range rs {
+				if !seen[r] {
+					seen[r] = true
+					reasons = append(reasons, r)
+				}
+			}
+		}
+
+		_, err = reviews.UpdateOne(ctx, bson.M{"_id": g.Ids[0]}, bson.M{"$set": bson.M{
+			"flagCount": g.FlagCount, "autoHidden": g.AutoHidden, "reasons": reasons}})
+		if err != nil {
+			return err
+		}
+
+		if _, err = reviews.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": g.Ids[1:]}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
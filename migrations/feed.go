@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// feedSize bounds the moderation feed, once it's full the oldest events are overwritten. A client that was
+// away long enough for its last event to go is told to reload instead of resuming.
+const feedSize = 64 << 20
+
+// feedCollectionUp creates the feed as a capped collection, only those can be tailed
+func feedCollectionUp(ctx context.Context, db *mongo.Database) error {
+	err := db.CreateCollection(ctx, "feed_events", options.CreateCollection().SetCapped(true).SetSizeInBytes(feedSize))
+
+	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Name == "NamespaceExists" {
+		return nil
+	}
+	return err
+}
+
+func feedCollectionDown(ctx context.Context, db *mongo.Database) error {
+	return db.Collection("feed_events").Drop(ctx)
+}
@@ -0,0 +1,210 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// index is a named index so the down step can drop exactly what the up step created
+type index struct {
+	collection string
+	name       string
+	keys       bson.D
+	unique     bool
+	partial    bson.M
+	// expireAfter makes a TTL index, documents go once the indexed date is this many seconds old
+	expireAfter *int32
+}
+
+func asc(keys ...string) bson.D {
+	d := bson.D{}
+	for _, k := range keys {
+		d = append(d, bson.E{Key: k, Value: 1})
+	}
+	return d
+}
+
+var userIndexes = []index{
+	{collection: "users", name: "username_unique", keys: asc("username"), unique: true},
+	// erased users have no email, so only documents that still have one need to be unique
+	{collection: "users", name: "email_unique", keys: asc("email"), unique: true,
+		partial: bson.M{"email": bson.M{"$exists": true}}},
+	{collection: "admin", name: "username_unique", keys: asc("username"), unique: true},
+}
+
+var contentIndexes = []index{
+	{collection: "stories", name: "authorUsername", keys: asc("authorUsername")},
+	{collection: "stories", name: "createdAt", keys: bson.D{{Key: "createdAt", Value: -1}}},
+	{collection: "comments", name: "resourceId", keys: asc("resourceId")},
+	{collection: "comments", name: "authorUsername", keys: asc("authorUsername")},
+	{collection: "replies", name: "resourceId", keys: asc("resourceId")},
+	{collection: "replies", name: "authorUsername", keys: asc("authorUsername")},
+	{collection: "flags", name: "flaggedResource", keys: asc("flaggedResource")},
+	// one flag per flagger per resource is what the flag thresholds count
+	{collection: "flags", name: "flaggerID_flaggedResource_unique", keys: asc("flaggerID", "flaggedResource"), unique: true},
+}
+
+var moderationIndexes = []index{
+	{collection: "review_queue", name: "resourceId_status", keys: asc("resourceId", "status")},
+	{collection: "review_queue", name: "queue_order", keys: bson.D{
+		{Key: "status", Value: 1}, {Key: "autoHidden", Value: -1}, {Key: "flagCount", Value: -1}, {Key: "createdAt", Value: 1},
+	}},
+	{collection: "moderation_actions", name: "targetUsername", keys: asc("targetUsername")},
+	{collection: "moderation_actions", name: "resourceId", keys: asc("resourceId")},
+	{collection: "appeals", name: "actionId_unique", keys: asc("actionId"), unique: true},
+	{collection: "appeals", name: "status_createdAt", keys: asc("status", "createdAt")},
+	{collection: "cases", name: "assigneeUsername_status", keys: asc("assigneeUsername", "status")},
+	{collection: "erasure_requests", name: "status_scheduledFor", keys: asc("status", "scheduledFor")},
+}
+
+// a resource has one pending review item however many flags arrive at once
+var pendingReviewIndexes = []index{
+	{collection: "review_queue", name: "resourceId_pending_unique", keys: asc("resourceId"), unique: true,
+		partial: bson.M{"status": "pending"}},
+}
+
+// expiresAt holds when a rate limit bucket would be full again, it's no use after that
+var rateLimitIndexes = []index{
+	{collection: "rate_limits", name: "expiresAt_ttl", keys: asc("expiresAt"), expireAfter: new(int32)},
+}
+
+var webhookIndexes = []index{
+	{collection: "webhooks", name: "active", keys: asc("active")},
+	{collection: "webhook_deliveries", name: "status_nextAttemptAt", keys: asc("status", "nextAttemptAt")},
+	{collection: "webhook_deliveries", name: "webhookId_createdAt", keys: asc("webhookId", "createdAt")},
+	// an event is delivered to a webhook once however often it's queued
+	{collection: "webhook_deliveries", name: "webhookId_eventId_unique", keys: asc("webhookId", "event._id"), unique: true},
+}
+
+// sent entries are kept for a week to look into what was sent, pending ones have no sentAt and stay
+var outboxIndexes = []index{
+	{collection: "outbox", name: "status_nextAttemptAt", keys: asc("status", "nextAttemptAt", "_id")},
+	{collection: "outbox", name: "sentAt_ttl", keys: asc("sentAt"), expireAfter: &outboxRetention},
+}
+
+var outboxRetention int32 = 7 * 24 * 60 * 60
+
+// the consumer skips a message whose id it has recorded, the _id keeps the ids unique. The relay can only send
+// a message again while its outbox entry is kept, so the records go with it.
+var processedIndexes = []index{
+	{collection: "processed_messages", name: "processedAt_ttl", keys: asc("processedAt"), expireAfter: &outboxRetention},
+}
+
+// one rebuild runs at a time across every instance
+var rebuildIndexes = []index{
+	{collection: "rebuild_jobs", name: "status_running_unique", keys: asc("status"), unique: true,
+		partial: bson.M{"status": "running"}},
+}
+
+func createIndexes(ctx context.Context, db *mongo.Database, indexes []index) error {
+	for _, i := range indexes {
+		opts := options.Index().SetName(i.name)
+		if i.unique {
+			opts.SetUnique(true)
+		}
+		if i.partial != nil {
+			opts.SetPartialFilterExpression(i.partial)
+		}
+		if i.expireAfter != nil {
+			opts.SetExpireAfterSeconds(*i.expireAfter)
+		}
+
+		_, err := db.Collection(i.collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: i.keys, Options: opts})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(ctx context.Context, db *mongo.Database, indexes []index) error {
+	for _, i := range indexes {
+		_, err := db.Collection(i.collection).Indexes().DropOne(ctx, i.name)
+		if err != nil {
+			// the index may never have been created if the up step failed half way
+			if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Name == "IndexNotFound" {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func userIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, userIndexes)
+}
+
+func userIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, userIndexes)
+}
+
+func contentIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, contentIndexes)
+}
+
+func contentIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, contentIndexes)
+}
+
+func moderationIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, moderationIndexes)
+}
+
+func moderationIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, moderationIndexes)
+}
+
+func rateLimitIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, rateLimitIndexes)
+}
+
+func rateLimitIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, rateLimitIndexes)
+}
+
+func webhookIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, webhookIndexes)
+}
+
+func webhookIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, webhookIndexes)
+}
+
+func outboxIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, outboxIndexes)
+}
+
+func outboxIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, outboxIndexes)
+}
+
+func rebuildIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, rebuildIndexes)
+}
+
+func rebuildIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, rebuildIndexes)
+}
+
+func processedIndexesUp(ctx context.Context, db *mongo.Database) error {
+	return createIndexes(ctx, db, processedIndexes)
+}
+
+func processedIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, processedIndexes)
+}
+
+// pendingReviewIndexesUp merges the pending items queued twice for one resource before the index forbids it
+func pendingReviewIndexesUp(ctx context.Context, db *mongo.Database) error {
+	if err := mergePendingReviews(ctx, db); err != nil {
+		return err
+	}
+	return createIndexes(ctx, db, pendingReviewIndexes)
+}
+
+func pendingReviewIndexesDown(ctx context.Context, db *mongo.Database) error {
+	return dropIndexes(ctx, db, pendingReviewIndexes)
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration is one versioned change to the schema or the data. Down undoes Up so a deploy can be rolled back.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// all is every migration in the order they are applied, versions must keep increasing
+var all = []Migration{
+	{1, "user indexes", userIndexesUp, userIndexesDown},
+	{2, "content and flag indexes", contentIndexesUp, contentIndexesDown},
+	{3, "moderation indexes", moderationIndexesUp, moderationIndexesDown},
+	{4, "backfill hidden flag", backfillHiddenUp, backfillHiddenDown},
+	{5, "rate limit expiry index", rateLimitIndexesUp, rateLimitIndexesDown},
+	{6, "capped moderation feed", feedCollectionUp, feedCollectionDown},
+	{7, "webhook indexes", webhookIndexesUp, webhookIndexesDown},
+	{8, "outbox indexes", outboxIndexesUp, outboxIndexesDown},
+	{9, "one running rebuild", rebuildIndexesUp, rebuildIndexesDown},
+	{10, "processed message expiry", processedIndexesUp, processedIndexesDown},
+	{11, "one pending review per resource", pendingReviewIndexesUp, pendingReviewIndexesDown},
+}
@@ -0,0 +1,235 @@
+package migrations
+
+import (
+	"context"
+	"example.com/app/database"
+	"example.com/app/logger"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	// lockLease is how long a migration lock is honoured without being refreshed before it's considered left behind
+	// by a crashed instance, the holder refreshes it every third of that
+	lockLease = time.Minute
+	// lockRetry is how often an instance waiting for the lock tries to take it
+	lockRetry = 2 * time.Second
+)
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Version     int       `bson:"_id" json:"version"`
+	Description string    `bson:"description" json:"description"`
+	Applied     bool      `bson:"-" json:"applied"`
+	AppliedAt   time.Time `bson:"appliedAt" json:"appliedAt"`
+}
+
+// Runner applies migrations and records them in the schema_migrations collection
+type Runner struct {
+	conn       *database.Connection
+	applied    *mongo.Collection
+	lock       *mongo.Collection
+	migrations []Migration
+}
+
+func NewRunner(conn *database.Connection) *Runner {
+	return &Runner{
+		conn:       conn,
+		applied:    conn.Database.Collection("schema_migrations"),
+		lock:       conn.Database.Collection("schema_migrations_lock"),
+		migrations: all,
+	}
+}
+
+// Up applies every migration that hasn't been applied yet, in version order
+func (r *Runner) Up(ctx context.Context) error {
+	return r.withLock(ctx, func() error {
+		applied, err := r.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range r.migrations {
+			if applied[m.Version] {
+				continue
+			}
+
+			logger.FromContext(ctx).Info("applying migration", "version", m.Version, "description", m.Description)
+
+			if err := m.Up(ctx, r.conn.Database); err != nil {
+				return fmt.Errorf("migration %d failed: %v", m.Version, err)
+			}
+
+			_, err := r.applied.InsertOne(ctx, Status{Version: m.Version, Description: m.Description, AppliedAt: time.Now()})
+			if err != nil {
+				return fmt.Errorf("recording migration %d failed: %v", m.Version, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, newest first
+func (r *Runner) Down(ctx context.Context, steps int) error {
+	return r.withLock(ctx, func() error {
+		applied, err := r.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(r.migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := r.migrations[i]
+			if !applied[m.Version] {
+				continue
+			}
+
+			logger.FromContext(ctx).Info("reverting migration", "version", m.Version, "description", m.Description)
+
+			if err := m.Down(ctx, r.conn.Database); err != nil {
+				return fmt.Errorf("reverting migration %d failed: %v", m.Version, err)
+			}
+
+			_, err := r.applied.DeleteOne(ctx, bson.M{"_id": m.Version})
+			if err != nil {
+				return fmt.Errorf("recording revert of migration %d failed: %v", m.Version, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration and when it was applied
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var records []Status
+
+	cur, err := r.applied.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	appliedAt := map[int]time.Time{}
+	for _, rec := range records {
+		appliedAt[rec.Version] = rec.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		at, ok := appliedAt[m.Version]
+		statuses = append(statuses, Status{Version: m.Version, Description: m.Description, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+func (r *Runner) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int]bool{}
+	for _, s := range statuses {
+		applied[s.Version] = s.Applied
+	}
+	return applied, nil
+}
+
+// withLock runs fn while holding the migration lock so instances starting together don't migrate twice. An
+// instance that finds the lock held waits for it, fn then sees what the holder already applied.
+func (r *Runner) withLock(ctx context.Context, fn func() error) error {
+	owner := primitive.NewObjectID().Hex()
+	waiting := false
+
+	for {
+		taken, err := r.takeLock(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if taken {
+			break
+		}
+
+		if !waiting {
+			logger.FromContext(ctx).Info("waiting for migrations running in another instance")
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+
+	refreshed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		r.refreshLock(ctx, owner, done)
+	}()
+
+	defer func() {
+		close(done)
+		<-refreshed
+		_, _ = r.lock.DeleteOne(context.Background(), bson.M{"_id": "lock", "owner": owner})
+	}()
+
+	return fn()
+}
+
+// takeLock takes the lock if nobody holds it or the holder's lease ran out, false means it's held
+func (r *Runner) takeLock(ctx context.Context, owner string) (bool, error) {
+	now := time.Now()
+
+	opts := options.Update().SetUpsert(true)
+	filter := bson.M{"_id": "lock", "$or": bson.A{
+		bson.M{"expiresAt": bson.M{"$lt": now}},
+		// locks from before leases only have the time they were taken
+		bson.M{"expiresAt": bson.M{"$exists": false}, "lockedAt": bson.M{"$lt": now.Add(-10 * lockLease)}},
+	}}
+	update := bson.M{"$set": bson.M{"owner": owner, "lockedAt": now, "expiresAt": now.Add(lockLease)}}
+
+	_, err := r.lock.UpdateOne(ctx, filter, update, opts)
+
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// refreshLock extends the lease of the lock until done is closed
+func (r *Runner) refreshLock(ctx context.Context, owner string, done <-chan struct{}) {
+	ticker := time.NewTicker(lockLease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		res, err := r.lock.UpdateOne(ctx, bson.M{"_id": "lock", "owner": owner},
+			bson.M{"$set": bson.M{"expiresAt": time.Now().Add(lockLease)}})
+
+		if err != nil {
+			logger.FromContext(ctx).Warn("error refreshing migration lock", "error", err)
+		} else if res.MatchedCount == 0 {
+			logger.FromContext(ctx).Warn("migration lock was taken over by another instance")
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+)
+
+//go:embed docs.html
+var docsPage string
+
+// Handler serves the document as json, it's marshalled once since routes don't change after startup
+func (d *Document) Handler() fiber.Handler {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+		return c.Send(b)
+	}
+}
+
+// UI serves a docs page that reads the document from specURL and can send requests to the api
+func UI(specURL string) fiber.Handler {
+	page := strings.ReplaceAll(docsPage, "{{SPEC_URL}}", specURL)
+
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString(page)
+	}
+}
@@ -0,0 +1,215 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Route describes one registered fiber route. Response is the data the handler puts in the envelope, or the
+// whole body when Unwrapped is set. Envelope wraps the data's schema, nil is the v1 {"status", "message", "data"}
+// envelope. Raw is the content type of a body that isn't json.
+type Route struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Auth        bool
+	Query       []Parameter
+	Body        interface{}
+	Status      int
+	Response    interface{}
+	Unwrapped   bool
+	Envelope    func(data *Schema) *Schema
+	Raw         string
+	Headers     map[string]*Header
+	Deprecated  bool
+}
+
+// QueryParam is a query parameter with a schema built from a type, like QueryParam("page", "", 1)
+func (d *Document) QueryParam(name, description string, v interface{}) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: d.SchemaOf(v)}
+}
+
+// Add puts a route in the document, a fiber path like /cases/:id becomes /cases/{id}
+func (d *Document) Add(r Route) {
+	path, params := convert(r.Path)
+
+	op := &Operation{
+		OperationID: operationID(r.Method, path),
+		Summary:     r.Summary,
+		Description: r.Description,
+		Tags:        []string{r.Tag},
+		Deprecated:  r.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: d.paramSchema(name)})
+	}
+	op.Parameters = append(op.Parameters, r.Query...)
+
+	if r.Body != nil {
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{
+			fiber.MIMEApplicationJSON: {Schema: d.SchemaOf(r.Body)},
+		}}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := &Response{Description: http.StatusText(status), Headers: r.Headers}
+	switch {
+	case status == http.StatusNoContent:
+	case r.Raw != "":
+		response.Content = map[string]*MediaType{r.Raw: {Schema: &Schema{Type: "string", Format: "binary"}}}
+	case r.Unwrapped:
+		response.Content = map[string]*MediaType{fiber.MIMEApplicationJSON: {Schema: d.SchemaOf(r.Response)}}
+	default:
+		wrap := r.Envelope
+		if wrap == nil {
+			wrap = envelope
+		}
+		response.Content = map[string]*MediaType{fiber.MIMEApplicationJSON: {Schema: wrap(d.SchemaOf(r.Response))}}
+	}
+	op.Responses[strconv.Itoa(status)] = response
+
+	op.Responses["default"] = &Response{
+		Description: "An error",
+		Content:     map[string]*MediaType{"application/problem+json": {Schema: &Schema{Ref: "#/components/schemas/Problem"}}},
+	}
+
+	if r.Auth {
+		op.Security = []map[string][]string{{"signedToken": {}}}
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(r.Method)] = op
+
+	d.routes[routeKey(r.Method, r.Path)] = true
+}
+
+// Verify compares the document against the app's registered routes and lists every route that has no
+// operation and every operation without a route. Middleware and the HEAD routes fiber adds for GETs are skipped.
+func (d *Document) Verify(stack [][]*fiber.Route) error {
+	registered := map[string]bool{}
+	var problems []string
+
+	for _, routes := range stack {
+		for _, r := range routes {
+			if middleware(r) || r.Method == fiber.MethodHead {
+				continue
+			}
+			key := routeKey(r.Method, r.Path)
+			if registered[key] {
+				continue
+			}
+			registered[key] = true
+			if !d.routes[key] {
+				problems = append(problems, key+" is registered but not documented")
+			}
+		}
+	}
+
+	for key := range d.routes {
+		if !registered[key] {
+			problems = append(problems, key+" is documented but not registered")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return fmt.Errorf("openapi document doesn't match the routes: %s", strings.Join(problems, "; "))
+}
+
+// middleware reports whether the route was added with Use or a Group's handlers. Fiber copies those into every
+// method's stack under that method and only marks them in an unexported field, reflect can still read it.
+func middleware(r *fiber.Route) bool {
+	use := reflect.ValueOf(r).Elem().FieldByName("use")
+	return use.IsValid() && use.Bool()
+}
+
+func (d *Document) paramSchema(name string) *Schema {
+	if name == "id" {
+		return d.schema(objectIDType)
+	}
+	return &Schema{Type: "string"}
+}
+
+// envelope wraps data in the {"status", "message", "data"} body every json handler sends
+func envelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":  {Type: "string", Enum: []string{"success"}},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"status", "message", "data"},
+	}
+}
+
+// DataEnvelope wraps data in the {"data"} body of the v2 api
+func DataEnvelope(data *Schema) *Schema {
+	return &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"data": data},
+		Required:   []string{"data"},
+	}
+}
+
+// convert turns a fiber path into an OpenAPI one and lists its parameters
+func convert(path string) (string, []string) {
+	var params []string
+	parts := strings.Split(normalise(path), "/")
+
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			name := strings.TrimSuffix(strings.TrimPrefix(part, ":"), "?")
+			params = append(params, name)
+			parts[i] = "{" + name + "}"
+		}
+	}
+
+	return strings.Join(parts, "/"), params
+}
+
+// normalise matches fiber's routing, which ignores a trailing slash
+func normalise(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	return path
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + normalise(path)
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '-' || r == '_' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package openapi
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// SchemaOf describes the json encoding of v. Named structs are added to the components once and referred to,
+// the validate tags of their fields become the matching constraints.
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return d.schema(reflect.TypeOf(v))
+}
+
+func (d *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: "^[0-9a-f]{24}$", Description: "a Mongo object id"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "binary"}
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		return d.object(t)
+	}
+
+	// interfaces, like the values of a bson.M, can be anything
+	return &Schema{}
+}
+
+func (d *Document) object(t reflect.Type) *Schema {
+	name := t.Name()
+
+	if name != "" {
+		if _, ok := d.Components.Schemas[name]; ok {
+			return &Schema{Ref: "#/components/schemas/" + name}
+		}
+		// a placeholder stops a struct that refers to itself from recursing forever
+		d.Components.Schemas[name] = &Schema{}
+	}
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+
+		// embedded structs like jwt.StandardClaims aren't part of any response
+		if field.Anonymous {
+			continue
+		}
+
+		if tag == "" {
+			tag = field.Name
+		}
+
+		prop := d.schema(field.Type)
+		if constrain(prop, field.Tag.Get("validate")) {
+			s.Required = append(s.Required, tag)
+		}
+		s.Properties[tag] = prop
+	}
+
+	if name == "" {
+		return s
+	}
+
+	*d.Components.Schemas[name] = *s
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// constrain applies validate rules to the schema and reports whether the field is required
+func constrain(s *Schema, rules string) bool {
+	if rules == "" || rules == "-" || s.Ref != "" {
+		return strings.Contains(rules, "required")
+	}
+
+	required := false
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		n, _ := strconv.Atoi(arg)
+
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		case "unique":
+			s.UniqueItems = true
+		case "min", "max":
+			switch s.Type {
+			case "string":
+				if name == "min" {
+					s.MinLength = &n
+				} else {
+					s.MaxLength = &n
+				}
+			case "array":
+				if name == "max" {
+					s.MaxItems = &n
+				}
+			case "integer", "number":
+				f := float64(n)
+				if name == "min" {
+					s.Minimum = &f
+				} else {
+					s.Maximum = &f
+				}
+			}
+		}
+	}
+
+	return required
+}
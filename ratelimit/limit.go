@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket that holds Burst requests and refills completely over Per, "20/1m" allows 20 requests
+// at once and one more every 3 seconds after that
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Parse reads a limit written as <burst>/<duration>, like 5/1m or 300/1h
+func Parse(s string) (Limit, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("%q is not <requests>/<duration>", s)
+	}
+
+	burst, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || burst < 1 {
+		return Limit{}, fmt.Errorf("%q doesn't start with a positive number of requests", s)
+	}
+
+	per, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("%q doesn't end with a positive duration", s)
+	}
+
+	return Limit{Burst: burst, Per: per}, nil
+}
+
+// rate is how many tokens the bucket gains a second
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Policy describes the limit for the RateLimit-Policy header
+func (l Limit) Policy() string {
+	return fmt.Sprintf("%d;w=%d", l.Burst, int(math.Ceil(l.Per.Seconds())))
+}
+
+// Result is the decision on one request. Reset is how long until the bucket is full again, RetryAfter how long
+// until a refused request would be allowed.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets. Take refills the bucket for key up to now and takes a token from it when there's one.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// result describes a bucket that has tokens left after the decision
+func result(allowed bool, tokens float64, limit Limit) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.rate()),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.rate())
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// refill is the bucket's tokens at now, it starts full
+func refill(tokens float64, updated, now time.Time, limit Limit) float64 {
+	if updated.IsZero() {
+		return float64(limit.Burst)
+	}
+	tokens += now.Sub(updated).Seconds() * limit.rate()
+	return math.Min(tokens, float64(limit.Burst))
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// MongoStore keeps the buckets in a collection so every instance shares them. Each decision is one
+// findAndModify that refills and takes in an update pipeline, two instances can't both take the last token.
+// A TTL index on expiresAt removes buckets once they'd be full again.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) MongoStore {
+	return MongoStore{collection: collection}
+}
+
+type storedBucket struct {
+	Tokens  float64 `bson:"tokens"`
+	Allowed bool    `bson:"allowed"`
+}
+
+func (m MongoStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	burst := float64(limit.Burst)
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{burst, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", burst}},
+				// subtracting two dates gives milliseconds
+				bson.M{"$multiply": bson.A{
+					bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated", now}}}},
+					limit.rate() / 1000,
+				}},
+			}}}},
+			"updated": now,
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":    bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expiresAt": now.Add(limit.Per),
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var b storedBucket
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&b)
+
+	// two first requests for the same key can race to insert it, the loser finds it on the second try
+	if mongo.IsDuplicateKeyError(err) {
+		err = m.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&b)
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result(b.Allowed, b.Tokens, limit), nil
+}
@@ -0,0 +1,14 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AppealRepo interface {
+	Create(ctx context.Context, appeal *domain.Appeal) error
+	FindAll(ctx context.Context, page string) (*[]domain.Appeal, error)
+	FindById(ctx context.Context, id primitive.ObjectID) (*domain.Appeal, error)
+	Decide(ctx context.Context, id primitive.ObjectID, status string, reviewer string, decision string) (*domain.Appeal, error)
+}
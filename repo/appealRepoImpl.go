@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strconv"
+	"time"
+)
+
+type AppealRepoImpl struct {
+	conn       *database.Connection
+	Appeal     domain.Appeal
+	AppealList []domain.Appeal
+}
+
+// Create queues an appeal against a moderation action, only the affected user can appeal, only once and only an
+// action that can be undone
+func (a AppealRepoImpl) Create(ctx context.Context, appeal *domain.Appeal) error {
+	conn := a.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	action, err := NewModerationActionRepoImpl(conn).FindById(ctx, appeal.ActionId)
+
+	if err != nil {
+		return err
+	}
+
+	if action.TargetUsername != appeal.AppellantUsername {
+		return apperrors.Forbidden("not_affected_user", "only the affected user can appeal this action")
+	}
+
+	if !action.Undoable() {
+		return apperrors.Conflict("action_not_undoable", "cannot appeal an action that can't be undone")
+	}
+
+	count, err := conn.AppealCollection.CountDocuments(ctx, bson.M{"actionId": appeal.ActionId})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if count > 0 {
+		return apperrors.Conflict("appeal_exists", "appeal already exists")
+	}
+
+	appeal.Id = primitive.NewObjectID()
+	appeal.Status = domain.AppealPending
+	appeal.OriginalActor = action.ActorUsername
+	appeal.ReviewerUsername = ""
+	appeal.Decision = ""
+	appeal.CreatedAt = time.Now()
+
+	_, err = conn.AppealCollection.InsertOne(ctx, appeal)
+
+	if err != nil {
+		// another appeal of the action got in between, the unique index on actionId turns it down
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.Conflict("appeal_exists", "appeal already exists")
+		}
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// FindAll returns the pending appeals, oldest first
+func (a AppealRepoImpl) FindAll(ctx context.Context, page string) (*[]domain.Appeal, error) {
+	conn := a.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	findOptions := options.FindOptions{}
+	perPage := 10
+	pageNumber, err := strconv.Atoi(page)
+
+	if err != nil {
+		return nil, apperrors.Validation("invalid_page", "page must be a number").WithField("page", "must be a number")
+	}
+	findOptions.SetSkip((int64(pageNumber) - 1) * int64(perPage))
+	findOptions.SetLimit(int64(perPage))
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: 1}})
+
+	cur, err := conn.AppealCollection.Find(ctx, bson.M{"status": domain.AppealPending}, &findOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cur.All(ctx, &a.AppealList); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return &a.AppealList, nil
+}
+
+func (a AppealRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*domain.Appeal, error) {
+	conn := a.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.AppealCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&a.Appeal)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("appeal_not_found", "cannot find appeal")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &a.Appeal, nil
+}
+
+// Decide resolves a pending appeal. The reviewer has to be someone other than the moderator who took the
+// original action. Overturning undoes the action, and either outcome is published on the event topic.
+func (a AppealRepoImpl) Decide(ctx context.Context, id primitive.ObjectID, status string, reviewer string, decision string) (*domain.Appeal, error) {
+	conn := a.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	if status != domain.AppealUpheld && status != domain.AppealOverturned {
+		return nil, apperrors.Validation("invalid_outcome", "invalid appeal outcome").WithField("outcome", "must be upheld or overturned")
+	}
+
+	appeal, err := a.FindById(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if appeal.OriginalActor == reviewer {
+		return nil, apperrors.Forbidden("same_moderator", "appeal must be reviewed by a different moderator")
+	}
+
+	// claim the appeal so two reviewers can't decide it at the same time
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	filter := bson.M{"_id": id, "status": domain.AppealPending}
+	update := bson.M{"$set": bson.M{"status": status, "reviewerUsername": reviewer,
+		"decision": decision, "decidedAt": time.Now()}}
+
+	err = conn.AppealCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&a.Appeal)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.Conflict("appeal_decided", "appeal has already been decided")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	action, err := NewModerationActionRepoImpl(conn).FindById(ctx, a.Appeal.ActionId)
+
+	if err == nil && status == domain.AppealOverturned {
+		err = NewModerationActionRepoImpl(conn).Undo(ctx, action)
+	}
+
+	if err != nil {
+		// put the appeal back in the queue so it can be decided again
+		_, _ = conn.AppealCollection.UpdateOne(ctx, bson.M{"_id": id},
+			bson.M{"$set": bson.M{"status": domain.AppealPending, "reviewerUsername": "", "decision": ""}})
+		return nil, err
+	}
+
+	event := new(domain.Event)
+	event.Action = "appeal " + status
+	event.Target = a.Appeal.AppellantUsername
+	event.ResourceId = action.ResourceId
+	event.ActorUsername = reviewer
+	event.Message = reviewer + " " + status + " the appeal against the " + action.Action + " of " + action.ResourceType + " " + action.ResourceId.Hex()
+	err = SendEventMessage(ctx, conn, event)
+	if err != nil {
+		logger.FromContext(ctx).Error("error queueing appeal event", "error", err)
+	}
+
+	return &a.Appeal, nil
+}
+
+func NewAppealRepoImpl(conn *database.Connection) AppealRepoImpl {
+	return AppealRepoImpl{conn: conn}
+}
@@ -1,8 +1,11 @@
 package repo
 
-import "example.com/app/domain"
+import (
+	"context"
+	"example.com/app/domain"
+)
 
 type AuthRepo interface {
-	Login(username string, password string, ip string, ips []string) (*domain.Admin, string, error)
+	Login(ctx context.Context, username string, password string, ip string, ips []string) (*domain.Admin, string, error)
 }
 
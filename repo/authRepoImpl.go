@@ -2,66 +2,71 @@ package repo
 
 import (
 	"context"
+	"example.com/app/apperrors"
 	"example.com/app/database"
 	"example.com/app/domain"
+	"example.com/app/logger"
 	//"example.com/app/util"
-	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthRepoImpl struct {
+	conn *database.Connection
 }
 
-func(a AuthRepoImpl) Login(username string, password string, ip string, ips []string) (*domain.Admin, string, error) {
+func (a AuthRepoImpl) Login(ctx context.Context, username string, password string, ip string, ips []string) (*domain.Admin, string, error) {
 	var login domain.Authentication
 	var admin domain.Admin
 
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	database.MongoConnectionPool.Put(conn)
+	conn := a.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
 
 	opts := options.FindOne()
-	err := conn.AdminCollection.FindOne(context.TODO(), bson.D{{"username",
-		username}},opts).Decode(&admin)
-
-	fmt.Println(admin)
-	fmt.Println(password)
+	err := conn.AdminCollection.FindOne(ctx, bson.D{{Key: "username", Value: username}},opts).Decode(&admin)
 
 	if err != nil {
-		return nil, "", fmt.Errorf("error finding by username")
+		if err == mongo.ErrNoDocuments {
+			return nil, "", apperrors.Unauthorized("invalid_credentials", "invalid username or password")
+		}
+		return nil, "", apperrors.Internal(err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password))
 
 	if err != nil {
-		return nil, "", fmt.Errorf("error comparing password")
+		return nil, "", apperrors.Unauthorized("invalid_credentials", "invalid username or password")
 	}
 
 	token, err := login.GenerateJWT(admin)
 
 	if err != nil {
-		return nil, "", fmt.Errorf("error generating token")
+		return nil, "", apperrors.Internal(err)
 	}
 
+	// the update outlives the request so it gets its own deadline, it still logs with the request's id
+	log := logger.FromContext(ctx)
 	go func() {
-		filter := bson.D{{"username", username}}
-		update := bson.D{{"$set", bson.D{{"lastLoginIp", ip}, {"lastLoginIps", ips}}}}
+		ctx, cancel := withTimeout(context.Background(), "write")
+		defer cancel()
 
-		_, err := conn.AdminCollection.UpdateOne(context.TODO(),
+		filter := bson.D{{Key: "username", Value: username}}
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "lastLoginIp", Value: ip}, {Key: "lastLoginIps", Value: ips}}}}
+
+		_, err := conn.AdminCollection.UpdateOne(ctx,
 			filter, update)
 
 		if err != nil {
-			panic(err)
+			log.Error("error recording last login", "username", username, "error", err)
 		}
-		return
 	}()
 
 	return &admin, token, nil
 }
 
-func NewAuthRepoImpl() AuthRepoImpl {
-	var authRepoImpl AuthRepoImpl
-
-	return authRepoImpl
+func NewAuthRepoImpl(conn *database.Connection) AuthRepoImpl {
+	return AuthRepoImpl{conn: conn}
 }
\ No newline at end of file
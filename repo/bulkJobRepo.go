@@ -0,0 +1,16 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BulkJobRepo interface {
+	FindTargets(ctx context.Context, request *domain.BulkRequest) (*[]domain.BulkTarget, error)
+	Create(ctx context.Context, job *domain.BulkJob) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*domain.BulkJob, error)
+	AddResult(ctx context.Context, id primitive.ObjectID, result domain.BulkItemResult) error
+	Finish(ctx context.Context, id primitive.ObjectID, status string) error
+	Hide(ctx context.Context, resourceType string, id primitive.ObjectID, actor string) error
+}
@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+	"time"
+)
+
+type BulkJobRepoImpl struct {
+	conn       *database.Connection
+	BulkJob    domain.BulkJob
+	TargetList []domain.BulkTarget
+}
+
+// FindTargets resolves a bulk request to the resources it applies to
+func (b BulkJobRepoImpl) FindTargets(ctx context.Context, request *domain.BulkRequest) (*[]domain.BulkTarget, error) {
+	conn := b.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	if request.ResourceType == "user" {
+		return nil, apperrors.Validation("invalid_resource_type", "invalid resource type")
+	}
+
+	collection, err := resourceCollection(conn, request.ResourceType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{}
+
+	if len(request.Ids) > 0 {
+		filter["_id"] = bson.M{"$in": request.Ids}
+	} else if request.Filter != nil {
+		f := request.Filter
+
+		if f.AuthorUsername != "" {
+			filter["authorUsername"] = f.AuthorUsername
+		}
+
+		createdAt := bson.M{}
+		if !f.From.IsZero() {
+			createdAt["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			createdAt["$lte"] = f.To
+		}
+		if len(createdAt) > 0 {
+			filter["createdAt"] = createdAt
+		}
+
+		if f.Tag != "" {
+			if request.ResourceType != "story" {
+				return nil, apperrors.Validation("invalid_filter", "only stories can be filtered by tag").WithField("filter.tag", "only stories can be filtered by tag")
+			}
+			filter["tags.value"] = f.Tag
+		}
+
+		if f.Text != "" {
+			filter["content"] = primitive.Regex{Pattern: regexp.QuoteMeta(f.Text), Options: "i"}
+		}
+	}
+
+	// never run an action against a whole collection by accident
+	if len(filter) == 0 {
+		return nil, apperrors.Validation("missing_targets", "must provide ids or a filter")
+	}
+
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "authorUsername": 1})
+
+	cur, err := collection.Find(ctx, filter, opts)
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	b.TargetList = []domain.BulkTarget{}
+	if err = cur.All(ctx, &b.TargetList); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return &b.TargetList, nil
+}
+
+func (b BulkJobRepoImpl) Create(ctx context.Context, job *domain.BulkJob) error {
+	conn := b.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	job.Id = primitive.NewObjectID()
+	job.Status = domain.JobRunning
+	job.Results = []domain.BulkItemResult{}
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+
+	_, err := conn.JobCollection.InsertOne(ctx, job)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+func (b BulkJobRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*domain.BulkJob, error) {
+	conn := b.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.JobCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&b.BulkJob)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("job_not_found", "cannot find job")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &b.BulkJob, nil
+}
+
+// AddResult records the outcome of one item and recalculates the progress of the job
+func (b BulkJobRepoImpl) AddResult(ctx context.Context, id primitive.ObjectID, result domain.BulkItemResult) error {
+	conn := b.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"results":   bson.M{"$concatArrays": bson.A{"$results", bson.A{result}}},
+			"processed": bson.M{"$add": bson.A{"$processed", 1}},
+			"updatedAt": time.Now(),
+		}},
+		bson.M{"$set": bson.M{
+			"progress": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$total", 0}},
+				100,
+				bson.M{"$multiply": bson.A{bson.M{"$divide": bson.A{"$processed", "$total"}}, 100}},
+			}},
+		}},
+	}
+
+	_, err := conn.JobCollection.UpdateOne(ctx, bson.M{"_id": id}, update)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+func (b BulkJobRepoImpl) Finish(ctx context.Context, id primitive.ObjectID, status string) error {
+	conn := b.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	now := time.Now()
+	_, err := conn.JobCollection.UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"status": status, "finishedAt": now, "updatedAt": now}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// Hide hides a resource on behalf of a moderator and publishes the hide on the event topic
+func (b BulkJobRepoImpl) Hide(ctx context.Context, resourceType string, id primitive.ObjectID, actor string) error {
+	conn := b.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	event := moderationEvent("hide", resourceType, id, actor, actor+" hid the "+resourceType)
+
+	err := transaction(ctx, conn, func(ctx context.Context, _ bool) error {
+		err := setHidden(ctx, conn, resourceType, id, true)
+
+		if err != nil {
+			return err
+		}
+
+		return SendEventMessage(ctx, conn, event)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	announceModerationEvent(ctx, conn, event)
+
+	return nil
+}
+
+func NewBulkJobRepoImpl(conn *database.Connection) BulkJobRepoImpl {
+	return BulkJobRepoImpl{conn: conn}
+}
@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// cascade runs the deletes in fn in one transaction. Standalone deployments can't run transactions,
+// so there fn runs again without one and result.Transactional is false.
+func cascade(ctx context.Context, conn *database.Connection, result *domain.CascadeResult,
+	fn func(ctx context.Context, result *domain.CascadeResult) error) error {
+	return transaction(ctx, conn, func(ctx context.Context, transactional bool) error {
+		// a retried transaction starts counting again
+		*result = domain.CascadeResult{Transactional: transactional}
+		return fn(ctx, result)
+	})
+}
+
+// deleteStory removes a story, its comments, their replies and every flag on any of them. With an action the
+// removed comments, replies and flags are kept in it to be put back on undo.
+func deleteStory(ctx context.Context, conn *database.Connection, id primitive.ObjectID, result *domain.CascadeResult,
+	action *domain.ModerationAction) error {
+	res, err := conn.StoryCollection.DeleteOne(ctx, bson.M{"_id": id})
+
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return apperrors.NotFound("story_not_found", "failed to delete story")
+	}
+
+	result.Stories = res.DeletedCount
+
+	commentIds, err := conn.CommentsCollection.Distinct(ctx, "_id", bson.M{"resourceId": id})
+
+	if err != nil {
+		return err
+	}
+
+	err = keepCascaded(ctx, conn.CommentsCollection, bson.M{"resourceId": id}, action)
+
+	if err != nil {
+		return err
+	}
+
+	comments, err := conn.CommentsCollection.DeleteMany(ctx, bson.M{"resourceId": id})
+
+	if err != nil {
+		return err
+	}
+
+	result.Comments = comments.DeletedCount
+
+	return deleteReplies(ctx, conn, commentIds, append(commentIds, id), result, action)
+}
+
+// deleteComment removes a comment, its replies and every flag on any of them, keeping the replies and flags in
+// action like deleteStory
+func deleteComment(ctx context.Context, conn *database.Connection, id primitive.ObjectID, result *domain.CascadeResult,
+	action *domain.ModerationAction) error {
+	res, err := conn.CommentsCollection.DeleteOne(ctx, bson.M{"_id": id})
+
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return apperrors.NotFound("comment_not_found", "failed to delete comment")
+	}
+
+	result.Comments = res.DeletedCount
+
+	return deleteReplies(ctx, conn, []interface{}{id}, []interface{}{id}, result, action)
+}
+
+// deleteReply removes a reply the user wrote and the flags on it, keeping the flags in action like deleteStory
+func deleteReply(ctx context.Context, conn *database.Connection, id primitive.ObjectID, username string, result *domain.CascadeResult,
+	action *domain.ModerationAction) error {
+	res, err := conn.RepliesCollection.DeleteOne(ctx, bson.M{"_id": id, "authorUsername": username})
+
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return apperrors.Forbidden("not_author", "you can't delete a reply that you didn't create")
+	}
+
+	result.Replies = res.DeletedCount
+
+	err = keepCascaded(ctx, conn.FlagCollection, bson.M{"flaggedResource": id}, action)
+
+	if err != nil {
+		return err
+	}
+
+	flags, err := conn.FlagCollection.DeleteMany(ctx, bson.M{"flaggedResource": id})
+
+	if err != nil {
+		return err
+	}
+
+	result.Flags = flags.DeletedCount
+
+	return nil
+}
+
+// deleteReplies removes the replies to commentIds, then the flags on them and on the flagged resources
+func deleteReplies(ctx context.Context, conn *database.Connection, commentIds []interface{}, flagged []interface{},
+	result *domain.CascadeResult, action *domain.ModerationAction) error {
+	replyIds, err := conn.RepliesCollection.Distinct(ctx, "_id", bson.M{"resourceId": bson.M{"$in": commentIds}})
+
+	if err != nil {
+		return err
+	}
+
+	err = keepCascaded(ctx, conn.RepliesCollection, bson.M{"_id": bson.M{"$in": replyIds}}, action)
+
+	if err != nil {
+		return err
+	}
+
+	replies, err := conn.RepliesCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": replyIds}})
+
+	if err != nil {
+		return err
+	}
+
+	result.Replies = replies.DeletedCount
+
+	flagFilter := bson.M{"flaggedResource": bson.M{"$in": append(flagged, replyIds...)}}
+
+	err = keepCascaded(ctx, conn.FlagCollection, flagFilter, action)
+
+	if err != nil {
+		return err
+	}
+
+	flags, err := conn.FlagCollection.DeleteMany(ctx, flagFilter)
+
+	if err != nil {
+		return err
+	}
+
+	result.Flags = flags.DeletedCount
+
+	return nil
+}
+
+// keepCascaded copies the documents a cascade is about to remove into action, nothing is kept without one
+func keepCascaded(ctx context.Context, collection *mongo.Collection, filter interface{}, action *domain.ModerationAction) error {
+	if action == nil {
+		return nil
+	}
+
+	cur, err := collection.Find(ctx, filter)
+
+	if err != nil {
+		return err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		action.Cascade = append(action.Cascade, domain.CascadedDocument{
+			Collection: collection.Name(),
+			Document:   append(bson.Raw{}, cur.Current...),
+		})
+	}
+
+	return cur.Err()
+}
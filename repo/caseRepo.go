@@ -0,0 +1,20 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CaseRepo interface {
+	Create(ctx context.Context, c *domain.Case) error
+	FindAll(ctx context.Context, page string, status string) (*[]domain.Case, error)
+	FindByAssignee(ctx context.Context, page string, username string) (*[]domain.Case, error)
+	FindById(ctx context.Context, id primitive.ObjectID) (*domain.Case, error)
+	UpdateById(ctx context.Context, id primitive.ObjectID, update *domain.CaseUpdate) (*domain.Case, error)
+	DeleteById(ctx context.Context, id primitive.ObjectID) error
+	Assign(ctx context.Context, id primitive.ObjectID, assignee string) (*domain.Case, error)
+	UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) (*domain.Case, error)
+	AddNote(ctx context.Context, id primitive.ObjectID, note *domain.CaseNote) (*domain.Case, error)
+	AddEvidence(ctx context.Context, id primitive.ObjectID, evidence *domain.CaseEvidence) (*domain.Case, error)
+}
@@ -0,0 +1,290 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strconv"
+	"time"
+)
+
+type CaseRepoImpl struct {
+	conn     *database.Connection
+	Case     domain.Case
+	CaseList []domain.Case
+}
+
+func (cr CaseRepoImpl) Create(ctx context.Context, c *domain.Case) error {
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	c.Id = primitive.NewObjectID()
+	c.Status = domain.CaseOpen
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = c.CreatedAt
+	c.Notes = []domain.CaseNote{}
+	c.Evidence = []domain.CaseEvidence{}
+
+	if c.AssigneeUsername != "" {
+		err := adminExists(ctx, conn, c.AssigneeUsername)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.CaseCollection.InsertOne(ctx, c)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// FindAll returns the cases most recently updated first, optionally only those with the given status
+func (cr CaseRepoImpl) FindAll(ctx context.Context, page string, status string) (*[]domain.Case, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	return cr.find(ctx, page, filter)
+}
+
+// FindByAssignee returns the cases assigned to an admin that aren't resolved yet
+func (cr CaseRepoImpl) FindByAssignee(ctx context.Context, page string, username string) (*[]domain.Case, error) {
+	return cr.find(ctx, page, bson.M{"assigneeUsername": username, "status": bson.M{"$ne": domain.CaseResolved}})
+}
+
+func (cr CaseRepoImpl) find(ctx context.Context, page string, filter bson.M) (*[]domain.Case, error) {
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	findOptions := options.FindOptions{}
+	perPage := 10
+	pageNumber, err := strconv.Atoi(page)
+
+	if err != nil {
+		return nil, apperrors.Validation("invalid_page", "page must be a number").WithField("page", "must be a number")
+	}
+	findOptions.SetSkip((int64(pageNumber) - 1) * int64(perPage))
+	findOptions.SetLimit(int64(perPage))
+	findOptions.SetSort(bson.D{{Key: "updatedAt", Value: -1}})
+
+	cur, err := conn.CaseCollection.Find(ctx, filter, &findOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cur.All(ctx, &cr.CaseList); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return &cr.CaseList, nil
+}
+
+func (cr CaseRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*domain.Case, error) {
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.CaseCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&cr.Case)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("case_not_found", "cannot find case")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &cr.Case, nil
+}
+
+func (cr CaseRepoImpl) UpdateById(ctx context.Context, id primitive.ObjectID, update *domain.CaseUpdate) (*domain.Case, error) {
+	return cr.update(ctx, id, bson.M{"$set": bson.M{
+		"title":       update.Title,
+		"description": update.Description,
+		"flagIds":     update.FlagIds,
+		"resources":   update.Resources,
+		"usernames":   update.Usernames,
+		"updatedAt":   time.Now(),
+	}})
+}
+
+func (cr CaseRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID) error {
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "cascade")
+	defer cancel()
+
+	res, err := conn.CaseCollection.DeleteOne(ctx, bson.M{"_id": id})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return apperrors.NotFound("case_not_found", "cannot find case")
+	}
+
+	return nil
+}
+
+func (cr CaseRepoImpl) Assign(ctx context.Context, id primitive.ObjectID, assignee string) (*domain.Case, error) {
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	err := adminExists(ctx, conn, assignee)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cr.update(ctx, id, bson.M{"$set": bson.M{"assigneeUsername": assignee, "updatedAt": time.Now()}})
+}
+
+// UpdateStatus moves the case to a new status if the transition is allowed from its current one
+func (cr CaseRepoImpl) UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) (*domain.Case, error) {
+	c, err := cr.FindById(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.CanTransition(status) {
+		return nil, apperrors.Conflict("invalid_transition", fmt.Sprintf("cannot move case from %s to %s", c.Status, status))
+	}
+
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	// match on the old status so a concurrent transition isn't overwritten
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	filter := bson.M{"_id": id, "status": c.Status}
+	update := bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now()}}
+
+	err = conn.CaseCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&cr.Case)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.Conflict("case_modified", "case was changed by someone else, try again")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &cr.Case, nil
+}
+
+// AddNote appends a note to the case, a note with a ParentId has to answer an existing note
+func (cr CaseRepoImpl) AddNote(ctx context.Context, id primitive.ObjectID, note *domain.CaseNote) (*domain.Case, error) {
+	if !note.ParentId.IsZero() {
+		c, err := cr.FindById(ctx, id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, n := range c.Notes {
+			if n.Id == note.ParentId {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, apperrors.NotFound("note_not_found", "cannot find parent note")
+		}
+	}
+
+	note.Id = primitive.NewObjectID()
+	note.CreatedAt = time.Now()
+
+	return cr.update(ctx, id, bson.M{
+		"$push": bson.M{"notes": note},
+		"$set":  bson.M{"updatedAt": note.CreatedAt},
+	})
+}
+
+// AddEvidence snapshots the resource as it is now and attaches it to the case
+func (cr CaseRepoImpl) AddEvidence(ctx context.Context, id primitive.ObjectID, evidence *domain.CaseEvidence) (*domain.Case, error) {
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	collection, err := resourceCollection(conn, evidence.ResourceType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := bson.M{}
+	err = collection.FindOne(ctx, bson.M{"_id": evidence.ResourceId}).Decode(&snapshot)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("resource_not_found", "resource not found")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	// credentials never belong in a case file
+	delete(snapshot, "password")
+
+	evidence.Id = primitive.NewObjectID()
+	evidence.Snapshot = snapshot
+	evidence.CapturedAt = time.Now()
+
+	return cr.update(ctx, id, bson.M{
+		"$push": bson.M{"evidence": evidence},
+		"$set":  bson.M{"updatedAt": evidence.CapturedAt},
+	})
+}
+
+func (cr CaseRepoImpl) update(ctx context.Context, id primitive.ObjectID, update bson.M) (*domain.Case, error) {
+	conn := cr.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := conn.CaseCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&cr.Case)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("case_not_found", "cannot find case")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &cr.Case, nil
+}
+
+func adminExists(ctx context.Context, conn *database.Connection, username string) error {
+	count, err := conn.AdminCollection.CountDocuments(ctx, bson.M{"username": username})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if count == 0 {
+		return apperrors.NotFound("admin_not_found", "cannot find admin")
+	}
+
+	return nil
+}
+
+func NewCaseRepoImpl(conn *database.Connection) CaseRepoImpl {
+	return CaseRepoImpl{conn: conn}
+}
@@ -1,14 +1,14 @@
 package repo
 
 import (
+	"context"
 	"example.com/app/domain"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type CommentRepo interface {
-	Create(comment *domain.Comment) error
-	UpdateById(id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time, username string) error
-	DeleteById(id primitive.ObjectID) error
-	DeleteManyById(id primitive.ObjectID) error
+	Create(ctx context.Context, comment *domain.Comment) error
+	UpdateById(ctx context.Context, id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time, username string) error
+	DeleteById(ctx context.Context, id primitive.ObjectID, action *domain.ModerationAction) (*domain.CascadeResult, error)
 }
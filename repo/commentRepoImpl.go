@@ -2,20 +2,16 @@ package repo
 
 import (
 	"context"
+	"example.com/app/apperrors"
 	"example.com/app/database"
 	"example.com/app/domain"
-	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readconcern"
-	"go.mongodb.org/mongo-driver/mongo/writeconcern"
-	"sync"
 	"time"
 )
 
 type CommentRepoImpl struct {
+	conn *database.Connection
 	Comment        domain.Comment
 	CommentDto     domain.CommentDto
 	Reply          domain.Reply
@@ -23,212 +19,98 @@ type CommentRepoImpl struct {
 	CommentDtoList []domain.CommentDto
 }
 
-func (c CommentRepoImpl) Create(comment *domain.Comment) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (c CommentRepoImpl) Create(ctx context.Context, comment *domain.Comment) error {
+	conn := c.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
 
 	story := new(domain.Story)
 
-	err := conn.StoryCollection.FindOne(context.TODO(), bson.D{{"_id", comment.ResourceId}}).Decode(&story)
+	err := conn.StoryCollection.FindOne(ctx, bson.D{{Key: "_id", Value: comment.ResourceId}}).Decode(&story)
 
 	if err != nil {
-		return fmt.Errorf("resource not found")
+		return apperrors.NotFound("resource_not_found", "resource not found")
 	}
 
-	_, err = conn.CommentsCollection.InsertOne(context.TODO(), &comment)
+	event := new(domain.Event)
+	event.Action = "comment on story"
+	event.Target = comment.ResourceId.String()
+	event.ResourceId = comment.ResourceId
+	event.ActorUsername = comment.AuthorUsername
+	event.Message = comment.AuthorUsername + " commented on a story with the ID:" + comment.ResourceId.String()
 
-	if err != nil {
-		return err
-	}
+	// the comment and its event are saved together, the outbox relay sends the event
+	return transaction(ctx, conn, func(ctx context.Context, _ bool) error {
+		_, err := conn.CommentsCollection.InsertOne(ctx, &comment)
 
-	go func() {
-		event := new(domain.Event)
-		event.Action = "comment on story"
-		event.Target = comment.ResourceId.String()
-		event.ResourceId = comment.ResourceId
-		event.ActorUsername = comment.AuthorUsername
-		event.Message = comment.AuthorUsername + " commented on a story with the ID:" + comment.ResourceId.String()
-		err = SendEventMessage(event, 0)
 		if err != nil {
-			fmt.Println("Error publishing...")
-			return
+			return err
 		}
-	}()
 
-	return nil
+		return SendEventMessage(ctx, conn, event)
+	})
 }
 
-func (c CommentRepoImpl) UpdateById(id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time, username string) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (c CommentRepoImpl) UpdateById(ctx context.Context, id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time, username string) error {
+	conn := c.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
 
-	opts := options.FindOneAndUpdate().SetUpsert(true)
-	filter := bson.D{{"_id", id}, {"authorUsername", username}}
-	update := bson.D{{"$set", bson.D{{"content", newContent}, {"edited", edited},
-		{"updatedTime", updatedTime}}}}
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "authorUsername", Value: username}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "content", Value: newContent}, {Key: "edited", Value: edited},
+		{Key: "updatedTime", Value: updatedTime}}}}
 
-	err := conn.CommentsCollection.FindOneAndUpdate(context.TODO(),
-		filter, update, opts).Decode(&c.Comment)
+	res, err := conn.CommentsCollection.UpdateOne(ctx,
+		filter, update)
 
 	if err != nil {
-		return fmt.Errorf("cannot update comment that you didn't write")
-	}
-
-	return nil
-}
-
-func (c CommentRepoImpl) DeleteById(id primitive.ObjectID) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
-
-	// sets mongo's read and write concerns
-	wc := writeconcern.New(writeconcern.WMajority())
-	rc := readconcern.Snapshot()
-	txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
-
-	// set up for a transaction
-	session, err := conn.StartSession()
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer session.EndSession(context.Background())
-
-	// execute this code in a logical transaction
-	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-		var wg sync.WaitGroup
-		wg.Add(3)
-
-		go func() {
-			defer wg.Done()
-
-			res, err := conn.CommentsCollection.DeleteOne(context.TODO(), bson.D{{"_id", id}})
-
-			if err != nil {
-				panic(err)
-			}
-
-			if res.DeletedCount == 0 {
-				panic(fmt.Errorf("you can't delete a comment that you didn't create"))
-			}
-
-			return
-		}()
-
-		go func() {
-			defer wg.Done()
-
-			_, err = conn.FlagCollection.DeleteMany(context.TODO(), bson.D{{"flaggedResource", id}})
-			if err != nil {
-				panic(err)
-			}
-
-			return
-		}()
-
-		go func() {
-			defer wg.Done()
-
-			_, err = conn.RepliesCollection.DeleteMany(context.TODO(), bson.D{{"resourceId", id}})
-			if err != nil {
-				panic(err)
-			}
-
-			return
-		}()
-
-		wg.Wait()
-
-		return nil, err
+		return apperrors.Internal(err)
 	}
 
-	_, err = session.WithTransaction(context.Background(), callback, txnOpts)
-
-	if err != nil {
-		return fmt.Errorf("failed to delete reply")
+	if res.MatchedCount == 0 {
+		return notAuthor(ctx, conn.CommentsCollection, id, apperrors.NotFound("comment_not_found", "cannot find comment"),
+			apperrors.Forbidden("not_author", "cannot update comment that you didn't write"))
 	}
 
 	return nil
 }
 
-func (c CommentRepoImpl) DeleteManyById(id primitive.ObjectID) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
-
-	err := conn.CommentsCollection.FindOne(context.TODO(), bson.D{{"resourceId", id}}).Decode(&c.Comment)
-
-	// sets mongo's read and write concerns
-	wc := writeconcern.New(writeconcern.WMajority())
-	rc := readconcern.Snapshot()
-	txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
-
-	// set up for a transaction
-	session, err := conn.StartSession()
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer session.EndSession(context.Background())
-
-	// execute this code in a logical transaction
-	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-		var wg sync.WaitGroup
-		wg.Add(3)
+// DeleteById deletes the comment with its replies and all of their flags in one transaction. An action is
+// recorded in the same transaction with what the delete removed, nil deletes without a record.
+func (c CommentRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID, action *domain.ModerationAction) (*domain.CascadeResult, error) {
+	conn := c.conn
+	ctx, cancel := withTimeout(ctx, "cascade")
+	defer cancel()
 
-		go func() {
-			defer wg.Done()
+	result := new(domain.CascadeResult)
 
-			res, err := conn.CommentsCollection.DeleteMany(context.TODO(), bson.D{{"resourceId", id}})
-			if err != nil {
-				panic(err)
-			}
-
-			if res.DeletedCount == 0 {
-				panic(err)
-			}
-
-			return
-		}()
-
-		go func() {
-			defer wg.Done()
-
-			_, err = conn.FlagCollection.DeleteMany(context.TODO(), bson.D{{"flaggedResource", id}})
-			if err != nil {
-				panic(err)
-			}
-
-			return
-		}()
+	err := cascade(ctx, conn, result, func(ctx context.Context, result *domain.CascadeResult) error {
+		if action == nil {
+			return deleteComment(ctx, conn, id, result, nil)
+		}
 
-		go func() {
-			defer wg.Done()
+		// a retried transaction collects the cascade again
+		action.Cascade = nil
+		err := deleteComment(ctx, conn, id, result, action)
 
-			_, err = conn.RepliesCollection.DeleteMany(context.TODO(), bson.D{{"resourceId", c.Comment.Id}})
-			if err != nil {
-				panic(err)
-			}
+		if err != nil {
+			return err
+		}
 
-			return
-		}()
+		return insertAction(ctx, conn, action)
+	})
 
-		wg.Wait()
+	if err != nil {
 		return nil, err
 	}
 
-	_, err = session.WithTransaction(context.Background(), callback, txnOpts)
-
-	if err != nil {
-		return err
+	if action != nil {
+		announceAction(ctx, conn, action)
 	}
 
-	return nil
+	return result, nil
 }
 
-func NewCommentRepoImpl() CommentRepoImpl {
-	var commentRepoImpl CommentRepoImpl
-
-	return commentRepoImpl
+func NewCommentRepoImpl(conn *database.Connection) CommentRepoImpl {
+	return CommentRepoImpl{conn: conn}
 }
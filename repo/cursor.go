@@ -0,0 +1,22 @@
+package repo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// afterFilter matches the documents older than after. Object ids start with their creation time, so walking
+// _id downwards is newest first and stays stable while documents are added, unlike skipping pages.
+func afterFilter(after primitive.ObjectID) bson.M {
+	if after.IsZero() {
+		return bson.M{}
+	}
+	return bson.M{"_id": bson.M{"$lt": after}}
+}
+
+// afterOptions sorts newest first and reads one document past the limit, so the caller can tell whether
+// there's another page without counting
+func afterOptions(limit int) *options.FindOptions {
+	return options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit) + 1)
+}
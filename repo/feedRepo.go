@@ -0,0 +1,14 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FeedRepo interface {
+	Create(ctx context.Context, event *domain.FeedEvent) error
+	FindAfter(ctx context.Context, after primitive.ObjectID, limit int) ([]domain.FeedEvent, error)
+	Exists(ctx context.Context, id primitive.ObjectID) (bool, error)
+	Tail(ctx context.Context, after primitive.ObjectID, fn func(domain.FeedEvent)) (primitive.ObjectID, error)
+}
@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// feedSkew is how much older than the event written before it an event's id can be and still be read in order
+const feedSkew = time.Minute
+
+// FeedRepoImpl keeps the feed in a capped collection, the oldest events make room for new ones and every
+// instance can tail it for the events the others wrote
+type FeedRepoImpl struct {
+	conn *database.Connection
+}
+
+func (f FeedRepoImpl) Create(ctx context.Context, event *domain.FeedEvent) error {
+	conn := f.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	if event.Id.IsZero() {
+		event.Id = primitive.NewObjectID()
+	}
+
+	_, err := conn.FeedCollection.InsertOne(ctx, event)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// FindAfter lists up to limit events written after the event with id after, in the order they were written. Ids
+// are made on every instance and written from goroutines, so the feed is read in its natural order, from a
+// little before after passing over everything up to it.
+func (f FeedRepoImpl) FindAfter(ctx context.Context, after primitive.ObjectID, limit int) ([]domain.FeedEvent, error) {
+	conn := f.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "$natural", Value: 1}})
+	cur, err := conn.FeedCollection.Find(ctx, feedWindow(after), opts)
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	defer cur.Close(context.Background())
+
+	events := []domain.FeedEvent{}
+	passed := false
+	for len(events) < limit && cur.Next(ctx) {
+		var event domain.FeedEvent
+		if err = cur.Decode(&event); err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		if !passed {
+			passed = event.Id == after
+			continue
+		}
+		events = append(events, event)
+	}
+
+	if err = cur.Err(); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return events, nil
+}
+
+// Exists reports whether the event is still in the feed, older events have been overwritten
+func (f FeedRepoImpl) Exists(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	conn := f.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	n, err := conn.FeedCollection.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+
+	if err != nil {
+		return false, apperrors.Internal(err)
+	}
+
+	return n > 0, nil
+}
+
+// Tail calls fn with every event written after the event with id after, in the order they were written, until
+// ctx is done or the cursor dies, then returns the id of the last event it saw so the caller can pick up from
+// there. A zero after starts with the events written from now on. An after that has been overwritten can't be
+// found to start behind, everything from a little before it is passed on and the caller drops what it's seen.
+func (f FeedRepoImpl) Tail(ctx context.Context, after primitive.ObjectID, fn func(domain.FeedEvent)) (primitive.ObjectID, error) {
+	conn := f.conn
+
+	if after.IsZero() {
+		latest, err := f.latest(ctx)
+		if err != nil {
+			return after, err
+		}
+		after = latest
+	}
+
+	passed := after.IsZero()
+	if !passed {
+		ok, err := f.Exists(ctx, after)
+		if err != nil {
+			return after, err
+		}
+		passed = !ok
+	}
+
+	// a tailable cursor follows the natural order
+	opts := options.Find().SetCursorType(options.TailableAwait).SetMaxAwaitTime(time.Second)
+	cur, err := conn.FeedCollection.Find(ctx, feedWindow(after), opts)
+
+	if err != nil {
+		return after, apperrors.Internal(err)
+	}
+	defer cur.Close(context.Background())
+
+	for cur.Next(ctx) {
+		var event domain.FeedEvent
+		if err = cur.Decode(&event); err != nil {
+			return after, apperrors.Internal(err)
+		}
+		if !passed {
+			passed = event.Id == after
+			continue
+		}
+		after = event.Id
+		fn(event)
+	}
+
+	return after, apperrors.Internal(cur.Err())
+}
+
+// latest is the id of the last event written, zero while the feed is empty
+func (f FeedRepoImpl) latest(ctx context.Context) (primitive.ObjectID, error) {
+	conn := f.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	var event struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "$natural", Value: -1}}).SetProjection(bson.M{"_id": 1})
+	err := conn.FeedCollection.FindOne(ctx, bson.M{}, opts).Decode(&event)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return primitive.NilObjectID, apperrors.Internal(err)
+	}
+
+	return event.Id, nil
+}
+
+// feedWindow matches the events with an id made no more than feedSkew before after, or with no after since
+// feedSkew ago. An event written after another can carry an older id when it was made on a host whose clock is
+// behind or by a goroutine that was slower to write it.
+func feedWindow(after primitive.ObjectID) bson.M {
+	since := time.Now()
+	if !after.IsZero() {
+		since = after.Timestamp()
+	}
+	return bson.M{"_id": bson.M{"$gte": primitive.NewObjectIDFromTimestamp(since.Add(-feedSkew))}}
+}
+
+func NewFeedRepoImpl(conn *database.Connection) FeedRepoImpl {
+	return FeedRepoImpl{conn: conn}
+}
+
+// publishFeedEvent adds an event to the moderation feed and queues it for the webhooks that want it, without
+// blocking the caller. The request that caused it may be over by the time it's written, so it gets its own
+// deadline, and a feed that's down never fails the action itself.
+func publishFeedEvent(ctx context.Context, conn *database.Connection, event domain.FeedEvent) {
+	log := logger.FromContext(ctx)
+
+	go func() {
+		ctx, cancel := withTimeout(context.Background(), "write")
+		defer cancel()
+
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+
+		if event.Tags == nil && event.ResourceType == "story" {
+			event.Tags = storyTags(ctx, conn, event.ResourceId)
+		}
+
+		if err := NewFeedRepoImpl(conn).Create(ctx, &event); err != nil {
+			log.Error("error publishing feed event", "type", event.Type, "error", err)
+			return
+		}
+
+		if err := NewWebhookRepoImpl(conn).Enqueue(ctx, event); err != nil {
+			log.Error("error queueing webhook deliveries", "type", event.Type, "error", err)
+		}
+	}()
+}
+
+// PublishMessage adds a message the consumer processed to the moderation feed, when the feed shows it
+func PublishMessage(ctx context.Context, conn *database.Connection, message domain.Envelope) {
+	event, ok := domain.FeedEventOf(message)
+	if !ok {
+		return
+	}
+
+	// a flag's tags are the tags of the story it's against
+	if message.Flag != nil && message.Flag.ResourceType == "story" {
+		event.Tags = storyTags(ctx, conn, message.Flag.FlaggedResource)
+	}
+
+	publishFeedEvent(ctx, conn, event)
+}
+
+// storyTags reads the tags of a story for feed subscribers that follow them, a story that's gone has none
+func storyTags(ctx context.Context, conn *database.Connection, id primitive.ObjectID) []string {
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	var story struct {
+		Tags []domain.Tag `bson:"tags"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"tags": 1})
+	if err := conn.StoryCollection.FindOne(ctx, bson.M{"_id": id}, opts).Decode(&story); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logger.FromContext(ctx).Warn("error reading story tags for the feed", "story", id.Hex(), "error", err)
+		}
+		return nil
+	}
+
+	tags := []string{}
+	for _, t := range story.Tags {
+		tags = append(tags, strings.ToLower(t.Value))
+	}
+	return tags
+}
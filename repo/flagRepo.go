@@ -0,0 +1,10 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+)
+
+type FlagRepo interface {
+	Create(ctx context.Context, flag *domain.Flag) error
+}
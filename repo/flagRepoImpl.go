@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type FlagRepoImpl struct {
+	conn     *database.Connection
+	Flag     domain.Flag
+	FlagList []domain.Flag
+}
+
+// Create stores a flag, counts the unique flaggers of the resource and queues it for review.
+// Once the count crosses the threshold for the resource type the content is hidden automatically.
+func (f FlagRepoImpl) Create(ctx context.Context, flag *domain.Flag) error {
+	conn := f.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	collection, err := resourceCollection(conn, flag.ResourceType)
+
+	if err != nil {
+		return err
+	}
+
+	err = collection.FindOne(ctx, bson.M{"_id": flag.FlaggedResource}).Err()
+
+	if err != nil {
+		// a flag on something deleted since is turned down, the consumer skips it rather than trying again
+		if err == mongo.ErrNoDocuments {
+			return apperrors.NotFound("resource_not_found", "resource not found")
+		}
+		return apperrors.Internal(err)
+	}
+
+	if flag.Id.IsZero() {
+		flag.Id = primitive.NewObjectID()
+	}
+	flag.CreatedAt = time.Now()
+
+	// a flagger can only flag a resource once, so repeat flags don't move the count
+	opts := options.Update().SetUpsert(true)
+	filter := bson.M{"flaggerID": flag.FlaggerID, "flaggedResource": flag.FlaggedResource}
+	update := bson.M{"$setOnInsert": flag}
+
+	_, err = conn.FlagCollection.UpdateOne(ctx, filter, update, opts)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if flag.ResourceType == "user" {
+		_, err = conn.UserCollection.UpdateOne(ctx, bson.M{"_id": flag.FlaggedResource},
+			bson.M{"$addToSet": bson.M{"flagCount": flag.FlaggerID}})
+
+		if err != nil {
+			return apperrors.Internal(err)
+		}
+	}
+
+	flaggers, err := conn.FlagCollection.Distinct(ctx, "flaggerID", bson.M{"flaggedResource": flag.FlaggedResource})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	item, err := NewReviewRepoImpl(conn).Enqueue(ctx, flag.ResourceType, flag.FlaggedResource, len(flaggers), flag.Reason)
+
+	if err != nil {
+		return err
+	}
+
+	if item.AutoHidden || len(flaggers) < flagThreshold(flag.ResourceType) {
+		return nil
+	}
+
+	// a moderator already confirmed or reversed a hide of this resource, leave it for them to review
+	decided, err := conn.ReviewCollection.CountDocuments(ctx, bson.M{"resourceId": flag.FlaggedResource,
+		"status": bson.M{"$in": []string{domain.ReviewConfirmed, domain.ReviewReversed}}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if decided > 0 {
+		return nil
+	}
+
+	return NewReviewRepoImpl(conn).AutoHide(ctx, item)
+}
+
+func NewFlagRepoImpl(conn *database.Connection) FlagRepoImpl {
+	return FlagRepoImpl{conn: conn}
+}
@@ -1,126 +1,164 @@
 package repo
 
 import (
+	"context"
+	"example.com/app/apperrors"
 	"example.com/app/config"
+	"example.com/app/database"
 	"example.com/app/domain"
 	"example.com/app/events"
-	"fmt"
+	"example.com/app/logger"
+	"example.com/app/metrics"
+	"example.com/app/tracing"
 	"github.com/Shopify/sarama"
-	"github.com/vmihailenco/msgpack/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
 )
 
-func ProcessMessage(message domain.Message) error {
-
-	if message.ResourceType == "user" {
-		// 201 is the created messageType
-		if message.MessageType == 201 {
-			user := message.User
-			err := UserRepoImpl{}.Create(&user)
-
-			if err != nil {
-				return err
+// ProcessMessage applies a message from the user topic to the local copy of the data
+func ProcessMessage(ctx context.Context, conn *database.Connection, message domain.Envelope) error {
+	switch message.Type {
+	case domain.MessageUserCreated:
+		err := NewUserRepoImpl(conn).Create(ctx, message.User)
+		// a message delivered again, or replayed over a copy of the users, finds the user it created
+		if apperrors.Is(err, apperrors.KindConflict) {
+			count, countErr := conn.UserCollection.CountDocuments(ctx, bson.M{"_id": message.User.Id})
+			if countErr == nil && count > 0 {
+				return nil
 			}
+		}
+		return err
+	case domain.MessageUserUpdated:
+		return NewUserRepoImpl(conn).UpdateByID(ctx, message.User)
+	case domain.MessageUserDeleted:
+		err := NewUserRepoImpl(conn).DeleteByID(ctx, message.User.Id)
+		// the user is already gone when the message is delivered again or was never created here
+		if apperrors.Is(err, apperrors.KindNotFound) {
 			return nil
 		}
+		return err
+	case domain.MessageFlagCreated:
+		return NewFlagRepoImpl(conn).Create(ctx, message.Flag)
+	case domain.MessageAppealCreated:
+		appeal := *message.Appeal
+		appeal.Source = "kafka"
+		return NewAppealRepoImpl(conn).Create(ctx, &appeal)
+	}
 
-		// 200 is the updated messageType
-		if message.MessageType == 200 {
-			user := message.User
+	return apperrors.Validation("unknown_message", "cannot process this message")
+}
 
-			err := UserRepoImpl{}.UpdateByID(&user)
-			if err != nil {
-				return err
-			}
-			return nil
-		}
+// MessageIDHeader carries the id of the outbox entry a message was sent from. The relay sends a message again when
+// it dies before marking it sent, so consumers can use the id to drop the copy.
+const MessageIDHeader = "X-Message-ID"
 
-		// 204 is the deleted messageType
-		if message.MessageType == 204 {
-			user := message.User
+// MessageProcessed reports whether the consumer has already applied a message with this id, a copy the relay sent
+// again is skipped
+func MessageProcessed(ctx context.Context, conn *database.Connection, id string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
 
-			err := UserRepoImpl{}.DeleteByID(user.Id)
+	n, err := conn.ProcessedCollection.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
 
-			if err != nil {
-				return err
-			}
-			return nil
-		}
+	if err != nil {
+		return false, apperrors.Internal(err)
 	}
 
-
-	return fmt.Errorf("cannot process this message")
+	return n > 0, nil
 }
 
-func PushUserToQueue(message []byte, topic string) error {
+// MarkProcessed records that the consumer applied the message. The id is the _id, so marking a message twice is
+// a no-op, and the record expires once the relay can't send the message again.
+func MarkProcessed(ctx context.Context, conn *database.Connection, message domain.Envelope) error {
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
 
-	producer := events.GetInstance()
+	_, err := conn.ProcessedCollection.InsertOne(ctx, bson.M{"_id": message.Id, "type": message.Type,
+		"processedAt": time.Now()})
 
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(message),
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return apperrors.Internal(err)
 	}
 
-
-	partition, offset, err := producer.SendMessage(msg)
-	if err != nil {
-		fmt.Println(fmt.Errorf("%v", err))
-		err = producer.Close()
-		if err != nil {
-			panic(err)
-		}
-		fmt.Println("Failed to send message to the queue")
-	}
-
-	fmt.Printf("Message is stored in topic(%s)/partition(%d)/offset(%d)\n", "user", partition, offset)
 	return nil
 }
 
-func SendKafkaMessage(story *domain.Story, eventType int) error {
-	um := new(domain.Message)
-	um.Story = *story
-
-	// user created/updated event
-	um.MessageType = eventType
-	um.ResourceType = "story"
+// PushUserToQueue sends the message with headers and the request id and trace context of ctx,
+// so consumers can log and trace it as part of the same request
+func PushUserToQueue(ctx context.Context, message []byte, topic string, headers map[string]string) error {
+	log := logger.FromContext(ctx)
+	id := headers[MessageIDHeader]
 
-	fmt.Println(um.Story)
-	//turn user struct into a byte array
-	b, err := msgpack.Marshal(um)
+	ctx, span := tracing.Start(ctx, "kafka.produce "+topic, tracing.KindProducer,
+		"messaging.system", "kafka", "messaging.destination", topic, "messaging.message_id", id)
+	defer span.End()
 
+	producer, err := events.Producer()
 	if err != nil {
+		metrics.KafkaProduced.Inc(topic, metrics.Result(err))
+		span.RecordError(err)
 		return err
 	}
 
-	err = PushUserToQueue(b, config.Config("PRODUCER_TOPIC"))
+	all := map[string]string{}
+	for key, value := range headers {
+		all[key] = value
+	}
+
+	if id := logger.RequestID(ctx); id != "" {
+		all[logger.RequestIDHeader] = id
+	}
 
+	tracing.Inject(ctx, func(key string, value string) {
+		all[key] = value
+	})
+
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.StringEncoder(message),
+	}
+
+	for key, value := range all {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+
+	// the producer is shared and retries on its own, a failed send leaves it usable for the next one
+	partition, offset, err := producer.SendMessage(msg)
+	metrics.KafkaProduced.Inc(topic, metrics.Result(err))
+	span.RecordError(err)
 	if err != nil {
+		log.Error("failed to send message to the queue", "topic", topic, "message_id", id, "error", err)
 		return err
 	}
 
+	log.Debug("message stored", "topic", topic, "message_id", id, "partition", partition, "offset", offset)
 	return nil
 }
-func SendEventMessage(event *domain.Event, eventType int) error {
-	um := new(domain.Message)
-	um.Event = *event
 
-	// user created/updated event
-	um.MessageType = eventType
-	um.ResourceType = "event"
+// SendEventMessage adds an event message to the outbox, in a transaction it's only sent if the transaction commits
+func SendEventMessage(ctx context.Context, conn *database.Connection, event *domain.Event) error {
+	return queueEnvelope(ctx, conn, "event", &domain.Envelope{Type: domain.MessageEventCreated, Event: event})
+}
 
-	fmt.Println(um.Event)
-	//turn user struct into a byte array
-	b, err := msgpack.Marshal(um)
+// queueEnvelope fills in the envelope and adds it to the outbox written with KAFKA_MESSAGE_CODEC. The envelope id
+// is the id of the outbox entry, so a message sent again has the same one.
+func queueEnvelope(ctx context.Context, conn *database.Connection, topic string, envelope *domain.Envelope) error {
+	codec, _ := events.CodecNamed(config.Get().MessageCodec)
+	id := primitive.NewObjectID()
 
-	if err != nil {
-		return err
-	}
+	envelope.SchemaVersion = domain.MessageSchemaVersion
+	envelope.Id = id.Hex()
+	envelope.Timestamp = time.Now()
+	envelope.Producer = config.Get().TracingServiceName
 
-	err = PushUserToQueue(b, "event")
+	b, err := events.Encode(codec, envelope)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return queueMessage(ctx, conn, id, topic, b, map[string]string{events.ContentTypeHeader: codec.ContentType()})
 }
-
@@ -0,0 +1,14 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ModerationActionRepo interface {
+	Snapshot(ctx context.Context, action string, resourceType string, id primitive.ObjectID, actor string) (*domain.ModerationAction, error)
+	Create(ctx context.Context, action *domain.ModerationAction) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*domain.ModerationAction, error)
+	Undo(ctx context.Context, action *domain.ModerationAction) error
+}
@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+type ModerationActionRepoImpl struct {
+	conn             *database.Connection
+	ModerationAction domain.ModerationAction
+}
+
+// Snapshot copies the resource as it is now so the action can be undone later.
+// It has to be called before the action is carried out.
+func (m ModerationActionRepoImpl) Snapshot(ctx context.Context, action string, resourceType string, id primitive.ObjectID, actor string) (*domain.ModerationAction, error) {
+	conn := m.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	collection, err := resourceCollection(conn, resourceType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := collection.FindOne(ctx, bson.M{"_id": id}).DecodeBytes()
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("resource_not_found", "resource not found")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	// users are identified by their username, content by its author
+	targetField := "authorUsername"
+	if resourceType == "user" {
+		targetField = "username"
+	}
+	target, _ := raw.Lookup(targetField).StringValueOK()
+
+	m.ModerationAction = domain.ModerationAction{
+		Id:             primitive.NewObjectID(),
+		Action:         action,
+		ResourceType:   resourceType,
+		ResourceId:     id,
+		ActorUsername:  actor,
+		TargetUsername: target,
+		Snapshot:       raw,
+		CreatedAt:      time.Now(),
+	}
+
+	return &m.ModerationAction, nil
+}
+
+// actionPastTense words the feed messages of recorded actions
+var actionPastTense = map[string]string{
+	domain.ActionDelete:    "deleted",
+	domain.ActionLock:      "locked",
+	domain.ActionExport:    "exported",
+	domain.ActionErase:     "erased",
+	domain.ActionAnonymise: "purged",
+}
+
+func (m ModerationActionRepoImpl) Create(ctx context.Context, action *domain.ModerationAction) error {
+	conn := m.conn
+
+	err := insertAction(ctx, conn, action)
+
+	if err != nil {
+		return err
+	}
+
+	announceAction(ctx, conn, action)
+
+	return nil
+}
+
+// insertAction records an action. In the transaction of the change it records, the change can't happen without a
+// record to appeal it by, and announceAction is called once it's committed.
+func insertAction(ctx context.Context, conn *database.Connection, action *domain.ModerationAction) error {
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	_, err := conn.ActionCollection.InsertOne(ctx, action)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// announceAction puts a recorded action in the moderation feed
+func announceAction(ctx context.Context, conn *database.Connection, action *domain.ModerationAction) {
+	event := domain.FeedEvent{Type: domain.FeedModeration + action.Action, ResourceType: action.ResourceType,
+		ResourceId: action.ResourceId, Actor: action.ActorUsername,
+		Message: action.ActorUsername + " " + actionPastTense[action.Action] + " the " + action.ResourceType}
+
+	// a deleted story can't be looked up any more, its tags are in the snapshot
+	var snapshot struct {
+		Tags []domain.Tag `bson:"tags"`
+	}
+	if action.ResourceType == "story" && bson.Unmarshal(action.Snapshot, &snapshot) == nil {
+		event.Tags = []string{}
+		for _, t := range snapshot.Tags {
+			event.Tags = append(event.Tags, strings.ToLower(t.Value))
+		}
+	}
+
+	publishFeedEvent(ctx, conn, event)
+}
+
+func (m ModerationActionRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*domain.ModerationAction, error) {
+	conn := m.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.ActionCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&m.ModerationAction)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("action_not_found", "cannot find moderation action")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &m.ModerationAction, nil
+}
+
+// Undo puts a deleted resource back from its snapshot, with the comments, replies and flags its delete removed,
+// or unlocks a locked user
+func (m ModerationActionRepoImpl) Undo(ctx context.Context, action *domain.ModerationAction) error {
+	conn := m.conn
+	ctx, cancel := withTimeout(ctx, "cascade")
+	defer cancel()
+
+	switch action.Action {
+	case domain.ActionDelete:
+		collection, err := resourceCollection(conn, action.ResourceType)
+
+		if err != nil {
+			return err
+		}
+
+		return transaction(ctx, conn, func(ctx context.Context, _ bool) error {
+			_, err := collection.InsertOne(ctx, action.Snapshot)
+
+			for _, d := range action.Cascade {
+				if err != nil {
+					break
+				}
+				_, err = conn.Collection(d.Collection).InsertOne(ctx, d.Document)
+			}
+
+			if mongo.IsDuplicateKeyError(err) {
+				return apperrors.Conflict("resource_exists", "resource already exists")
+			}
+
+			return apperrors.Internal(err)
+		})
+	case domain.ActionLock:
+		return NewUserRepoImpl(conn).LockByID(ctx, action.ResourceId, false)
+	default:
+		return apperrors.Conflict("action_not_undoable", "cannot undo this action")
+	}
+}
+
+func NewModerationActionRepoImpl(conn *database.Connection) ModerationActionRepoImpl {
+	return ModerationActionRepoImpl{conn: conn}
+}
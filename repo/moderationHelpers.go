@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/config"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+	"strings"
+)
+
+// defaultFlagThresholds is the number of unique flaggers it takes to auto-hide each resource type
+var defaultFlagThresholds = map[string]int{
+	"story":   5,
+	"comment": 5,
+	"reply":   5,
+	"user":    10,
+}
+
+// flagThreshold reads FLAG_THRESHOLD_<TYPE> from the config and falls back to the defaults
+func flagThreshold(resourceType string) int {
+	t, err := strconv.Atoi(config.Value("FLAG_THRESHOLD_" + strings.ToUpper(resourceType)))
+
+	if err != nil || t <= 0 {
+		return defaultFlagThresholds[resourceType]
+	}
+
+	return t
+}
+
+// resourceCollection maps a resource type to the collection that stores it
+func resourceCollection(conn *database.Connection, resourceType string) (*mongo.Collection, error) {
+	switch resourceType {
+	case "story":
+		return conn.StoryCollection, nil
+	case "comment":
+		return conn.CommentsCollection, nil
+	case "reply":
+		return conn.RepliesCollection, nil
+	case "user":
+		return conn.UserCollection, nil
+	default:
+		return nil, apperrors.Validation("invalid_resource_type", "invalid resource type")
+	}
+}
+
+// notAuthor explains a write filtered by the author that matched nothing, it's notFound when there's no resource with
+// the id and forbidden when someone else wrote it
+func notAuthor(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID, notFound error, forbidden error) error {
+	count, err := collection.CountDocuments(ctx, bson.M{"_id": id})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if count == 0 {
+		return notFound
+	}
+
+	return forbidden
+}
+
+// moderationEvent is the event topic message for a moderation action
+func moderationEvent(action string, resourceType string, resourceId primitive.ObjectID, actor string, message string) *domain.Event {
+	return &domain.Event{Action: action, Target: resourceType, ResourceId: resourceId, ActorUsername: actor, Message: message}
+}
+
+// publishModerationEvent adds a moderation action to the outbox and announces it. A change made in a transaction
+// queues the event with SendEventMessage inside it and calls announceModerationEvent once it's committed instead.
+func publishModerationEvent(ctx context.Context, conn *database.Connection, event *domain.Event) {
+	if err := SendEventMessage(ctx, conn, event); err != nil {
+		logger.FromContext(ctx).Error("error queueing moderation event", "action", event.Action, "error", err)
+	}
+
+	announceModerationEvent(ctx, conn, event)
+}
+
+// announceModerationEvent counts a moderation action and adds it to the moderation feed without blocking the caller
+func announceModerationEvent(ctx context.Context, conn *database.Connection, event *domain.Event) {
+	metrics.ModerationActions.Inc(event.Action, event.Target)
+
+	publishFeedEvent(ctx, conn, domain.FeedEvent{Type: domain.FeedModeration + event.Action, ResourceType: event.Target,
+		ResourceId: event.ResourceId, Actor: event.ActorUsername, Message: event.Message})
+}
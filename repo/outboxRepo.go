@@ -0,0 +1,15 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type OutboxRepo interface {
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*domain.OutboxEntry, error)
+	Publish(ctx context.Context, entry *domain.OutboxEntry) error
+	MarkSent(ctx context.Context, id primitive.ObjectID) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID, cause error, next time.Time) error
+}
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type OutboxRepoImpl struct {
+	conn  *database.Connection
+	Entry domain.OutboxEntry
+}
+
+// queueMessage writes a message to the outbox for the relay to send. With the session context of a transaction
+// it's part of the transaction, so the message is sent exactly when the change it announces is committed.
+func queueMessage(ctx context.Context, conn *database.Connection, id primitive.ObjectID, topic string, message []byte,
+	headers map[string]string) error {
+	now := time.Now()
+	entry := domain.OutboxEntry{
+		Id:            id,
+		Topic:         topic,
+		Payload:       message,
+		Headers:       headers,
+		Status:        domain.OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	if id := logger.RequestID(ctx); id != "" {
+		entry.Headers[logger.RequestIDHeader] = id
+	}
+
+	tracing.Inject(ctx, func(key string, value string) {
+		entry.Headers[key] = value
+	})
+
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	_, err := conn.OutboxCollection.InsertOne(ctx, &entry)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// ClaimDue takes the pending entry that is due longest and pushes its next attempt back by lease, so no other
+// instance sends it meanwhile and it's sent again if this one dies. It returns nil when nothing is due.
+func (o OutboxRepoImpl) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*domain.OutboxEntry, error) {
+	conn := o.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+	filter := bson.M{"status": domain.OutboxPending, "nextAttemptAt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"nextAttemptAt": now.Add(lease)}}
+
+	err := conn.OutboxCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&o.Entry)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &o.Entry, nil
+}
+
+// Publish sends an entry to kafka with the request id and trace of the request that wrote it
+func (o OutboxRepoImpl) Publish(ctx context.Context, entry *domain.OutboxEntry) error {
+	if id, ok := entry.Headers[logger.RequestIDHeader]; ok {
+		ctx = logger.WithRequestID(ctx, id)
+	}
+
+	ctx = tracing.Extract(ctx, func(key string) string { return entry.Headers[key] })
+
+	headers := map[string]string{MessageIDHeader: entry.Id.Hex()}
+	for key, value := range entry.Headers {
+		headers[key] = value
+	}
+
+	return PushUserToQueue(ctx, entry.Payload, entry.Topic, headers)
+}
+
+func (o OutboxRepoImpl) MarkSent(ctx context.Context, id primitive.ObjectID) error {
+	conn := o.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	_, err := conn.OutboxCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"status": domain.OutboxSent, "sentAt": time.Now()},
+		"$inc":   bson.M{"attempts": 1},
+		"$unset": bson.M{"lastError": ""},
+	})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// MarkFailed keeps the entry pending and records why sending it failed, next is when it's tried again
+func (o OutboxRepoImpl) MarkFailed(ctx context.Context, id primitive.ObjectID, cause error, next time.Time) error {
+	conn := o.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	_, err := conn.OutboxCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"lastError": cause.Error(), "nextAttemptAt": next},
+		"$inc": bson.M{"attempts": 1},
+	})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+func NewOutboxRepoImpl(conn *database.Connection) OutboxRepoImpl {
+	return OutboxRepoImpl{conn: conn}
+}
@@ -0,0 +1,18 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type PrivacyRepo interface {
+	Export(ctx context.Context, id primitive.ObjectID, actor string) (*domain.UserExport, error)
+	CreateErasure(ctx context.Context, request *domain.ErasureRequest) error
+	FindErasureById(ctx context.Context, id primitive.ObjectID) (*domain.ErasureRequest, error)
+	CancelErasure(ctx context.Context, id primitive.ObjectID) error
+	ClaimDueErasure(ctx context.Context, now time.Time) (*domain.ErasureRequest, error)
+	Erase(ctx context.Context, request *domain.ErasureRequest) (*domain.ErasureCertificate, error)
+	FailErasure(ctx context.Context, id primitive.ObjectID, cause error) error
+}
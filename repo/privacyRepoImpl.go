@@ -0,0 +1,408 @@
+package repo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"example.com/app/apperrors"
+	"example.com/app/config"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// erasedUserFields are the personal fields removed from the user document on erasure
+var erasedUserFields = []string{
+	"email",
+	"password",
+	"lastLoginIp",
+	"lastLoginIps",
+	"profilePictureUrl",
+	"profileBackgroundPictureUrl",
+	"currentBadgeUrl",
+	"currentTagLine",
+}
+
+type PrivacyRepoImpl struct {
+	conn           *database.Connection
+	ErasureRequest domain.ErasureRequest
+}
+
+// Export collects the user document, their content, the flags filed by and against them and the
+// moderation actions that involve them. The export itself is recorded as a moderation action.
+func (p PrivacyRepoImpl) Export(ctx context.Context, id primitive.ObjectID, actor string) (*domain.UserExport, error) {
+	conn := p.conn
+	ctx, cancel := withTimeout(ctx, "export")
+	defer cancel()
+
+	export := new(domain.UserExport)
+
+	opts := options.FindOne().SetProjection(bson.M{"password": 0})
+	err := conn.UserCollection.FindOne(ctx, bson.M{"_id": id}, opts).Decode(&export.User)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("user_not_found", "cannot find user")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	username, _ := export.User["username"].(string)
+	authored := bson.M{"authorUsername": username}
+
+	if export.Stories, err = findAll(ctx, conn.StoryCollection, authored); err != nil {
+		return nil, err
+	}
+	if export.Comments, err = findAll(ctx, conn.CommentsCollection, authored); err != nil {
+		return nil, err
+	}
+	if export.Replies, err = findAll(ctx, conn.RepliesCollection, authored); err != nil {
+		return nil, err
+	}
+	if export.FlagsFiled, err = findAll(ctx, conn.FlagCollection, bson.M{"flaggerID": id}); err != nil {
+		return nil, err
+	}
+
+	// flags against the user are the ones on their profile and on anything they wrote
+	flagged := []primitive.ObjectID{id}
+	for _, list := range [][]bson.M{export.Stories, export.Comments, export.Replies} {
+		for _, doc := range list {
+			if oid, ok := doc["_id"].(primitive.ObjectID); ok {
+				flagged = append(flagged, oid)
+			}
+		}
+	}
+
+	if export.FlagsAgainst, err = findAll(ctx, conn.FlagCollection, bson.M{"flaggedResource": bson.M{"$in": flagged}}); err != nil {
+		return nil, err
+	}
+	if export.Audit, err = findAll(ctx, conn.ActionCollection, bson.M{"$or": []interface{}{
+		bson.M{"targetUsername": username},
+		bson.M{"resourceId": id},
+	}}); err != nil {
+		return nil, err
+	}
+
+	err = NewModerationActionRepoImpl(conn).Create(ctx, &domain.ModerationAction{
+		Id:             primitive.NewObjectID(),
+		Action:         domain.ActionExport,
+		ResourceType:   "user",
+		ResourceId:     id,
+		ActorUsername:  actor,
+		TargetUsername: username,
+		CreatedAt:      time.Now(),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func (p PrivacyRepoImpl) CreateErasure(ctx context.Context, request *domain.ErasureRequest) error {
+	conn := p.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	count, err := conn.UserCollection.CountDocuments(ctx, bson.M{"_id": request.UserId})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if count == 0 {
+		return apperrors.NotFound("user_not_found", "cannot find user")
+	}
+
+	count, err = conn.ErasureCollection.CountDocuments(ctx, bson.M{"userId": request.UserId,
+		"status": bson.M{"$in": []string{domain.ErasureScheduled, domain.ErasureProcessing}}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if count > 0 {
+		return apperrors.Conflict("erasure_scheduled", "erasure already scheduled")
+	}
+
+	request.Id = primitive.NewObjectID()
+	request.Status = domain.ErasureScheduled
+	request.CreatedAt = time.Now()
+
+	_, err = conn.ErasureCollection.InsertOne(ctx, request)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+func (p PrivacyRepoImpl) FindErasureById(ctx context.Context, id primitive.ObjectID) (*domain.ErasureRequest, error) {
+	conn := p.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.ErasureCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&p.ErasureRequest)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("erasure_not_found", "cannot find erasure request")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &p.ErasureRequest, nil
+}
+
+// CancelErasure stops an erasure that is still in its cooling off period
+func (p PrivacyRepoImpl) CancelErasure(ctx context.Context, id primitive.ObjectID) error {
+	conn := p.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	res, err := conn.ErasureCollection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": domain.ErasureScheduled},
+		bson.M{"$set": bson.M{"status": domain.ErasureCancelled}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return apperrors.Conflict("erasure_not_cancellable", "erasure can no longer be cancelled")
+	}
+
+	return nil
+}
+
+// ClaimDueErasure marks the oldest erasure whose cooling off period is over as processing and returns it,
+// it returns nil when nothing is due
+func (p PrivacyRepoImpl) ClaimDueErasure(ctx context.Context, now time.Time) (*domain.ErasureRequest, error) {
+	conn := p.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "scheduledFor", Value: 1}}).
+		SetReturnDocument(options.After)
+	filter := bson.M{"status": domain.ErasureScheduled, "scheduledFor": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"status": domain.ErasureProcessing}}
+
+	err := conn.ErasureCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&p.ErasureRequest)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &p.ErasureRequest, nil
+}
+
+// Erase anonymises the user's content, removes their personal data and stores a certificate of completion
+func (p PrivacyRepoImpl) Erase(ctx context.Context, request *domain.ErasureRequest) (*domain.ErasureCertificate, error) {
+	conn := p.conn
+	ctx, cancel := withTimeout(ctx, "cascade")
+	defer cancel()
+
+	user := new(domain.User)
+	err := conn.UserCollection.FindOne(ctx, bson.M{"_id": request.UserId}).Decode(user)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("user_not_found", "cannot find user")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	certificate := &domain.ErasureCertificate{
+		RequestId:    request.Id,
+		UserId:       request.UserId,
+		UsernameHash: usernameHash(user.Username),
+		ErasedFields: append([]string{"username"}, erasedUserFields...),
+		Anonymised:   domain.PurgeResult{Mode: domain.PurgeAnonymise},
+	}
+
+	err = anonymiseAuthored(ctx, conn, user.Username, &certificate.Anonymised)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := conn.FlagCollection.DeleteMany(ctx, bson.M{"flaggerID": request.UserId})
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	certificate.Anonymised.Flags = res.DeletedCount
+
+	// old moderation records keep a copy of the user, drop it along with their name
+	_, err = conn.ActionCollection.UpdateMany(ctx, bson.M{"targetUsername": user.Username},
+		bson.M{"$set": bson.M{"targetUsername": domain.AnonymousUsername}, "$unset": bson.M{"snapshot": ""}})
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	_, err = conn.AppealCollection.UpdateMany(ctx, bson.M{"appellantUsername": user.Username},
+		bson.M{"$set": bson.M{"appellantUsername": domain.AnonymousUsername, "statement": ""}})
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	// usernames have to stay unique, so the erased user keeps a placeholder based on their id
+	placeholder := "erased-" + request.UserId.Hex()
+
+	err = scrubCases(ctx, conn, request.UserId, user.Username, placeholder)
+
+	if err != nil {
+		return nil, err
+	}
+
+	unset := bson.M{}
+	for _, field := range erasedUserFields {
+		unset[field] = ""
+	}
+
+	_, err = conn.UserCollection.UpdateOne(ctx, bson.M{"_id": request.UserId}, bson.M{
+		"$unset": unset,
+		"$set":   bson.M{"username": placeholder, "isLocked": true, "hidden": true},
+	})
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	certificate.CompletedAt = time.Now()
+
+	b, err := json.Marshal(certificate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certificate.Digest = fmt.Sprintf("%x", sha256.Sum256(b))
+
+	_, err = conn.ErasureCollection.UpdateOne(ctx, bson.M{"_id": request.Id}, bson.M{"$set": bson.M{
+		"status":      domain.ErasureCompleted,
+		"completedAt": certificate.CompletedAt,
+		"certificate": certificate,
+	}})
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	err = NewModerationActionRepoImpl(conn).Create(ctx, &domain.ModerationAction{
+		Id:             primitive.NewObjectID(),
+		Action:         domain.ActionErase,
+		ResourceType:   "user",
+		ResourceId:     request.UserId,
+		ActorUsername:  request.RequestedBy,
+		TargetUsername: domain.AnonymousUsername,
+		CreatedAt:      certificate.CompletedAt,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	publishModerationEvent(ctx, conn, moderationEvent("erase", "user", request.UserId, request.RequestedBy, "user data was erased"))
+
+	return certificate, nil
+}
+
+func (p PrivacyRepoImpl) FailErasure(ctx context.Context, id primitive.ObjectID, cause error) error {
+	conn := p.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	_, err := conn.ErasureCollection.UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"status": domain.ErasureFailed, "error": cause.Error()}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+func findAll(ctx context.Context, collection *mongo.Collection, filter bson.M) ([]bson.M, error) {
+	docs := []bson.M{}
+
+	cur, err := collection.Find(ctx, filter)
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	if err = cur.All(ctx, &docs); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return docs, nil
+}
+
+// scrubCases takes the user out of case files: evidence of the user loses the erased fields and their username,
+// evidence of what they wrote loses their username and the case's usernames list names the placeholder instead
+func scrubCases(ctx context.Context, conn *database.Connection, id primitive.ObjectID, username string, placeholder string) error {
+	unset := bson.M{}
+	for _, field := range erasedUserFields {
+		unset["evidence.$[e].snapshot."+field] = ""
+	}
+
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+		bson.M{"e.resourceType": "user", "e.resourceId": id},
+	}})
+	_, err := conn.CaseCollection.UpdateMany(ctx,
+		bson.M{"evidence": bson.M{"$elemMatch": bson.M{"resourceType": "user", "resourceId": id}}},
+		bson.M{"$unset": unset, "$set": bson.M{"evidence.$[e].snapshot.username": placeholder}}, opts)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	opts = options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+		bson.M{"a.snapshot.authorUsername": username},
+	}})
+	_, err = conn.CaseCollection.UpdateMany(ctx, bson.M{"evidence.snapshot.authorUsername": username},
+		bson.M{"$set": bson.M{"evidence.$[a].snapshot.authorUsername": domain.AnonymousUsername}}, opts)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	opts = options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"u": username}}})
+	_, err = conn.CaseCollection.UpdateMany(ctx, bson.M{"usernames": username},
+		bson.M{"$set": bson.M{"usernames.$[u]": placeholder}}, opts)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// usernameHash identifies an erased user in their certificate. It's keyed with the server secret, a plain hash of
+// a username is short enough to be guessed back.
+func usernameHash(username string) string {
+	mac := hmac.New(sha256.New, []byte(config.Get().Secret))
+	mac.Write([]byte(username))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewPrivacyRepoImpl(conn *database.Connection) PrivacyRepoImpl {
+	return PrivacyRepoImpl{conn: conn}
+}
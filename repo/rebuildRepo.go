@@ -0,0 +1,20 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RebuildRepo interface {
+	Create(ctx context.Context, job *domain.RebuildJob) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*domain.RebuildJob, error)
+	Update(ctx context.Context, job *domain.RebuildJob) error
+	RequestCancel(ctx context.Context, id primitive.ObjectID) error
+	Swapping(ctx context.Context) (*domain.RebuildJob, error)
+	Finish(ctx context.Context, id primitive.ObjectID, status string, cause string) error
+	PrepareShadow(ctx context.Context, shadow string, seed bool) error
+	Apply(ctx context.Context, shadow string, message domain.Envelope) error
+	Swap(ctx context.Context, id primitive.ObjectID, shadow string) error
+	DropShadow(ctx context.Context, shadow string) error
+}
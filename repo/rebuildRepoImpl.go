@@ -0,0 +1,365 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// rebuildAbandoned is how long a running rebuild can go without saving its progress before it's taken to have
+// died with its instance
+const rebuildAbandoned = 2 * time.Minute
+
+type RebuildRepoImpl struct {
+	conn *database.Connection
+	Job  domain.RebuildJob
+}
+
+// Create starts a rebuild. Only one runs at a time, a rebuild that stopped saving its progress is failed first so
+// it doesn't hold up the new one.
+func (r RebuildRepoImpl) Create(ctx context.Context, job *domain.RebuildJob) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	now := time.Now()
+	cur, err := conn.RebuildCollection.Find(ctx, bson.M{"status": domain.JobRunning,
+		"updatedAt": bson.M{"$lt": now.Add(-rebuildAbandoned)}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	var abandoned []domain.RebuildJob
+	if err = cur.All(ctx, &abandoned); err != nil {
+		return apperrors.Internal(err)
+	}
+
+	for _, a := range abandoned {
+		err = r.Finish(ctx, a.Id, domain.JobFailed, "abandoned")
+		if err != nil {
+			return err
+		}
+
+		err = r.DropShadow(ctx, a.Shadow)
+		if err != nil {
+			return err
+		}
+	}
+
+	job.Id = primitive.NewObjectID()
+	job.Status = domain.JobRunning
+	job.Phase = domain.RebuildReplaying
+	job.Shadow = conn.UserCollection.Name() + "_rebuild_" + job.Id.Hex()
+	job.Partitions = []domain.RebuildPartition{}
+	job.Errors = []string{}
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	_, err = conn.RebuildCollection.InsertOne(ctx, job)
+
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.Conflict("rebuild_running", "a rebuild is already running")
+		}
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+func (r RebuildRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*domain.RebuildJob, error) {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.RebuildCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&r.Job)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("rebuild_not_found", "cannot find rebuild")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &r.Job, nil
+}
+
+// Update saves the progress of a running rebuild, a rebuild that was failed as abandoned stays failed
+func (r RebuildRepoImpl) Update(ctx context.Context, job *domain.RebuildJob) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	job.UpdatedAt = time.Now()
+	res, err := conn.RebuildCollection.UpdateOne(ctx, bson.M{"_id": job.Id, "status": domain.JobRunning},
+		bson.M{"$set": bson.M{
+			"phase":      job.Phase,
+			"partitions": job.Partitions,
+			"applied":    job.Applied,
+			"skipped":    job.Skipped,
+			"failed":     job.Failed,
+			"errors":     job.Errors,
+			"progress":   job.Progress,
+			"updatedAt":  job.UpdatedAt,
+		}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return apperrors.Conflict("job_not_running", "job is not running")
+	}
+
+	return nil
+}
+
+// RequestCancel marks a running rebuild to stop, the instance running it sees the mark when it next checks
+func (r RebuildRepoImpl) RequestCancel(ctx context.Context, id primitive.ObjectID) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	res, err := conn.RebuildCollection.UpdateOne(ctx, bson.M{"_id": id, "status": domain.JobRunning},
+		bson.M{"$set": bson.M{"cancelRequested": true}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	if _, err = r.FindById(ctx, id); err != nil {
+		return err
+	}
+
+	return apperrors.Conflict("job_not_running", "job is not running")
+}
+
+// Swapping returns the rebuild that is swapping its shadow in on any instance, nil when there is none. One that
+// stopped saving its progress has died and is left out.
+func (r RebuildRepoImpl) Swapping(ctx context.Context) (*domain.RebuildJob, error) {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.RebuildCollection.FindOne(ctx, bson.M{"status": domain.JobRunning, "phase": domain.RebuildSwapping,
+		"updatedAt": bson.M{"$gte": time.Now().Add(-rebuildAbandoned)}}).Decode(&r.Job)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &r.Job, nil
+}
+
+func (r RebuildRepoImpl) Finish(ctx context.Context, id primitive.ObjectID, status string, cause string) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{"status": status, "error": cause, "finishedAt": now, "updatedAt": now}
+	if status == domain.JobCompleted {
+		set["progress"] = 100
+	}
+
+	_, err := conn.RebuildCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// PrepareShadow creates the shadow collection empty with the indexes of the users collection. With seed it
+// starts as a copy of the users, for a replay from an offset to apply its messages on top of.
+func (r RebuildRepoImpl) PrepareShadow(ctx context.Context, shadow string, seed bool) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "rebuild")
+	defer cancel()
+
+	err := r.DropShadow(ctx, shadow)
+	if err != nil {
+		return err
+	}
+
+	cur, err := conn.UserCollection.Indexes().List(ctx)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	var specs []bson.M
+	if err = cur.All(ctx, &specs); err != nil {
+		return apperrors.Internal(err)
+	}
+
+	indexes := bson.A{}
+	for _, spec := range specs {
+		if spec["name"] == "_id_" {
+			continue
+		}
+		delete(spec, "ns")
+		indexes = append(indexes, spec)
+	}
+
+	if len(indexes) > 0 {
+		err = conn.RunCommand(ctx, bson.D{{Key: "createIndexes", Value: shadow}, {Key: "indexes", Value: indexes}}).Err()
+	} else {
+		err = conn.CreateCollection(ctx, shadow)
+	}
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if !seed {
+		return nil
+	}
+
+	return aggregateInto(ctx, conn.UserCollection, bson.A{
+		bson.M{"$merge": bson.M{"into": shadow, "whenMatched": "replace", "whenNotMatched": "insert"}},
+	})
+}
+
+// Apply processes a message against the shadow collection in place of the users collection
+func (r RebuildRepoImpl) Apply(ctx context.Context, shadow string, message domain.Envelope) error {
+	conn := *r.conn
+	conn.UserCollection = conn.Collection(shadow)
+
+	return ProcessMessage(ctx, &conn, message)
+}
+
+// Swap replaces the users collection with the shadow. What only exists locally is carried over first: locks,
+// hides and flags, and erased users stay erased even if the topic still holds their data. Users deleted while
+// the rebuild ran are taken out of the shadow before the rename, and a delete recorded while it happens is
+// applied to the new collection after.
+func (r RebuildRepoImpl) Swap(ctx context.Context, id primitive.ObjectID, shadow string) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "rebuild")
+	defer cancel()
+
+	err := aggregateInto(ctx, conn.UserCollection, bson.A{
+		bson.M{"$project": bson.M{"isLocked": 1, "hidden": 1, "flagCount": 1}},
+		bson.M{"$merge": bson.M{"into": shadow, "whenMatched": "merge", "whenNotMatched": "discard"}},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	erased, err := conn.ErasureCollection.Distinct(ctx, "userId", bson.M{"status": domain.ErasureCompleted})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if len(erased) > 0 {
+		_, err = conn.Collection(shadow).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": erased}})
+
+		if err != nil {
+			return apperrors.Internal(err)
+		}
+
+		err = aggregateInto(ctx, conn.UserCollection, bson.A{
+			bson.M{"$match": bson.M{"_id": bson.M{"$in": erased}}},
+			bson.M{"$merge": bson.M{"into": shadow, "whenMatched": "replace", "whenNotMatched": "insert"}},
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = r.deleteRemoved(ctx, id, conn.Collection(shadow)); err != nil {
+		return err
+	}
+
+	db := conn.Name()
+	err = conn.Client.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: db + "." + shadow},
+		{Key: "to", Value: db + "." + conn.UserCollection.Name()},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return r.deleteRemoved(ctx, id, conn.UserCollection)
+}
+
+// deleteRemoved deletes the users recorded as deleted during the rebuild from collection
+func (r RebuildRepoImpl) deleteRemoved(ctx context.Context, id primitive.ObjectID, collection *mongo.Collection) error {
+	conn := r.conn
+
+	var job domain.RebuildJob
+	opts := options.FindOne().SetProjection(bson.M{"deletedUsers": 1})
+	if err := conn.RebuildCollection.FindOne(ctx, bson.M{"_id": id}, opts).Decode(&job); err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if len(job.DeletedUsers) == 0 {
+		return nil
+	}
+
+	if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": job.DeletedUsers}}); err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// recordDeletedUser adds a user about to be deleted to the running rebuild, if there is one, so the swap
+// doesn't bring them back. It's recorded before the delete, a delete that lands after the swap has read the
+// record goes to the new collection.
+func recordDeletedUser(ctx context.Context, conn *database.Connection, id primitive.ObjectID) error {
+	_, err := conn.RebuildCollection.UpdateOne(ctx, bson.M{"status": domain.JobRunning},
+		bson.M{"$addToSet": bson.M{"deletedUsers": id}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+func (r RebuildRepoImpl) DropShadow(ctx context.Context, shadow string) error {
+	conn := r.conn
+
+	err := conn.Collection(shadow).Drop(ctx)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// aggregateInto runs a pipeline that ends in a $merge, which returns no documents
+func aggregateInto(ctx context.Context, collection *mongo.Collection, pipeline bson.A) error {
+	cur, err := collection.Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return cur.Close(ctx)
+}
+
+func NewRebuildRepoImpl(conn *database.Connection) RebuildRepoImpl {
+	return RebuildRepoImpl{conn: conn}
+}
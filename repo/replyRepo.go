@@ -1,14 +1,15 @@
 package repo
 
 import (
+	"context"
 	"example.com/app/domain"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type ReplyRepo interface {
-	Create(comment *domain.Reply) error
-	UpdateById(id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time) error
-	DeleteById(id primitive.ObjectID, username string) error
+	Create(ctx context.Context, comment *domain.Reply) error
+	UpdateById(ctx context.Context, id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time) error
+	DeleteById(ctx context.Context, id primitive.ObjectID, username string) (*domain.CascadeResult, error)
 }
 
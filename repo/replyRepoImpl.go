@@ -2,37 +2,35 @@ package repo
 
 import (
 	"context"
+	"example.com/app/apperrors"
 	"example.com/app/database"
 	"example.com/app/domain"
-	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readconcern"
-	"go.mongodb.org/mongo-driver/mongo/writeconcern"
-	"sync"
 	"time"
 )
 
 type ReplyRepoImpl struct {
+	conn *database.Connection
 	Reply        domain.Reply
 	ReplyList    []domain.Reply
 }
 
-func (r ReplyRepoImpl) Create(comment *domain.Reply) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (r ReplyRepoImpl) Create(ctx context.Context, comment *domain.Reply) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
 
 	commentObj := new(domain.Comment)
 
-	err := conn.CommentsCollection.FindOne(context.TODO(), bson.D{{"_id", comment.ResourceId}}).Decode(&commentObj)
+	err := conn.CommentsCollection.FindOne(ctx, bson.D{{Key: "_id", Value: comment.ResourceId}}).Decode(&commentObj)
 
 	if err != nil {
-		return fmt.Errorf("resource not found")
+		return apperrors.NotFound("resource_not_found", "resource not found")
 	}
 
-	_, err = conn.RepliesCollection.InsertOne(context.TODO(), &comment)
+	_, err = conn.RepliesCollection.InsertOne(ctx, &comment)
 
 	if err != nil {
 		return err
@@ -41,90 +39,45 @@ func (r ReplyRepoImpl) Create(comment *domain.Reply) error {
 	return nil
 }
 
-func (r ReplyRepoImpl) UpdateById(id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (r ReplyRepoImpl) UpdateById(ctx context.Context, id primitive.ObjectID, newContent string, edited bool, updatedTime time.Time) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
 
 	opts := options.FindOneAndUpdate().SetUpsert(true)
-	filter := bson.D{{"_id", id}}
-	update := bson.D{{"$set", bson.D{{"content", newContent}, {"edited", edited},
-		{"updatedTime", updatedTime}}}}
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "content", Value: newContent}, {Key: "edited", Value: edited},
+		{Key: "updatedTime", Value: updatedTime}}}}
 
-	err := conn.RepliesCollection.FindOneAndUpdate(context.TODO(),
+	err := conn.RepliesCollection.FindOneAndUpdate(ctx,
 		filter, update, opts).Decode(&r.Reply)
 
 	if err != nil {
-		return fmt.Errorf("cannot update comment that you didn't write")
+		return apperrors.Forbidden("not_author", "cannot update comment that you didn't write")
 	}
 
 	return nil
 }
 
-func (r ReplyRepoImpl) DeleteById(id primitive.ObjectID, username string) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+// DeleteById deletes the user's reply and its flags in one transaction
+func (r ReplyRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID, username string) (*domain.CascadeResult, error) {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "cascade")
+	defer cancel()
 
-	// sets mongo's read and write concerns
-	wc := writeconcern.New(writeconcern.WMajority())
-	rc := readconcern.Snapshot()
-	txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
+	result := new(domain.CascadeResult)
 
-	// set up for a transaction
-	session, err := conn.StartSession()
+	err := cascade(ctx, conn, result, func(ctx context.Context, result *domain.CascadeResult) error {
+		return deleteReply(ctx, conn, id, username, result, nil)
+	})
 
 	if err != nil {
-		panic(err)
-	}
-
-	defer session.EndSession(context.Background())
-
-	// execute this code in a logical transaction
-	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-		var wg sync.WaitGroup
-		wg.Add(2)
-
-		go func() {
-			defer wg.Done()
-
-			res, err := conn.RepliesCollection.DeleteOne(context.TODO(), bson.D{{"_id", id}, {"authorUsername", username}})
-
-			if err != nil {
-				panic(err)
-			}
-
-			if res.DeletedCount == 0  {
-				panic(fmt.Errorf("failed to delete reply"))
-			}
-
-			return
-		}()
-
-		go func() {
-			defer wg.Done()
-			_, err = conn.FlagCollection.DeleteMany(context.TODO(), bson.D{{"flaggedResource", id}})
-
-			if err != nil {
-				panic(err)
-			}
-			return
-		}()
-
-		wg.Wait()
-
 		return nil, err
 	}
 
-	_, err = session.WithTransaction(context.Background(), callback, txnOpts)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return result, nil
 }
 
-func NewReplyRepoImpl() ReplyRepoImpl {
-	var replyRepoImpl ReplyRepoImpl
-
-	return replyRepoImpl
+func NewReplyRepoImpl(conn *database.Connection) ReplyRepoImpl {
+	return ReplyRepoImpl{conn: conn}
 }
\ No newline at end of file
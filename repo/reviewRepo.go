@@ -0,0 +1,15 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReviewRepo interface {
+	FindAll(ctx context.Context, page string) (*[]domain.ReviewItem, error)
+	Enqueue(ctx context.Context, resourceType string, resourceId primitive.ObjectID, flagCount int, reason string) (*domain.ReviewItem, error)
+	AutoHide(ctx context.Context, item *domain.ReviewItem) error
+	Confirm(ctx context.Context, id primitive.ObjectID, username string) error
+	Reverse(ctx context.Context, id primitive.ObjectID, username string) error
+}
@@ -0,0 +1,247 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strconv"
+	"time"
+)
+
+type ReviewRepoImpl struct {
+	conn       *database.Connection
+	ReviewItem domain.ReviewItem
+	ReviewList []domain.ReviewItem
+}
+
+// FindAll returns the pending review queue, auto-hidden content first and then the most flagged
+func (r ReviewRepoImpl) FindAll(ctx context.Context, page string) (*[]domain.ReviewItem, error) {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	findOptions := options.FindOptions{}
+	perPage := 10
+	pageNumber, err := strconv.Atoi(page)
+
+	if err != nil {
+		return nil, apperrors.Validation("invalid_page", "page must be a number").WithField("page", "must be a number")
+	}
+	findOptions.SetSkip((int64(pageNumber) - 1) * int64(perPage))
+	findOptions.SetLimit(int64(perPage))
+	findOptions.SetSort(bson.D{
+		{Key: "autoHidden", Value: -1},
+		{Key: "flagCount", Value: -1},
+		{Key: "createdAt", Value: 1},
+	})
+
+	cur, err := conn.ReviewCollection.Find(ctx, bson.M{"status": domain.ReviewPending}, &findOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cur.All(ctx, &r.ReviewList); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return &r.ReviewList, nil
+}
+
+// Enqueue adds the resource to the review queue or refreshes its flag count if it's already pending
+func (r ReviewRepoImpl) Enqueue(ctx context.Context, resourceType string, resourceId primitive.ObjectID, flagCount int, reason string) (*domain.ReviewItem, error) {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	filter := bson.M{"resourceId": resourceId, "status": domain.ReviewPending}
+	update := bson.M{
+		"$set": bson.M{"flagCount": flagCount, "updatedAt": now},
+		"$setOnInsert": bson.M{
+			"_id":          primitive.NewObjectID(),
+			"resourceType": resourceType,
+			"autoHidden":   false,
+			"reasons":      []string{},
+			"createdAt":    now,
+		},
+	}
+
+	err := conn.ReviewCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&r.ReviewItem)
+
+	// a flag at the same time queued the item first, the unique index on pending items turns down the second
+	// insert and the item it queued is updated instead
+	if mongo.IsDuplicateKeyError(err) {
+		err = conn.ReviewCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&r.ReviewItem)
+	}
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	if reason != "" {
+		_, err = conn.ReviewCollection.UpdateOne(ctx, bson.M{"_id": r.ReviewItem.Id},
+			bson.M{"$addToSet": bson.M{"reasons": reason}})
+
+		if err != nil {
+			return nil, apperrors.Internal(err)
+		}
+	}
+
+	return &r.ReviewItem, nil
+}
+
+// AutoHide hides the resource behind the review item and publishes the hide on the event topic
+func (r ReviewRepoImpl) AutoHide(ctx context.Context, item *domain.ReviewItem) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	event := moderationEvent("auto-hide", item.ResourceType, item.ResourceId, "system",
+		item.ResourceType+" was hidden automatically after "+strconv.Itoa(item.FlagCount)+" flags")
+
+	err := transaction(ctx, conn, func(ctx context.Context, _ bool) error {
+		err := setHidden(ctx, conn, item.ResourceType, item.ResourceId, true)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.ReviewCollection.UpdateOne(ctx, bson.M{"_id": item.Id},
+			bson.M{"$set": bson.M{"autoHidden": true, "updatedAt": time.Now()}})
+
+		if err != nil {
+			return apperrors.Internal(err)
+		}
+
+		return SendEventMessage(ctx, conn, event)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	item.AutoHidden = true
+	announceModerationEvent(ctx, conn, event)
+
+	return nil
+}
+
+// Confirm keeps the resource hidden and closes the review item
+func (r ReviewRepoImpl) Confirm(ctx context.Context, id primitive.ObjectID, username string) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	var event *domain.Event
+
+	err := transaction(ctx, conn, func(ctx context.Context, _ bool) error {
+		item, err := resolveReview(ctx, conn, id, domain.ReviewConfirmed, username)
+
+		if err != nil {
+			return err
+		}
+
+		err = setHidden(ctx, conn, item.ResourceType, item.ResourceId, true)
+
+		if err != nil {
+			return err
+		}
+
+		event = moderationEvent("confirm hide", item.ResourceType, item.ResourceId, username, username+" confirmed hiding the "+item.ResourceType)
+		return SendEventMessage(ctx, conn, event)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	announceModerationEvent(ctx, conn, event)
+
+	return nil
+}
+
+// Reverse restores the visibility of the resource and closes the review item
+func (r ReviewRepoImpl) Reverse(ctx context.Context, id primitive.ObjectID, username string) error {
+	conn := r.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	var event *domain.Event
+
+	err := transaction(ctx, conn, func(ctx context.Context, _ bool) error {
+		item, err := resolveReview(ctx, conn, id, domain.ReviewReversed, username)
+
+		if err != nil {
+			return err
+		}
+
+		err = setHidden(ctx, conn, item.ResourceType, item.ResourceId, false)
+
+		if err != nil {
+			return err
+		}
+
+		event = moderationEvent("unhide", item.ResourceType, item.ResourceId, username, username+" restored the "+item.ResourceType)
+		return SendEventMessage(ctx, conn, event)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	announceModerationEvent(ctx, conn, event)
+
+	return nil
+}
+
+// resolveReview moves a pending review item to its final status
+func resolveReview(ctx context.Context, conn *database.Connection, id primitive.ObjectID, status string, username string) (*domain.ReviewItem, error) {
+	item := new(domain.ReviewItem)
+	now := time.Now()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	filter := bson.M{"_id": id, "status": domain.ReviewPending}
+	update := bson.M{"$set": bson.M{"status": status, "reviewedBy": username, "reviewedAt": now, "updatedAt": now}}
+
+	err := conn.ReviewCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(item)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("review_item_not_found", "cannot find pending review item")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return item, nil
+}
+
+func setHidden(ctx context.Context, conn *database.Connection, resourceType string, id primitive.ObjectID, hidden bool) error {
+	collection, err := resourceCollection(conn, resourceType)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"hidden": hidden}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return apperrors.NotFound("resource_not_found", "resource not found")
+	}
+
+	return nil
+}
+
+func NewReviewRepoImpl(conn *database.Connection) ReviewRepoImpl {
+	return ReviewRepoImpl{conn: conn}
+}
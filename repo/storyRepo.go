@@ -1,14 +1,16 @@
 package repo
 
 import (
+	"context"
 	"example.com/app/domain"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type StoryRepo interface {
-	FindAll(string, bool) (*[]domain.Story, error)
-	FindById(primitive.ObjectID) (*domain.StoryDto, error)
-	Create(story *domain.Story) error
-	UpdateById(primitive.ObjectID, string, string, string, *[]domain.Tag, bool) error
-	DeleteById(primitive.ObjectID) error
+	FindAll(context.Context, string, bool) (*[]domain.Story, error)
+	FindAfter(context.Context, primitive.ObjectID, int) ([]domain.Story, error)
+	FindById(context.Context, primitive.ObjectID) (*domain.StoryDto, error)
+	Create(ctx context.Context, story *domain.Story) error
+	UpdateById(context.Context, primitive.ObjectID, string, string, string, *[]domain.Tag, bool) error
+	DeleteById(context.Context, primitive.ObjectID, *domain.ModerationAction) (*domain.CascadeResult, error)
 }
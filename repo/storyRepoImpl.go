@@ -2,190 +2,185 @@ package repo
 
 import (
 	"context"
+	"example.com/app/apperrors"
 	"example.com/app/database"
 	"example.com/app/domain"
-	"fmt"
+	"example.com/app/logger"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readconcern"
-	"go.mongodb.org/mongo-driver/mongo/writeconcern"
-	"log"
 	"strconv"
-	"sync"
 	"time"
 )
 
 type StoryRepoImpl struct {
+	conn *database.Connection
 	Story             domain.Story
 	StoryDto          domain.StoryDto
 	StoryList         []domain.Story
 	StoryDtoList      []domain.StoryDto
 }
 
-func (s StoryRepoImpl) FindAll(page string, newStoriesQuery bool) (*[]domain.Story, error) {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (s StoryRepoImpl) FindAll(ctx context.Context, page string, newStoriesQuery bool) (*[]domain.Story, error) {
+	conn := s.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
 
 	findOptions := options.FindOptions{}
 	perPage := 10
 	pageNumber, err := strconv.Atoi(page)
 
 	if err != nil {
-		return nil, fmt.Errorf("page must be a number")
+		return nil, apperrors.Validation("invalid_page", "page must be a number").WithField("page", "must be a number")
 	}
 	findOptions.SetSkip((int64(pageNumber) - 1) * int64(perPage))
 	findOptions.SetLimit(int64(perPage))
 
 	if newStoriesQuery {
-		findOptions.SetSort(bson.D{{"createdAt", -1}})
+		findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
 	}
 
-	cur, err := conn.StoryCollection.Find(context.TODO(), bson.M{}, &findOptions)
+	cur, err := conn.StoryCollection.Find(ctx, bson.M{}, &findOptions)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if err = cur.All(context.TODO(), &s.StoryList); err != nil {
-		log.Fatal(err)
+	if err = cur.All(ctx, &s.StoryList); err != nil {
+		logger.FromContext(ctx).Error("error decoding results", "error", err)
+		return nil, err
 	}
 
 	// Close the cursor once finished
-	err = cur.Close(context.TODO())
+	err = cur.Close(ctx)
 
 	if err != nil {
-		return nil, fmt.Errorf("error processing data")
+		return nil, apperrors.Internal(err)
 	}
 
 	return &s.StoryList, nil
 }
 
-func (s StoryRepoImpl) FindById(storyID primitive.ObjectID) (*domain.StoryDto, error) {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+// FindAfter lists up to limit stories, newest first, starting after the story with id after or at the newest
+// when it's zero
+func (s StoryRepoImpl) FindAfter(ctx context.Context, after primitive.ObjectID, limit int) ([]domain.Story, error) {
+	conn := s.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
 
-	err := conn.StoryCollection.FindOne(context.TODO(), bson.D{{"_id", storyID}}).Decode(&s.StoryDto)
+	cur, err := conn.StoryCollection.Find(ctx, afterFilter(after), afterOptions(limit))
 
 	if err != nil {
-		return nil, err
+		return nil, apperrors.Internal(err)
+	}
+
+	stories := []domain.Story{}
+	if err = cur.All(ctx, &stories); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return stories, nil
+}
+
+func (s StoryRepoImpl) FindById(ctx context.Context, storyID primitive.ObjectID) (*domain.StoryDto, error) {
+	conn := s.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.StoryCollection.FindOne(ctx, bson.D{{Key: "_id", Value: storyID}}).Decode(&s.StoryDto)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("story_not_found", "cannot find story")
+		}
+		return nil, apperrors.Internal(err)
 	}
 	return &s.StoryDto, nil
 }
 
-func (s StoryRepoImpl) Create(story *domain.Story) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (s StoryRepoImpl) Create(ctx context.Context, story *domain.Story) error {
+	conn := s.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
 
 	story.Id = primitive.NewObjectID()
 
-	_, err := conn.StoryCollection.InsertOne(context.TODO(), &story)
+	_, err := conn.StoryCollection.InsertOne(ctx, &story)
 
 	if err != nil {
-		return fmt.Errorf("error processing data")
+		return apperrors.Internal(err)
 	}
 
 	return nil
 }
 
-func (s StoryRepoImpl) UpdateById(id primitive.ObjectID, newContent string, newTitle string, username string, tags *[]domain.Tag, updated bool) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
-
-	filter := bson.D{{"_id", id}, {"authorUsername", username}}
-	update := bson.D{{"$set",
-		bson.D{{"content", newContent},
-			{"title", newTitle},
-			{"updatedAt", time.Now()},
-			{"tags", tags},
-			{"updated", updated},
+func (s StoryRepoImpl) UpdateById(ctx context.Context, id primitive.ObjectID, newContent string, newTitle string, username string, tags *[]domain.Tag, updated bool) error {
+	conn := s.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "authorUsername", Value: username}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "content", Value: newContent},
+			{Key: "title", Value: newTitle},
+			{Key: "updatedAt", Value: time.Now()},
+			{Key: "tags", Value: tags},
+			{Key: "updated", Value: updated},
 		},
 	}}
 
-	_, err := conn.StoryCollection.UpdateOne(context.TODO(),
+	res, err := conn.StoryCollection.UpdateOne(ctx,
 		filter, update)
 
 	if err != nil {
-		return fmt.Errorf("you can't update a story you didn't write")
+		return apperrors.Internal(err)
 	}
 
-	return nil
-}
-
-
-func (s StoryRepoImpl) DeleteById(id primitive.ObjectID) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
-	// sets mongo's read and write concerns
-	wc := writeconcern.New(writeconcern.WMajority())
-	rc := readconcern.Snapshot()
-	txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
-
-	// set up for a transaction
-	session, err := conn.StartSession()
-
-	if err != nil {
-		panic(err)
+	if res.MatchedCount == 0 {
+		return notAuthor(ctx, conn.StoryCollection, id, apperrors.NotFound("story_not_found", "cannot find story"),
+			apperrors.Forbidden("not_author", "you can't update a story you didn't write"))
 	}
 
-	defer session.EndSession(context.Background())
-
-	// execute this code in a logical transaction
-	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-		var wg sync.WaitGroup
-		wg.Add(3)
-
-		go func() {
-			defer wg.Done()
-			res, err := conn.StoryCollection.DeleteOne(context.TODO(), bson.D{{"_id", id}})
+	return nil
+}
 
-			if err != nil {
-				panic(err)
-			}
 
-			if res.DeletedCount == 0 {
-				panic(fmt.Errorf("failed to delete story"))
-			}
-			return
-		}()
+// DeleteById deletes the story with its comments, their replies and all of their flags in one transaction. An action is
+// recorded in the same transaction with what the delete removed, nil deletes without a record.
+func (s StoryRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID, action *domain.ModerationAction) (*domain.CascadeResult, error) {
+	conn := s.conn
+	ctx, cancel := withTimeout(ctx, "cascade")
+	defer cancel()
 
-		go func() {
-			defer wg.Done()
-			_, err = conn.FlagCollection.DeleteMany(context.TODO(), bson.D{{"flaggedResource", id}})
+	result := new(domain.CascadeResult)
 
-			if err != nil {
-				panic(err)
-			}
-			return
-		}()
+	err := cascade(ctx, conn, result, func(ctx context.Context, result *domain.CascadeResult) error {
+		if action == nil {
+			return deleteStory(ctx, conn, id, result, nil)
+		}
 
-		go func() {
-			defer wg.Done()
-			err = CommentRepoImpl{}.DeleteManyById(id)
+		// a retried transaction collects the cascade again
+		action.Cascade = nil
+		err := deleteStory(ctx, conn, id, result, action)
 
-			if err != nil {
-				panic(err)
-			}
-			return
-		}()
+		if err != nil {
+			return err
+		}
 
-		wg.Wait()
+		return insertAction(ctx, conn, action)
+	})
 
+	if err != nil {
 		return nil, err
 	}
 
-	_, err = session.WithTransaction(context.Background(), callback, txnOpts)
-
-	if err != nil {
-		return err
+	if action != nil {
+		announceAction(ctx, conn, action)
 	}
 
-	return nil
+	return result, nil
 }
 
-func NewStoryRepoImpl() StoryRepoImpl {
-	var storyRepoImpl StoryRepoImpl
-
-	return storyRepoImpl
+func NewStoryRepoImpl(conn *database.Connection) StoryRepoImpl {
+	return StoryRepoImpl{conn: conn}
 }
@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeouts bound each kind of database operation, TIMEOUT_<OPERATION> in seconds overrides them.
+// The deadline of the incoming request still applies when it's shorter.
+var defaultTimeouts = map[string]time.Duration{
+	"read":    5 * time.Second,
+	"write":   5 * time.Second,
+	"cascade": 30 * time.Second,
+	"purge":   5 * time.Minute,
+	"export":  time.Minute,
+	"rebuild": 10 * time.Minute,
+}
+
+func withTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	timeout := defaultTimeouts[operation]
+
+	if s, err := strconv.Atoi(config.Value("TIMEOUT_" + strings.ToUpper(operation))); err == nil && s > 0 {
+		timeout = time.Duration(s) * time.Second
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
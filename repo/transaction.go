@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// illegalOperation is the code a standalone server answers a transaction with
+const illegalOperation = 20
+
+// transaction runs fn in one transaction, fn may run more than once when the transaction is retried. Standalone
+// deployments can't run transactions, so there fn runs again without one and transactional is false.
+func transaction(ctx context.Context, conn *database.Connection, fn func(ctx context.Context, transactional bool) error) error {
+	// sets mongo's read and write concerns
+	wc := writeconcern.New(writeconcern.WMajority())
+	rc := readconcern.Snapshot()
+	txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
+
+	session, err := conn.StartSession()
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext, true)
+	}, txnOpts)
+
+	if transactionsUnsupported(err) {
+		return fn(ctx, false)
+	}
+
+	return apperrors.Internal(err)
+}
+
+func transactionsUnsupported(err error) bool {
+	var commandErr mongo.CommandError
+
+	return errors.As(err, &commandErr) && commandErr.Code == illegalOperation
+}
@@ -7,9 +7,12 @@ import (
 )
 
 type UserRepo interface {
-	FindAll(string, context.Context) (*domain.UserResponse, error)
-	Create(user *domain.User) error
-	UpdateByID(user *domain.User) error
-	FindByUsername(string) (*domain.UserDto, error)
-	DeleteByID(primitive.ObjectID) error
+	FindAll(context.Context, string) (*domain.UserResponse, error)
+	FindAfter(context.Context, primitive.ObjectID, int) ([]domain.UserDto, error)
+	Create(ctx context.Context, user *domain.User) error
+	UpdateByID(ctx context.Context, user *domain.User) error
+	FindByUsername(context.Context, string) (*domain.UserDto, error)
+	DeleteByID(context.Context, primitive.ObjectID) error
+	LockByID(context.Context, primitive.ObjectID, bool) error
+	PurgeByID(context.Context, primitive.ObjectID, string, *domain.ModerationAction) (*domain.PurgeResult, error)
 }
@@ -2,18 +2,19 @@ package repo
 
 import (
 	"context"
+	"example.com/app/apperrors"
 	"example.com/app/database"
 	"example.com/app/domain"
-	"fmt"
+	"example.com/app/logger"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
 	"strconv"
 )
 
 type UserRepoImpl struct {
+	conn *database.Connection
 	users        []domain.User
 	user         domain.User
 	userDto      domain.UserDto
@@ -21,17 +22,18 @@ type UserRepoImpl struct {
 	userResponse domain.UserResponse
 }
 
-func (u UserRepoImpl) FindAll(page string, ctx context.Context) (*domain.UserResponse, error) {
+func (u UserRepoImpl) FindAll(ctx context.Context, page string) (*domain.UserResponse, error) {
 
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
 
 	findOptions := options.FindOptions{}
 	perPage := 10
 	pageNumber, err := strconv.Atoi(page)
 
 	if err != nil {
-		return nil, fmt.Errorf("page must be a number")
+		return nil, apperrors.Validation("invalid_page", "page must be a number").WithField("page", "must be a number")
 	}
 	findOptions.SetSkip((int64(pageNumber) - 1) * int64(perPage))
 	findOptions.SetLimit(int64(perPage))
@@ -44,7 +46,8 @@ func (u UserRepoImpl) FindAll(page string, ctx context.Context) (*domain.UserRes
 	}
 
 	if err = cur.All(ctx, &u.userDtoList); err != nil {
-		log.Fatal(err)
+		logger.FromContext(ctx).Error("error decoding results", "error", err)
+		return nil, err
 	}
 
 	u.userResponse = domain.UserResponse{Users: &u.userDtoList, CurrentPage: page}
@@ -52,11 +55,33 @@ func (u UserRepoImpl) FindAll(page string, ctx context.Context) (*domain.UserRes
 	return &u.userResponse, nil
 }
 
-func (u UserRepoImpl) Create(user *domain.User) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+// FindAfter lists up to limit users, newest first, starting after the user with id after or at the newest
+// when it's zero
+func (u UserRepoImpl) FindAfter(ctx context.Context, after primitive.ObjectID, limit int) ([]domain.UserDto, error) {
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	cur, err := conn.UserCollection.Find(ctx, afterFilter(after), afterOptions(limit))
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	users := []domain.UserDto{}
+	if err = cur.All(ctx, &users); err != nil {
+		return nil, apperrors.Internal(err)
+	}
 
-	cur, err := conn.UserCollection.Find(context.TODO(), bson.M{
+	return users, nil
+}
+
+func (u UserRepoImpl) Create(ctx context.Context, user *domain.User) error {
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	cur, err := conn.UserCollection.Find(ctx, bson.M{
 		"$or": []interface{}{
 			bson.M{"email": user.Email},
 			bson.M{"username": user.Username},
@@ -64,68 +89,280 @@ func (u UserRepoImpl) Create(user *domain.User) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("error processing data")
+		return apperrors.Internal(err)
 	}
 
-	if !cur.Next(context.TODO()) {
-		_, err = conn.UserCollection.InsertOne(context.TODO(), &user)
+	if !cur.Next(ctx) {
+		_, err = conn.UserCollection.InsertOne(ctx, &user)
 
 		if err != nil {
-			return fmt.Errorf("error processing data")
+			return apperrors.Internal(err)
 		}
 
 		return nil
 	}
 
-	return fmt.Errorf("user already exists")
+	return apperrors.Conflict("user_exists", "user already exists")
 }
 
-func (u UserRepoImpl) UpdateByID(user *domain.User) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (u UserRepoImpl) UpdateByID(ctx context.Context, user *domain.User) error {
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
 
-	opts := options.FindOneAndUpdate().SetUpsert(true)
-	filter := bson.D{{"_id", user.Id}}
-	update := bson.D{{"$set", user}}
+	filter := bson.D{{Key: "_id", Value: user.Id}}
+	update := bson.D{{Key: "$set", Value: user}}
+
+	res, err := conn.UserCollection.UpdateOne(ctx,
+		filter, update)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
 
-	conn.UserCollection.FindOneAndUpdate(context.TODO(),
-		filter, update, opts)
+	if res.MatchedCount == 0 {
+		return apperrors.NotFound("user_not_found", "cannot find user")
+	}
 
 	return nil
 }
 
-func (u UserRepoImpl) FindByUsername(username string) (*domain.UserDto, error) {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (u UserRepoImpl) FindByUsername(ctx context.Context, username string) (*domain.UserDto, error) {
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
 
-	err := conn.UserCollection.FindOne(context.TODO(), bson.M{"username": username}).Decode(&u.userDto)
+	err := conn.UserCollection.FindOne(ctx, bson.M{"username": username}).Decode(&u.userDto)
 
 	if err != nil {
 		// ErrNoDocuments means that the filter did not match any documents in the collection
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("cannot find user")
+			return nil, apperrors.NotFound("user_not_found", "cannot find user")
 		}
-		return nil, fmt.Errorf("error processing data")
+		return nil, apperrors.Internal(err)
 	}
 
 	return &u.userDto, nil
 }
 
-func (u UserRepoImpl) DeleteByID(id primitive.ObjectID) error {
-	conn := database.MongoConnectionPool.Get().(*database.Connection)
-	defer database.MongoConnectionPool.Put(conn)
+func (u UserRepoImpl) DeleteByID(ctx context.Context, id primitive.ObjectID) error {
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "cascade")
+	defer cancel()
 
-	_, err := conn.UserCollection.DeleteOne(context.TODO(), bson.D{{"_id", id}})
+	if err := recordDeletedUser(ctx, conn, id); err != nil {
+		return err
+	}
+
+	res, err := conn.UserCollection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
 
 	if err != nil {
-		return err
+		return apperrors.Internal(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return apperrors.NotFound("user_not_found", "cannot find user")
+	}
+
+	return nil
+}
+
+func (u UserRepoImpl) LockByID(ctx context.Context, id primitive.ObjectID, locked bool) error {
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	res, err := conn.UserCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"isLocked": locked}})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return apperrors.NotFound("user_not_found", "cannot find user")
+	}
+
+	return nil
+}
+
+func NewUserRepoImpl(conn *database.Connection) UserRepoImpl {
+	return UserRepoImpl{conn: conn}
+}
+
+// PurgeByID removes the user along with everything they wrote and records action, all in one transaction. In delete
+// mode their content goes through the same cascades as a moderator delete and is kept in the action so an appeal
+// can put it back, in anonymise mode it stays but loses its author, which can't be undone.
+// Flags filed by or against the user are removed either way.
+func (u UserRepoImpl) PurgeByID(ctx context.Context, id primitive.ObjectID, mode string, action *domain.ModerationAction) (*domain.PurgeResult, error) {
+	conn := u.conn
+	ctx, cancel := withTimeout(ctx, "purge")
+	defer cancel()
+
+	if mode != domain.PurgeDelete && mode != domain.PurgeAnonymise {
+		return nil, apperrors.Validation("invalid_purge_mode", "invalid purge mode").WithField("mode", "must be delete or anonymise")
+	}
+
+	var result *domain.PurgeResult
+	var purged []*domain.Event
+
+	err := transaction(ctx, conn, func(ctx context.Context, _ bool) error {
+		// a retried transaction starts over
+		result = &domain.PurgeResult{Mode: mode}
+		purged = nil
+		action.Cascade = nil
+
+		raw, err := conn.UserCollection.FindOne(ctx, bson.M{"_id": id}).DecodeBytes()
+
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return apperrors.NotFound("user_not_found", "cannot find user")
+			}
+			return err
+		}
+
+		action.Snapshot = raw
+		username, _ := raw.Lookup("username").StringValueOK()
+
+		if mode == domain.PurgeAnonymise {
+			err = anonymiseAuthored(ctx, conn, username, result)
+		} else {
+			purged, err = purgeAuthored(ctx, conn, username, result, action)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		flagFilter := bson.M{
+			"$or": []interface{}{
+				bson.M{"flaggerID": id},
+				bson.M{"flaggedResource": id},
+			},
+		}
+
+		err = keepCascaded(ctx, conn.FlagCollection, flagFilter, action)
+
+		if err != nil {
+			return err
+		}
+
+		res, err := conn.FlagCollection.DeleteMany(ctx, flagFilter)
+
+		if err != nil {
+			return err
+		}
+
+		result.Flags += res.DeletedCount
+
+		err = u.DeleteByID(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		result.Users = 1
+		purged = append(purged, moderationEvent("delete", "user", id, "system", "user "+username+" was purged"))
+
+		for _, event := range purged {
+			if err = SendEventMessage(ctx, conn, event); err != nil {
+				return err
+			}
+		}
+
+		return insertAction(ctx, conn, action)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range purged {
+		announceModerationEvent(ctx, conn, event)
+	}
+
+	announceAction(ctx, conn, action)
+
+	return result, nil
+}
+
+// anonymiseAuthored replaces the user as the author of their stories, comments and replies
+func anonymiseAuthored(ctx context.Context, conn *database.Connection, username string, result *domain.PurgeResult) error {
+	collections := map[string]*int64{"story": &result.Stories, "comment": &result.Comments, "reply": &result.Replies}
+
+	for resourceType, count := range collections {
+		collection, _ := resourceCollection(conn, resourceType)
+
+		res, err := collection.UpdateMany(ctx, bson.M{"authorUsername": username},
+			bson.M{"$set": bson.M{"authorUsername": domain.AnonymousUsername}})
+
+		if err != nil {
+			return apperrors.Internal(err)
+		}
+
+		*count = res.ModifiedCount
 	}
 
 	return nil
 }
 
-func NewUserRepoImpl() UserRepoImpl {
-	var userRepoImpl UserRepoImpl
+// purgeAuthored deletes every story, comment and reply the user wrote one at a time, adding up what each cascade
+// removed. The resources and everything removed with them are kept in action, and a delete event for each is
+// returned for the purge to queue.
+func purgeAuthored(ctx context.Context, conn *database.Connection, username string, result *domain.PurgeResult,
+	action *domain.ModerationAction) ([]*domain.Event, error) {
+	var purged []*domain.Event
+
+	deletes := []struct {
+		resourceType string
+		collection   *mongo.Collection
+		deleteFn     func(id primitive.ObjectID, deleted *domain.CascadeResult) error
+	}{
+		{"story", conn.StoryCollection, func(id primitive.ObjectID, deleted *domain.CascadeResult) error {
+			return deleteStory(ctx, conn, id, deleted, action)
+		}},
+		{"comment", conn.CommentsCollection, func(id primitive.ObjectID, deleted *domain.CascadeResult) error {
+			return deleteComment(ctx, conn, id, deleted, action)
+		}},
+		{"reply", conn.RepliesCollection, func(id primitive.ObjectID, deleted *domain.CascadeResult) error {
+			return deleteReply(ctx, conn, id, username, deleted, action)
+		}},
+	}
+
+	for _, d := range deletes {
+		var targets []domain.BulkTarget
+
+		opts := options.Find().SetProjection(bson.M{"_id": 1, "authorUsername": 1})
+		cur, err := d.collection.Find(ctx, bson.M{"authorUsername": username}, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = cur.All(ctx, &targets); err != nil {
+			return nil, err
+		}
+
+		for _, t := range targets {
+			err = keepCascaded(ctx, d.collection, bson.M{"_id": t.Id}, action)
+
+			if err != nil {
+				return nil, err
+			}
+
+			deleted := new(domain.CascadeResult)
+			err = d.deleteFn(t.Id, deleted)
+
+			if err != nil {
+				return nil, err
+			}
+
+			result.Stories += deleted.Stories
+			result.Comments += deleted.Comments
+			result.Replies += deleted.Replies
+			result.Flags += deleted.Flags
+			purged = append(purged, moderationEvent("delete", d.resourceType, t.Id, "system", d.resourceType+" by "+username+" was purged"))
+		}
+	}
 
-	return userRepoImpl
+	return purged, nil
 }
@@ -0,0 +1,20 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type WebhookRepo interface {
+	Create(ctx context.Context, webhook *domain.Webhook) error
+	FindAll(ctx context.Context, page string) (*[]domain.Webhook, error)
+	FindById(ctx context.Context, id primitive.ObjectID) (*domain.Webhook, error)
+	DeleteById(ctx context.Context, id primitive.ObjectID) error
+	FindDeliveries(ctx context.Context, webhookId primitive.ObjectID, page string) (*[]domain.WebhookDelivery, error)
+	Enqueue(ctx context.Context, event domain.FeedEvent) error
+	ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt domain.DeliveryAttempt, status string, next time.Time) error
+	Redeliver(ctx context.Context, id primitive.ObjectID) (*domain.WebhookDelivery, error)
+}
@@ -0,0 +1,270 @@
+package repo
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/database"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strconv"
+	"time"
+)
+
+type WebhookRepoImpl struct {
+	conn         *database.Connection
+	Webhook      domain.Webhook
+	WebhookList  []domain.Webhook
+	Delivery     domain.WebhookDelivery
+	DeliveryList []domain.WebhookDelivery
+}
+
+func (w WebhookRepoImpl) Create(ctx context.Context, webhook *domain.Webhook) error {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	webhook.Id = primitive.NewObjectID()
+	webhook.Active = true
+	webhook.CreatedAt = time.Now()
+
+	_, err := conn.WebhookCollection.InsertOne(ctx, webhook)
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// FindAll lists the webhooks newest first, without their secrets
+func (w WebhookRepoImpl) FindAll(ctx context.Context, page string) (*[]domain.Webhook, error) {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	findOptions, err := pageOptions(page)
+
+	if err != nil {
+		return nil, err
+	}
+	findOptions.SetSort(bson.D{{Key: "_id", Value: -1}})
+	findOptions.SetProjection(bson.M{"secret": 0})
+
+	cur, err := conn.WebhookCollection.Find(ctx, bson.M{}, findOptions)
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	w.WebhookList = []domain.Webhook{}
+	if err = cur.All(ctx, &w.WebhookList); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return &w.WebhookList, nil
+}
+
+// FindById returns the webhook with its secret, the delivery worker signs with it
+func (w WebhookRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*domain.Webhook, error) {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	err := conn.WebhookCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&w.Webhook)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("webhook_not_found", "cannot find webhook")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &w.Webhook, nil
+}
+
+// DeleteById removes the webhook and its delivery log, anything still pending is never sent
+func (w WebhookRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID) error {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	res, err := conn.WebhookCollection.DeleteOne(ctx, bson.M{"_id": id})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return apperrors.NotFound("webhook_not_found", "cannot find webhook")
+	}
+
+	_, err = conn.DeliveryCollection.DeleteMany(ctx, bson.M{"webhookId": id})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// FindDeliveries lists the deliveries to a webhook newest first
+func (w WebhookRepoImpl) FindDeliveries(ctx context.Context, webhookId primitive.ObjectID, page string) (*[]domain.WebhookDelivery, error) {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "read")
+	defer cancel()
+
+	findOptions, err := pageOptions(page)
+
+	if err != nil {
+		return nil, err
+	}
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cur, err := conn.DeliveryCollection.Find(ctx, bson.M{"webhookId": webhookId}, findOptions)
+
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	w.DeliveryList = []domain.WebhookDelivery{}
+	if err = cur.All(ctx, &w.DeliveryList); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	return &w.DeliveryList, nil
+}
+
+// Enqueue adds a pending delivery of the event for every active webhook whose filter matches it. The unique
+// index on webhookId and event._id makes a second enqueue of the same event a no-op.
+func (w WebhookRepoImpl) Enqueue(ctx context.Context, event domain.FeedEvent) error {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	cur, err := conn.WebhookCollection.Find(ctx, bson.M{"active": true}, options.Find().SetProjection(bson.M{"filter": 1}))
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	var webhooks []domain.Webhook
+	if err = cur.All(ctx, &webhooks); err != nil {
+		return apperrors.Internal(err)
+	}
+
+	now := time.Now()
+	var deliveries []interface{}
+
+	for _, webhook := range webhooks {
+		if !webhook.Filter.Matches(event) {
+			continue
+		}
+		deliveries = append(deliveries, domain.WebhookDelivery{
+			Id:            primitive.NewObjectID(),
+			WebhookId:     webhook.Id,
+			Event:         event,
+			Status:        domain.DeliveryPending,
+			Attempts:      []domain.DeliveryAttempt{},
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	_, err = conn.DeliveryCollection.InsertMany(ctx, deliveries, options.InsertMany().SetOrdered(false))
+
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// ClaimDueDelivery takes the pending delivery that has waited longest and pushes its next attempt back by lease,
+// so no other instance sends it meanwhile and it's tried again if this one dies. It returns nil when nothing
+// is due.
+func (w WebhookRepoImpl) ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error) {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	filter := bson.M{"status": domain.DeliveryPending, "nextAttemptAt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"nextAttemptAt": now.Add(lease)}}
+
+	err := conn.DeliveryCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&w.Delivery)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &w.Delivery, nil
+}
+
+// RecordAttempt logs an attempt and moves the delivery to status, next is when a pending one is tried again
+func (w WebhookRepoImpl) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt domain.DeliveryAttempt, status string, next time.Time) error {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	_, err := conn.DeliveryCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$push": bson.M{"attempts": attempt},
+		"$set":  bson.M{"status": status, "nextAttemptAt": next, "updatedAt": time.Now()},
+	})
+
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return nil
+}
+
+// Redeliver makes a delivery pending again and due now, keeping the log of its earlier attempts
+func (w WebhookRepoImpl) Redeliver(ctx context.Context, id primitive.ObjectID) (*domain.WebhookDelivery, error) {
+	conn := w.conn
+	ctx, cancel := withTimeout(ctx, "write")
+	defer cancel()
+
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{"status": domain.DeliveryPending, "nextAttemptAt": now, "updatedAt": now}}
+
+	err := conn.DeliveryCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&w.Delivery)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperrors.NotFound("delivery_not_found", "cannot find webhook delivery")
+		}
+		return nil, apperrors.Internal(err)
+	}
+
+	return &w.Delivery, nil
+}
+
+// pageOptions skips to a page of 10
+func pageOptions(page string) (*options.FindOptions, error) {
+	perPage := 10
+	pageNumber, err := strconv.Atoi(page)
+
+	if err != nil {
+		return nil, apperrors.Validation("invalid_page", "page must be a number").WithField("page", "must be a number")
+	}
+
+	return options.Find().SetSkip((int64(pageNumber) - 1) * int64(perPage)).SetLimit(int64(perPage)), nil
+}
+
+func NewWebhookRepoImpl(conn *database.Connection) WebhookRepoImpl {
+	return WebhookRepoImpl{conn: conn}
+}
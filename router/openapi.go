@@ -0,0 +1,252 @@
+package router
+
+import (
+	"example.com/app/domain"
+	"example.com/app/health"
+	"example.com/app/openapi"
+)
+
+// Spec documents every route SetupRoutes registers, Setup refuses to start when the two don't match
+func Spec() *openapi.Document {
+	d := openapi.New("Control API", "2.0.0",
+		"Moderation and administration of stories, comments, replies and users. Log in with POST "+v1Prefix+"/auth/login, "+
+			"the response's Authorization header holds `Bearer <jwt>|<signature>`, send it back as is on every other request. "+
+			"Errors are RFC 7807 problems with a stable code. "+
+			"/v1 answers in a {status, message, data} envelope and pages lists by number, /v2 answers in a {data} envelope "+
+			"and pages the story and user lists by cursor. The unversioned "+legacyPrefix+" routes are v1 and deprecated. "+
+			"Login, reads, writes and destructive routes each have a token bucket per admin or address, "+
+			"responses carry RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy and a 429 adds Retry-After.")
+
+	d.Components.SecuritySchemes["signedToken"] = &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "Authorization",
+		Description: "Bearer <jwt>|<signature>, copied from the Authorization header of the login response",
+	}
+
+	d.Add(openapi.Route{Method: "GET", Path: "/healthz", Tag: "operations", Summary: "Liveness probe",
+		Unwrapped: true, Response: map[string]string{}})
+	d.Add(openapi.Route{Method: "GET", Path: "/readyz", Tag: "operations", Summary: "Readiness probe, 503 when a dependency is down",
+		Unwrapped: true, Response: health.Report{}})
+	d.Add(openapi.Route{Method: "GET", Path: "/metrics", Tag: "operations", Summary: "Prometheus metrics",
+		Raw: "text/plain"})
+	d.Add(openapi.Route{Method: "GET", Path: "/openapi.json", Tag: "operations", Summary: "This document",
+		Unwrapped: true})
+	d.Add(openapi.Route{Method: "GET", Path: "/docs", Tag: "operations", Summary: "Interactive docs for this document",
+		Raw: "text/html"})
+
+	page := d.QueryParam("page", "page number, starting at 1", domain.PageQuery{}.Page)
+	page.Schema.Minimum, page.Schema.Maximum = float(1), float(10000)
+
+	documentV1(version{d: d, prefix: legacyPrefix, deprecated: true}, page)
+	documentV1(version{d: d, prefix: v1Prefix}, page)
+	documentV2(version{d: d, prefix: v2Prefix, envelope: openapi.DataEnvelope}, page)
+
+	return d
+}
+
+// version adds routes under one api version's prefix, with its envelope and deprecation headers
+type version struct {
+	d          *openapi.Document
+	prefix     string
+	deprecated bool
+	envelope   func(*openapi.Schema) *openapi.Schema
+}
+
+func (v version) Add(r openapi.Route) {
+	r.Path = v.prefix + r.Path
+	r.Envelope = v.envelope
+
+	if v.deprecated {
+		r.Deprecated = true
+		headers := map[string]*openapi.Header{
+			"Deprecation": {Description: "when the route was deprecated, @ and a unix time", Schema: &openapi.Schema{Type: "string"}},
+			"Sunset":      {Description: "the HTTP date the route stops working", Schema: &openapi.Schema{Type: "string"}},
+			"Link":        {Description: "the same route under /v1, rel=\"successor-version\"", Schema: &openapi.Schema{Type: "string"}},
+		}
+		for name, h := range r.Headers {
+			headers[name] = h
+		}
+		r.Headers = headers
+	}
+
+	v.d.Add(r)
+}
+
+// documentV1 documents registerV1
+func documentV1(v version, page openapi.Parameter) {
+	d := v.d
+
+	v.Add(openapi.Route{Method: "GET", Path: "/stories/", Tag: "stories", Summary: "List stories", Auth: true,
+		Query:    []openapi.Parameter{page, d.QueryParam("new", "newest first instead of by score", false)},
+		Response: []domain.Story{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/users/", Tag: "users", Summary: "List users", Auth: true,
+		Query: []openapi.Parameter{page}, Response: domain.UserResponse{}})
+
+	documentShared(v, page)
+}
+
+// documentV2 documents registerV2
+func documentV2(v version, page openapi.Parameter) {
+	d := v.d
+
+	cursor := []openapi.Parameter{
+		d.QueryParam("cursor", "next_cursor of the previous page, leave out for the first", ""),
+		d.QueryParam("limit", "items per page, 20 when left out", domain.CursorQuery{}.Limit),
+	}
+	cursor[1].Schema.Minimum, cursor[1].Schema.Maximum = float(1), float(100)
+
+	v.Add(openapi.Route{Method: "GET", Path: "/stories/", Tag: "stories", Summary: "List stories, newest first", Auth: true,
+		Query: cursor, Response: domain.StoryPage{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/users/", Tag: "users", Summary: "List users, newest first", Auth: true,
+		Query: cursor, Response: domain.UserPage{}})
+
+	documentShared(v, page)
+}
+
+// documentShared documents registerShared
+func documentShared(v version, page openapi.Parameter) {
+	d := v.d
+
+	v.Add(openapi.Route{Method: "POST", Path: "/auth/login", Tag: "auth", Summary: "Log in as an admin",
+		Body: domain.LoginDetails{}, Response: domain.Admin{},
+		Headers: map[string]*openapi.Header{"Authorization": {
+			Description: "the signed token to send on every other request",
+			Schema:      &openapi.Schema{Type: "string"},
+		}}})
+
+	v.Add(openapi.Route{Method: "GET", Path: "/stories/:id", Tag: "stories", Summary: "Get a story with its comments", Auth: true,
+		Response: domain.StoryDto{}})
+	v.Add(openapi.Route{Method: "DELETE", Path: "/stories/:id", Tag: "stories", Auth: true,
+		Summary: "Delete a story with its comments, replies and flags", Response: domain.CascadeResult{}})
+
+	v.Add(openapi.Route{Method: "DELETE", Path: "/comment/:id", Tag: "comments", Auth: true,
+		Summary: "Delete a comment with its replies and flags", Response: domain.CascadeResult{}})
+	v.Add(openapi.Route{Method: "DELETE", Path: "/reply/:id", Tag: "comments", Auth: true,
+		Summary: "Delete a reply and its flags", Response: domain.CascadeResult{}})
+
+	v.Add(openapi.Route{Method: "DELETE", Path: "/users/:id", Tag: "users", Auth: true,
+		Summary:     "Delete a user, or purge everything they wrote",
+		Description: "Without purge the user is deleted and the response is a 204. With purge=true their content is deleted or anonymised and the counts are returned. A purge that deletes can be appealed and undone, one that anonymises can't.",
+		Query: []openapi.Parameter{
+			d.QueryParam("purge", "also remove what the user wrote", false),
+			enum(d.QueryParam("mode", "what a purge does with the content", ""), domain.PurgeDelete, domain.PurgeAnonymise),
+		},
+		Response: domain.PurgeResult{}})
+	v.Add(openapi.Route{Method: "PUT", Path: "/users/:id/lock", Tag: "users", Summary: "Lock a user", Auth: true,
+		Response: ""})
+	v.Add(openapi.Route{Method: "POST", Path: "/users/:id/export", Tag: "privacy", Auth: true,
+		Summary: "Export everything stored about a user as a zip archive", Raw: "application/zip"})
+	v.Add(openapi.Route{Method: "POST", Path: "/users/:id/erasure", Tag: "privacy", Auth: true,
+		Summary: "Schedule a user's erasure after the cooling off period", Status: 202, Response: domain.ErasureRequest{}})
+
+	v.Add(openapi.Route{Method: "GET", Path: "/erasure/:id", Tag: "privacy", Summary: "Get an erasure request", Auth: true,
+		Response: domain.ErasureRequest{}})
+	v.Add(openapi.Route{Method: "DELETE", Path: "/erasure/:id", Tag: "privacy", Auth: true,
+		Summary: "Cancel an erasure during its cooling off period", Response: ""})
+
+	v.Add(openapi.Route{Method: "GET", Path: "/review/", Tag: "review", Summary: "List content waiting for review", Auth: true,
+		Query: []openapi.Parameter{page}, Response: []domain.ReviewItem{}})
+	v.Add(openapi.Route{Method: "PUT", Path: "/review/:id/confirm", Tag: "review", Auth: true,
+		Summary: "Confirm the flags on a review item", Response: ""})
+	v.Add(openapi.Route{Method: "PUT", Path: "/review/:id/reverse", Tag: "review", Auth: true,
+		Summary: "Reverse an automatic hide", Response: ""})
+
+	v.Add(openapi.Route{Method: "POST", Path: "/appeals/", Tag: "appeals", Summary: "File an appeal", Auth: true,
+		Body: domain.Appeal{}, Status: 201, Response: domain.Appeal{},
+		Description: "Only deletes and locks can be appealed, other actions are turned down with a 409."})
+	v.Add(openapi.Route{Method: "GET", Path: "/appeals/", Tag: "appeals", Summary: "List appeals", Auth: true,
+		Query: []openapi.Parameter{page}, Response: []domain.Appeal{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/appeals/:id", Tag: "appeals", Summary: "Get an appeal", Auth: true,
+		Response: domain.Appeal{}})
+	v.Add(openapi.Route{Method: "PUT", Path: "/appeals/:id/uphold", Tag: "appeals", Auth: true,
+		Summary: "Uphold an appeal, keeping the moderation action", Body: domain.AppealDecision{}, Response: domain.Appeal{}})
+	v.Add(openapi.Route{Method: "PUT", Path: "/appeals/:id/overturn", Tag: "appeals", Auth: true,
+		Summary: "Overturn an appeal, undoing the moderation action", Body: domain.AppealDecision{}, Response: domain.Appeal{}})
+
+	v.Add(openapi.Route{Method: "POST", Path: "/cases/", Tag: "cases", Summary: "Open a case", Auth: true,
+		Body: domain.Case{}, Status: 201, Response: domain.Case{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/cases/", Tag: "cases", Summary: "List cases", Auth: true,
+		Query: []openapi.Parameter{page,
+			enum(d.QueryParam("status", "only cases with this status", ""), domain.CaseOpen, domain.CaseInvestigating, domain.CaseResolved)},
+		Response: []domain.Case{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/cases/mine", Tag: "cases", Summary: "List the open cases assigned to me", Auth: true,
+		Query: []openapi.Parameter{page}, Response: []domain.Case{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/cases/:id", Tag: "cases", Summary: "Get a case", Auth: true,
+		Response: domain.Case{}})
+	v.Add(openapi.Route{Method: "PUT", Path: "/cases/:id", Tag: "cases", Summary: "Edit a case", Auth: true,
+		Body: domain.CaseUpdate{}, Response: domain.Case{}})
+	v.Add(openapi.Route{Method: "DELETE", Path: "/cases/:id", Tag: "cases", Summary: "Delete a case", Auth: true,
+		Status: 204})
+	v.Add(openapi.Route{Method: "PUT", Path: "/cases/:id/assign", Tag: "cases", Summary: "Assign a case", Auth: true,
+		Body: domain.CaseAssignment{}, Response: domain.Case{}})
+	v.Add(openapi.Route{Method: "PUT", Path: "/cases/:id/status", Tag: "cases", Summary: "Move a case to another status", Auth: true,
+		Body: domain.CaseStatusUpdate{}, Response: domain.Case{}})
+	v.Add(openapi.Route{Method: "POST", Path: "/cases/:id/notes", Tag: "cases", Summary: "Add a note to a case", Auth: true,
+		Body: domain.CaseNote{}, Status: 201, Response: domain.Case{}})
+	v.Add(openapi.Route{Method: "POST", Path: "/cases/:id/evidence", Tag: "cases", Auth: true,
+		Summary: "Attach a snapshot of a resource to a case", Body: domain.CaseEvidence{}, Status: 201, Response: domain.Case{}})
+
+	v.Add(openapi.Route{Method: "POST", Path: "/bulk/", Tag: "bulk", Auth: true,
+		Summary:     "Start a bulk action",
+		Description: "A dry run answers 200 with the targets it would act on, otherwise the job starts in the background and a 202 returns it.",
+		Body:        domain.BulkRequest{}, Status: 202, Response: domain.BulkJob{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/bulk/:id", Tag: "bulk", Summary: "Get a bulk job's progress", Auth: true,
+		Response: domain.BulkJob{}})
+	v.Add(openapi.Route{Method: "DELETE", Path: "/bulk/:id", Tag: "bulk", Summary: "Cancel a running bulk job", Auth: true,
+		Status: 202, Response: ""})
+
+	v.Add(openapi.Route{Method: "POST", Path: "/rebuilds/", Tag: "rebuilds", Auth: true,
+		Summary: "Rebuild the users collection from kafka",
+		Description: "Replays the topics from fromOffset in every partition, from the first message at fromTime, or from the " +
+			"oldest message, through the consumer's processing into a shadow collection. Once it has caught up the " +
+			"shadow replaces the users collection, keeping locks, hides, flags and erasures and leaving out users deleted " +
+			"meanwhile. One rebuild runs at a time.",
+		Body: domain.RebuildRequest{}, Status: 202, Response: domain.RebuildJob{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/rebuilds/:id", Tag: "rebuilds", Summary: "Get a rebuild's progress", Auth: true,
+		Response: domain.RebuildJob{}})
+	v.Add(openapi.Route{Method: "DELETE", Path: "/rebuilds/:id", Tag: "rebuilds", Auth: true,
+		Summary: "Cancel a running rebuild and drop its shadow",
+		Description: "Works from any instance, the one running the rebuild stops within a few seconds. A rebuild that has " +
+			"started swapping finishes.",
+		Status: 202, Response: ""})
+
+	v.Add(openapi.Route{Method: "GET", Path: "/feed", Tag: "feed", Auth: true,
+		Summary: "Stream the moderation feed as server-sent events",
+		Description: "New users, flags and appeals as the consumer processes them and every moderation action, each as an " +
+			"event named by its type with a FeedEvent as data. Reconnecting with Last-Event-ID resumes after that event, " +
+			"a reset event means it's no longer in the feed and the client should reload.",
+		Query: []openapi.Parameter{
+			d.QueryParam("types", "comma separated event types, like flag.created,moderation.*", ""),
+			d.QueryParam("tags", "comma separated story tags", ""),
+			d.QueryParam("last_event_id", "Last-Event-ID for clients that can't set headers", ""),
+			{Name: "Last-Event-ID", In: "header", Description: "id of the last event received", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Raw: "text/event-stream"})
+	d.SchemaOf(domain.FeedEvent{})
+
+	v.Add(openapi.Route{Method: "POST", Path: "/webhooks/", Tag: "webhooks", Summary: "Register a webhook", Auth: true,
+		Description: "Matching feed events are posted to the url as json. Each delivery is signed: X-Webhook-Signature is " +
+			"sha256= and the hex HMAC-SHA256, keyed with the secret, of X-Webhook-Timestamp, a dot and the body. " +
+			"A non 2xx answer is retried with exponential backoff. The secret is generated when it's left out and only returned here.",
+		Body: domain.Webhook{}, Status: 201, Response: domain.Webhook{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/webhooks/", Tag: "webhooks", Summary: "List webhooks", Auth: true,
+		Query: []openapi.Parameter{page}, Response: []domain.Webhook{}})
+	v.Add(openapi.Route{Method: "GET", Path: "/webhooks/:id", Tag: "webhooks", Summary: "Get a webhook", Auth: true,
+		Response: domain.Webhook{}})
+	v.Add(openapi.Route{Method: "DELETE", Path: "/webhooks/:id", Tag: "webhooks", Auth: true,
+		Summary: "Delete a webhook and its delivery log", Status: 204})
+	v.Add(openapi.Route{Method: "GET", Path: "/webhooks/:id/deliveries", Tag: "webhooks", Auth: true,
+		Summary: "List a webhook's deliveries with every attempt", Query: []openapi.Parameter{page}, Response: []domain.WebhookDelivery{}})
+	v.Add(openapi.Route{Method: "POST", Path: "/webhooks/deliveries/:id/redeliver", Tag: "webhooks", Auth: true,
+		Summary: "Send a delivery again", Status: 202, Response: domain.WebhookDelivery{}})
+}
+
+func enum(p openapi.Parameter, values ...string) openapi.Parameter {
+	p.Schema.Enum = values
+	return p
+}
+
+func float(f float64) *float64 {
+	return &f
+}
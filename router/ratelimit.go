@@ -0,0 +1,49 @@
+package router
+
+import (
+	"example.com/app/config"
+	"example.com/app/database"
+	"example.com/app/logger"
+	"example.com/app/middleware"
+	"example.com/app/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// budgets are the rate limits of each kind of route, a caller has a separate bucket in each. Destructive routes
+// delete, lock or erase, write routes change anything else.
+type budgets struct {
+	login       fiber.Handler
+	read        fiber.Handler
+	write       fiber.Handler
+	destructive fiber.Handler
+}
+
+func newBudgets(conn *database.Connection) budgets {
+	cfg := config.Get()
+
+	if cfg.RateLimitStore == "off" {
+		next := func(c *fiber.Ctx) error { return c.Next() }
+		return budgets{login: next, read: next, write: next, destructive: next}
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	// the openapi subcommand builds the router without a database
+	if cfg.RateLimitStore == "mongo" && conn != nil {
+		store = ratelimit.NewMongoStore(conn.RateLimitCollection)
+	}
+
+	budget := func(name, limit string) fiber.Handler {
+		l, err := ratelimit.Parse(limit)
+		if err != nil {
+			logger.L().Fatal("invalid configuration", "budget", name, "error", err)
+		}
+		return middleware.RateLimit(store, name, l)
+	}
+
+	return budgets{
+		login:       budget("login", cfg.RateLimitLogin),
+		read:        budget("read", cfg.RateLimitRead),
+		write:       budget("write", cfg.RateLimitWrite),
+		destructive: budget("destructive", cfg.RateLimitDestructive),
+	}
+}
@@ -1,51 +1,79 @@
 package router
 
 import (
+	"example.com/app/config"
+	"example.com/app/database"
+	"example.com/app/event-consumer"
+	"example.com/app/events"
 	"example.com/app/handlers"
+	"example.com/app/health"
 	"example.com/app/middleware"
+	"example.com/app/openapi"
 	"example.com/app/repo"
 	"example.com/app/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"time"
 )
 
-func SetupRoutes(app *fiber.App) {
-	ch := handlers.CommentHandler{CommentService: services.NewCommentService(repo.NewCommentRepoImpl())}
-	sh := handlers.StoryHandler{StoryService: services.NewStoryService(repo.NewStoryRepoImpl())}
-	reh := handlers.ReplyHandler{ReplyService: services.NewReplyService(repo.NewReplyRepoImpl())}
-	uh := handlers.UserHandler{UserService: services.NewUserService(repo.NewUserRepoImpl())}
-	ah := handlers.AuthHandler{AuthService: services.NewAuthService(repo.NewAuthRepoImpl())}
+func SetupRoutes(app *fiber.App, conn *database.Connection) {
+	commentService := services.NewCommentService(repo.NewCommentRepoImpl(conn), repo.NewModerationActionRepoImpl(conn))
+	storyService := services.NewStoryService(repo.NewStoryRepoImpl(conn), repo.NewModerationActionRepoImpl(conn))
+	replyService := services.NewReplyService(repo.NewReplyRepoImpl(conn))
+	userService := services.NewUserService(repo.NewUserRepoImpl(conn), repo.NewModerationActionRepoImpl(conn))
 
-	app.Use(recover.New())
-	api := app.Group("", logger.New())
+	h := handlerSet{
+		comment: handlers.CommentHandler{CommentService: commentService},
+		story:   handlers.StoryHandler{StoryService: storyService},
+		reply:   handlers.ReplyHandler{ReplyService: replyService},
+		user:    handlers.UserHandler{UserService: userService},
+		auth:    handlers.AuthHandler{AuthService: services.NewAuthService(repo.NewAuthRepoImpl(conn))},
+		review:  handlers.ReviewHandler{ReviewService: services.NewReviewService(repo.NewReviewRepoImpl(conn))},
+		appeal:  handlers.AppealHandler{AppealService: services.NewAppealService(repo.NewAppealRepoImpl(conn))},
+		cases:   handlers.CaseHandler{CaseService: services.NewCaseService(repo.NewCaseRepoImpl(conn))},
+		privacy: handlers.PrivacyHandler{PrivacyService: services.NewPrivacyService(repo.NewPrivacyRepoImpl(conn))},
+		bulk:    handlers.BulkHandler{BulkService: services.NewBulkService(repo.NewBulkJobRepoImpl(conn), storyService, commentService, replyService, userService)},
+		feed:    handlers.FeedHandler{FeedService: services.NewFeedService(repo.NewFeedRepoImpl(conn))},
+		webhook: handlers.WebhookHandler{WebhookService: services.NewWebhookService(repo.NewWebhookRepoImpl(conn))},
+		rebuild: handlers.RebuildHandler{RebuildService: services.NewRebuildService(repo.NewRebuildRepoImpl(conn))},
+		limit:   newBudgets(conn),
+	}
+	hh := handlers.HealthHandler{Components: []health.Component{
+		{Name: "mongo", Timeout: 2 * time.Second, Check: conn.Ping},
+		{Name: "producer", Timeout: 3 * time.Second, Check: events.Ping},
+		{Name: "consumer", Timeout: time.Second, Check: event_consumer.Ping},
+	}}
 
-	stories := api.Group("application/storage/app/stories")
-	stories.Get("/:id", middleware.IsLoggedIn, sh.FindStory)
-	stories.Delete("/:id", middleware.IsLoggedIn, sh.DeleteStory)
-	stories.Get("/", middleware.IsLoggedIn, sh.FindAll)
+	app.Use(recover.New())
+	app.Use(middleware.Metrics)
 
-	comments := api.Group("application/storage/app/comment")
-	comments.Delete("/:id",middleware.IsLoggedIn, ch.DeleteById)
+	// probes for the orchestrator, outside the api group so they skip its request logging and auth
+	app.Get("/healthz", hh.Live)
+	app.Get("/readyz", hh.Ready)
+	app.Get("/metrics", handlers.Metrics)
+	app.Get("/openapi.json", Spec().Handler())
+	app.Get("/docs", openapi.UI("/openapi.json"))
 
-	reply := api.Group("application/storage/app/reply")
-	reply.Delete("/:id", middleware.IsLoggedIn, reh.DeleteById)
+	api := app.Group("", middleware.RequestID, middleware.Tracing, middleware.Timeout)
 
-	auth := api.Group("application/storage/app/auth")
-	auth.Post("/login", ah.Login)
+	// the unversioned routes answer like v1 until their sunset, every response points at the same route under /v1
+	cfg := config.Get()
+	registerV1(api.Group(legacyPrefix, middleware.APIVersion("v1"),
+		middleware.Deprecated(cfg.LegacyDeprecation, cfg.LegacySunset, legacyPrefix, v1Prefix)), h)
+	registerV1(api.Group(v1Prefix, middleware.APIVersion("v1")), h)
+	registerV2(api.Group(v2Prefix, middleware.APIVersion("v2")), h)
 
-	user := api.Group("application/storage/app/users")
-	user.Get("/", middleware.IsLoggedIn, uh.GetAllUsers)
-	user.Delete("/delete",middleware.IsLoggedIn,  uh.DeleteByID)
+	app.Use(handlers.NotFound)
 }
 
-func Setup() *fiber.App {
-	app := fiber.New()
+func Setup(conn *database.Connection) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
 	app.Use(cors.New(cors.Config{
-		ExposeHeaders: "Authorization",
+		ExposeHeaders: "Authorization, Deprecation, Sunset, Link, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After",
 	}))
 
-	SetupRoutes(app)
+	SetupRoutes(app, conn)
+
 	return app
 }
@@ -0,0 +1,34 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("SECRET", "test-secret")
+	os.Setenv("EXPIRATION", "5")
+	os.Setenv("DB_NAME", "mongodb://")
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", ":27017")
+	os.Exit(m.Run())
+}
+
+// TestSpecMatchesRoutes fails when a route is added without documenting it or a documented route goes away
+func TestSpecMatchesRoutes(t *testing.T) {
+	app := Setup(nil)
+
+	if err := Spec().Verify(app.Stack()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpecVerifyCatchesDrift(t *testing.T) {
+	app := Setup(nil)
+	app.Get("/v2/undocumented", func(c *fiber.Ctx) error { return nil })
+
+	if err := Spec().Verify(app.Stack()); err == nil {
+		t.Fatal("an undocumented route passed verification")
+	}
+}
@@ -0,0 +1,116 @@
+package router
+
+import (
+	"example.com/app/handlers"
+	"example.com/app/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	legacyPrefix = "/application/storage/app"
+	v1Prefix     = "/v1"
+	v2Prefix     = "/v2"
+)
+
+// handlerSet holds the handlers every api version registers its routes on
+type handlerSet struct {
+	comment handlers.CommentHandler
+	story   handlers.StoryHandler
+	reply   handlers.ReplyHandler
+	user    handlers.UserHandler
+	auth    handlers.AuthHandler
+	review  handlers.ReviewHandler
+	appeal  handlers.AppealHandler
+	cases   handlers.CaseHandler
+	privacy handlers.PrivacyHandler
+	bulk    handlers.BulkHandler
+	feed    handlers.FeedHandler
+	webhook handlers.WebhookHandler
+	rebuild handlers.RebuildHandler
+	limit   budgets
+}
+
+// registerV1 is the api as it was before versioning: the {"status", "message", "data"} envelope and lists
+// paged by page number
+func registerV1(r fiber.Router, h handlerSet) {
+	r.Get("/stories/", middleware.IsLoggedIn, h.limit.read, h.story.FindAll)
+	r.Get("/users/", middleware.IsLoggedIn, h.limit.read, h.user.GetAllUsers)
+	registerShared(r, h)
+}
+
+// registerV2 answers with a bare {"data"} envelope and pages the story and user lists by cursor
+func registerV2(r fiber.Router, h handlerSet) {
+	r.Get("/stories/", middleware.IsLoggedIn, h.limit.read, h.story.List)
+	r.Get("/users/", middleware.IsLoggedIn, h.limit.read, h.user.List)
+	registerShared(r, h)
+}
+
+// registerShared adds the routes that behave the same in every version apart from the envelope
+func registerShared(r fiber.Router, h handlerSet) {
+	stories := r.Group("/stories")
+	stories.Get("/:id", middleware.IsLoggedIn, h.limit.read, h.story.FindStory)
+	stories.Delete("/:id", middleware.IsLoggedIn, h.limit.destructive, h.story.DeleteStory)
+
+	comments := r.Group("/comment")
+	comments.Delete("/:id", middleware.IsLoggedIn, h.limit.destructive, h.comment.DeleteById)
+
+	reply := r.Group("/reply")
+	reply.Delete("/:id", middleware.IsLoggedIn, h.limit.destructive, h.reply.DeleteById)
+
+	auth := r.Group("/auth")
+	auth.Post("/login", h.limit.login, h.auth.Login)
+
+	user := r.Group("/users")
+	user.Delete("/:id", middleware.IsLoggedIn, h.limit.destructive, h.user.DeleteByID)
+	user.Put("/:id/lock", middleware.IsLoggedIn, h.limit.destructive, h.user.LockByID)
+	user.Post("/:id/export", middleware.IsLoggedIn, h.limit.write, h.privacy.Export)
+	user.Post("/:id/erasure", middleware.IsLoggedIn, h.limit.destructive, h.privacy.RequestErasure)
+
+	erasure := r.Group("/erasure")
+	erasure.Get("/:id", middleware.IsLoggedIn, h.limit.read, h.privacy.FindErasure)
+	erasure.Delete("/:id", middleware.IsLoggedIn, h.limit.write, h.privacy.CancelErasure)
+
+	review := r.Group("/review")
+	review.Get("/", middleware.IsLoggedIn, h.limit.read, h.review.FindAll)
+	review.Put("/:id/confirm", middleware.IsLoggedIn, h.limit.write, h.review.Confirm)
+	review.Put("/:id/reverse", middleware.IsLoggedIn, h.limit.write, h.review.Reverse)
+
+	appeals := r.Group("/appeals")
+	appeals.Post("/", middleware.IsLoggedIn, h.limit.write, h.appeal.Create)
+	appeals.Get("/", middleware.IsLoggedIn, h.limit.read, h.appeal.FindAll)
+	appeals.Get("/:id", middleware.IsLoggedIn, h.limit.read, h.appeal.FindById)
+	appeals.Put("/:id/uphold", middleware.IsLoggedIn, h.limit.write, h.appeal.Uphold)
+	appeals.Put("/:id/overturn", middleware.IsLoggedIn, h.limit.write, h.appeal.Overturn)
+
+	cases := r.Group("/cases")
+	cases.Post("/", middleware.IsLoggedIn, h.limit.write, h.cases.Create)
+	cases.Get("/", middleware.IsLoggedIn, h.limit.read, h.cases.FindAll)
+	cases.Get("/mine", middleware.IsLoggedIn, h.limit.read, h.cases.FindMine)
+	cases.Get("/:id", middleware.IsLoggedIn, h.limit.read, h.cases.FindById)
+	cases.Put("/:id", middleware.IsLoggedIn, h.limit.write, h.cases.UpdateById)
+	cases.Delete("/:id", middleware.IsLoggedIn, h.limit.write, h.cases.DeleteById)
+	cases.Put("/:id/assign", middleware.IsLoggedIn, h.limit.write, h.cases.Assign)
+	cases.Put("/:id/status", middleware.IsLoggedIn, h.limit.write, h.cases.UpdateStatus)
+	cases.Post("/:id/notes", middleware.IsLoggedIn, h.limit.write, h.cases.AddNote)
+	cases.Post("/:id/evidence", middleware.IsLoggedIn, h.limit.write, h.cases.AddEvidence)
+
+	bulk := r.Group("/bulk")
+	bulk.Post("/", middleware.IsLoggedIn, h.limit.destructive, h.bulk.Start)
+	bulk.Get("/:id", middleware.IsLoggedIn, h.limit.read, h.bulk.FindById)
+	bulk.Delete("/:id", middleware.IsLoggedIn, h.limit.write, h.bulk.Cancel)
+
+	rebuilds := r.Group("/rebuilds")
+	rebuilds.Post("/", middleware.IsLoggedIn, h.limit.destructive, h.rebuild.Start)
+	rebuilds.Get("/:id", middleware.IsLoggedIn, h.limit.read, h.rebuild.FindById)
+	rebuilds.Delete("/:id", middleware.IsLoggedIn, h.limit.write, h.rebuild.Cancel)
+
+	r.Get("/feed", middleware.IsLoggedIn, h.limit.read, h.feed.Stream)
+
+	webhooks := r.Group("/webhooks")
+	webhooks.Post("/", middleware.IsLoggedIn, h.limit.write, h.webhook.Create)
+	webhooks.Get("/", middleware.IsLoggedIn, h.limit.read, h.webhook.FindAll)
+	webhooks.Get("/:id", middleware.IsLoggedIn, h.limit.read, h.webhook.FindById)
+	webhooks.Delete("/:id", middleware.IsLoggedIn, h.limit.write, h.webhook.DeleteById)
+	webhooks.Get("/:id/deliveries", middleware.IsLoggedIn, h.limit.read, h.webhook.FindDeliveries)
+	webhooks.Post("/deliveries/:id/redeliver", middleware.IsLoggedIn, h.limit.write, h.webhook.Redeliver)
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"example.com/app/domain"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AppealService interface {
+	Create(context.Context, *domain.Appeal) error
+	FindAll(context.Context, string) (*[]domain.Appeal, error)
+	FindById(context.Context, primitive.ObjectID) (*domain.Appeal, error)
+	Decide(context.Context, primitive.ObjectID, string, string, string) (*domain.Appeal, error)
+}
+
+type DefaultAppealService struct {
+	repo repo.AppealRepo
+}
+
+func (a DefaultAppealService) Create(ctx context.Context, appeal *domain.Appeal) error {
+	err := a.repo.Create(ctx, appeal)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a DefaultAppealService) FindAll(ctx context.Context, page string) (*[]domain.Appeal, error) {
+	appeals, err := a.repo.FindAll(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	return appeals, nil
+}
+
+func (a DefaultAppealService) FindById(ctx context.Context, id primitive.ObjectID) (*domain.Appeal, error) {
+	appeal, err := a.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return appeal, nil
+}
+
+func (a DefaultAppealService) Decide(ctx context.Context, id primitive.ObjectID, status string, reviewer string, decision string) (*domain.Appeal, error) {
+	appeal, err := a.repo.Decide(ctx, id, status, reviewer, decision)
+	if err != nil {
+		return nil, err
+	}
+	return appeal, nil
+}
+
+func NewAppealService(repository repo.AppealRepo) DefaultAppealService {
+	return DefaultAppealService{repository}
+}
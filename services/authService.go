@@ -1,20 +1,21 @@
 package services
 
 import (
+	"context"
 	"example.com/app/domain"
 	"example.com/app/repo"
 )
 
 type AuthService interface {
-	Login(username string, password string, ip string, ips []string) (*domain.Admin, string, error)
+	Login(ctx context.Context, username string, password string, ip string, ips []string) (*domain.Admin, string, error)
 }
 
 type DefaultAuthService struct {
 	repo repo.AuthRepo
 }
 
-func (a DefaultAuthService) Login(username string, password string, ip string, ips []string) (*domain.Admin, string, error) {
-	u, token, err := a.repo.Login(username, password, ip, ips)
+func (a DefaultAuthService) Login(ctx context.Context, username string, password string, ip string, ips []string) (*domain.Admin, string, error) {
+	u, token, err := a.repo.Login(ctx, username, password, ip, ips)
 	if err != nil {
 		return nil, "", err
 	}
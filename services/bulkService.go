@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+)
+
+type BulkService interface {
+	Start(context.Context, *domain.BulkRequest, string) (*domain.BulkJob, error)
+	DryRun(context.Context, *domain.BulkRequest) (*[]domain.BulkTarget, error)
+	FindById(context.Context, primitive.ObjectID) (*domain.BulkJob, error)
+	Cancel(context.Context, primitive.ObjectID) error
+}
+
+// DefaultBulkService runs bulk moderation jobs in the background, one item at a time,
+// through the same services the single item endpoints use
+type DefaultBulkService struct {
+	repo     repo.BulkJobRepo
+	stories  StoryService
+	comments CommentService
+	replies  ReplyService
+	users    UserService
+}
+
+// runningJobs holds the cancel functions of the bulk jobs and rebuilds running in this instance
+var runningJobs = struct {
+	sync.Mutex
+	cancel map[primitive.ObjectID]context.CancelFunc
+}{cancel: map[primitive.ObjectID]context.CancelFunc{}}
+
+func (b DefaultBulkService) Start(ctx context.Context, request *domain.BulkRequest, actor string) (*domain.BulkJob, error) {
+	err := validateBulkRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := b.repo.FindTargets(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &domain.BulkJob{ResourceType: request.ResourceType, Action: request.Action, CreatedBy: actor, Total: len(*targets)}
+	err = b.repo.Create(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	// the job outlives the request that started it
+	jobCtx, cancel := context.WithCancel(context.Background())
+	runningJobs.Lock()
+	runningJobs.cancel[job.Id] = cancel
+	runningJobs.Unlock()
+
+	go b.run(jobCtx, job, *targets, actor)
+
+	return job, nil
+}
+
+// DryRun returns what a bulk request would act on without changing anything
+func (b DefaultBulkService) DryRun(ctx context.Context, request *domain.BulkRequest) (*[]domain.BulkTarget, error) {
+	err := validateBulkRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := b.repo.FindTargets(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+func (b DefaultBulkService) FindById(ctx context.Context, id primitive.ObjectID) (*domain.BulkJob, error) {
+	job, err := b.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Cancel stops a running job after the item it's working on
+func (b DefaultBulkService) Cancel(ctx context.Context, id primitive.ObjectID) error {
+	runningJobs.Lock()
+	cancel, ok := runningJobs.cancel[id]
+	runningJobs.Unlock()
+
+	if !ok {
+		return apperrors.Conflict("job_not_running", "job is not running")
+	}
+
+	cancel()
+	return nil
+}
+
+func (b DefaultBulkService) run(ctx context.Context, job *domain.BulkJob, targets []domain.BulkTarget, actor string) {
+	defer func() {
+		runningJobs.Lock()
+		delete(runningJobs.cancel, job.Id)
+		runningJobs.Unlock()
+	}()
+
+	lockedAuthors := map[string]bool{}
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			_ = b.repo.Finish(context.Background(), job.Id, domain.JobCancelled)
+			return
+		}
+
+		result := domain.BulkItemResult{ResourceId: target.Id, Status: "done"}
+
+		var err error
+		switch job.Action {
+		case domain.BulkDelete:
+			err = b.delete(ctx, job.ResourceType, target, actor)
+		case domain.BulkHide:
+			err = b.repo.Hide(ctx, job.ResourceType, target.Id, actor)
+		case domain.BulkLockAuthor:
+			// an author with many items only needs locking once
+			if lockedAuthors[target.AuthorUsername] {
+				result.Status = "skipped"
+				break
+			}
+			err = b.lockAuthor(ctx, target.AuthorUsername, actor)
+			if err == nil {
+				lockedAuthors[target.AuthorUsername] = true
+			}
+		}
+
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		}
+
+		_ = b.repo.AddResult(ctx, job.Id, result)
+	}
+
+	_ = b.repo.Finish(ctx, job.Id, domain.JobCompleted)
+}
+
+func (b DefaultBulkService) delete(ctx context.Context, resourceType string, target domain.BulkTarget, actor string) error {
+	var err error
+	switch resourceType {
+	case "story":
+		_, err = b.stories.DeleteById(ctx, target.Id, actor)
+	case "comment":
+		_, err = b.comments.DeleteById(ctx, target.Id, actor)
+	case "reply":
+		_, err = b.replies.DeleteById(ctx, target.Id, target.AuthorUsername)
+	default:
+		err = apperrors.Validation("invalid_resource_type", "invalid resource type")
+	}
+	return err
+}
+
+func (b DefaultBulkService) lockAuthor(ctx context.Context, username string, actor string) error {
+	u, err := b.users.FindByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	return b.users.LockByID(ctx, u.Id, actor)
+}
+
+func validateBulkRequest(request *domain.BulkRequest) error {
+	switch request.Action {
+	case domain.BulkDelete, domain.BulkHide, domain.BulkLockAuthor:
+	default:
+		return apperrors.Validation("invalid_action", "invalid action").WithField("action", "must be delete, hide or lock_author")
+	}
+
+	switch request.ResourceType {
+	case "story", "comment", "reply":
+	default:
+		return apperrors.Validation("invalid_resource_type", "invalid resource type")
+	}
+
+	return nil
+}
+
+func NewBulkService(repository repo.BulkJobRepo, stories StoryService, comments CommentService, replies ReplyService, users UserService) DefaultBulkService {
+	return DefaultBulkService{repository, stories, comments, replies, users}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"example.com/app/domain"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CaseService interface {
+	Create(context.Context, *domain.Case) error
+	FindAll(context.Context, string, string) (*[]domain.Case, error)
+	FindByAssignee(context.Context, string, string) (*[]domain.Case, error)
+	FindById(context.Context, primitive.ObjectID) (*domain.Case, error)
+	UpdateById(context.Context, primitive.ObjectID, *domain.CaseUpdate) (*domain.Case, error)
+	DeleteById(context.Context, primitive.ObjectID) error
+	Assign(context.Context, primitive.ObjectID, string) (*domain.Case, error)
+	UpdateStatus(context.Context, primitive.ObjectID, string) (*domain.Case, error)
+	AddNote(context.Context, primitive.ObjectID, *domain.CaseNote) (*domain.Case, error)
+	AddEvidence(context.Context, primitive.ObjectID, *domain.CaseEvidence) (*domain.Case, error)
+}
+
+type DefaultCaseService struct {
+	repo repo.CaseRepo
+}
+
+func (cs DefaultCaseService) Create(ctx context.Context, c *domain.Case) error {
+	err := cs.repo.Create(ctx, c)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cs DefaultCaseService) FindAll(ctx context.Context, page string, status string) (*[]domain.Case, error) {
+	cases, err := cs.repo.FindAll(ctx, page, status)
+	if err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+func (cs DefaultCaseService) FindByAssignee(ctx context.Context, page string, username string) (*[]domain.Case, error) {
+	cases, err := cs.repo.FindByAssignee(ctx, page, username)
+	if err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+func (cs DefaultCaseService) FindById(ctx context.Context, id primitive.ObjectID) (*domain.Case, error) {
+	c, err := cs.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cs DefaultCaseService) UpdateById(ctx context.Context, id primitive.ObjectID, update *domain.CaseUpdate) (*domain.Case, error) {
+	c, err := cs.repo.UpdateById(ctx, id, update)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cs DefaultCaseService) DeleteById(ctx context.Context, id primitive.ObjectID) error {
+	err := cs.repo.DeleteById(ctx, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cs DefaultCaseService) Assign(ctx context.Context, id primitive.ObjectID, assignee string) (*domain.Case, error) {
+	c, err := cs.repo.Assign(ctx, id, assignee)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cs DefaultCaseService) UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) (*domain.Case, error) {
+	c, err := cs.repo.UpdateStatus(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cs DefaultCaseService) AddNote(ctx context.Context, id primitive.ObjectID, note *domain.CaseNote) (*domain.Case, error) {
+	c, err := cs.repo.AddNote(ctx, id, note)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cs DefaultCaseService) AddEvidence(ctx context.Context, id primitive.ObjectID, evidence *domain.CaseEvidence) (*domain.Case, error) {
+	c, err := cs.repo.AddEvidence(ctx, id, evidence)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func NewCaseService(repository repo.CaseRepo) DefaultCaseService {
+	return DefaultCaseService{repository}
+}
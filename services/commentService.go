@@ -1,26 +1,30 @@
 package services
 
 import (
+	"context"
+	"example.com/app/domain"
 	"example.com/app/repo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CommentService interface {
-	DeleteById(id primitive.ObjectID) error
+	DeleteById(ctx context.Context, id primitive.ObjectID, actor string) (*domain.CascadeResult, error)
 }
 
 type DefaultCommentService struct {
-	repo repo.CommentRepo
+	repo    repo.CommentRepo
+	actions repo.ModerationActionRepo
 }
 
-func (c DefaultCommentService) DeleteById(id primitive.ObjectID) error {
-	err := c.repo.DeleteById(id)
+// DeleteById records the delete as a moderation action so it can be appealed
+func (c DefaultCommentService) DeleteById(ctx context.Context, id primitive.ObjectID, actor string) (*domain.CascadeResult, error) {
+	action, err := c.actions.Snapshot(ctx, domain.ActionDelete, "comment", id, actor)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return c.repo.DeleteById(ctx, id, action)
 }
 
-func NewCommentService(repository repo.CommentRepo) DefaultCommentService {
-	return DefaultCommentService{repository}
+func NewCommentService(repository repo.CommentRepo, actions repo.ModerationActionRepo) DefaultCommentService {
+	return DefaultCommentService{repository, actions}
 }
\ No newline at end of file
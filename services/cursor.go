@@ -0,0 +1,37 @@
+package services
+
+import (
+	"encoding/base64"
+	"example.com/app/apperrors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// encodeCursor makes the opaque next_cursor of a page from the id of its last item. Clients are told to pass
+// it back as is, so what's inside can change without breaking them.
+func encodeCursor(id primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id.Hex()))
+}
+
+// decodeCursor reads a cursor from encodeCursor, an empty one starts at the first page
+func decodeCursor(cursor string) (primitive.ObjectID, error) {
+	if cursor == "" {
+		return primitive.NilObjectID, nil
+	}
+
+	hex, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return primitive.NilObjectID, invalidCursor()
+	}
+
+	id, err := primitive.ObjectIDFromHex(string(hex))
+	if err != nil {
+		return primitive.NilObjectID, invalidCursor()
+	}
+
+	return id, nil
+}
+
+func invalidCursor() error {
+	return apperrors.Validation("invalid_cursor", "cursor must be the next_cursor of a previous page").
+		WithField("cursor", "must be the next_cursor of a previous page")
+}
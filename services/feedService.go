@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+// feedBacklog is the most events a reconnecting subscriber is sent to catch up, one that missed more is
+// told to reload
+const feedBacklog = 500
+
+// feedBuffer is how many events a subscriber can fall behind before it's dropped, it reconnects and resumes
+const feedBuffer = 64
+
+// feedSeen is how many of the latest event ids are remembered to drop repeats, more than a backlog so the live
+// events that overlap it are always recognised
+const feedSeen = 2 * feedBacklog
+
+type FeedService interface {
+	Subscribe(ctx context.Context, filter domain.FeedFilter, lastEventId string) (*Subscription, error)
+}
+
+// Subscription is one client of the feed. Backlog is what it missed since its last event id, Reset is set when
+// that event is too old to resume from. Events closes when the client falls too far behind.
+type Subscription struct {
+	Backlog []domain.FeedEvent
+	Reset   bool
+	Events  <-chan domain.FeedEvent
+	seen    *recentIds
+	close   func()
+}
+
+// Fresh reports whether the event hasn't been sent yet, the live events can overlap the backlog
+func (s *Subscription) Fresh(e domain.FeedEvent) bool {
+	return s.seen.add(e.Id)
+}
+
+func (s *Subscription) Close() {
+	if s.close != nil {
+		s.close()
+	}
+}
+
+// DefaultFeedService fans the events every instance writes out to the subscribers of this one. The feed is only
+// tailed while someone is subscribed.
+type DefaultFeedService struct {
+	hub *feedHub
+}
+
+type feedHub struct {
+	repo repo.FeedRepo
+	mu   sync.Mutex
+	subs map[chan domain.FeedEvent]domain.FeedFilter
+	stop context.CancelFunc
+}
+
+// Subscribe starts a subscription, lastEventId resumes after that event when it's still in the feed
+func (s DefaultFeedService) Subscribe(ctx context.Context, filter domain.FeedFilter, lastEventId string) (*Subscription, error) {
+	var after primitive.ObjectID
+	if lastEventId != "" {
+		id, err := primitive.ObjectIDFromHex(lastEventId)
+		if err != nil {
+			return nil, apperrors.Validation("invalid_last_event_id", "Last-Event-ID must be the id of a feed event").
+				WithField("Last-Event-ID", "must be the id of a feed event")
+		}
+		after = id
+	}
+
+	// listen before reading the backlog so nothing written in between is missed, Fresh drops the overlap
+	ch := s.hub.subscribe(filter)
+	sub := &Subscription{Events: ch, seen: newRecentIds(feedSeen), close: func() { s.hub.unsubscribe(ch) }}
+
+	if after.IsZero() {
+		return sub, nil
+	}
+
+	ok, err := s.hub.repo.Exists(ctx, after)
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	if !ok {
+		sub.Reset = true
+		return sub, nil
+	}
+
+	events, err := s.hub.repo.FindAfter(ctx, after, feedBacklog)
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	if len(events) == feedBacklog {
+		sub.Reset = true
+		return sub, nil
+	}
+
+	sub.seen.add(after)
+	for _, e := range events {
+		if filter.Matches(e) {
+			sub.Backlog = append(sub.Backlog, e)
+		}
+		sub.seen.add(e.Id)
+	}
+
+	return sub, nil
+}
+
+func (h *feedHub) subscribe(filter domain.FeedFilter) chan domain.FeedEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan domain.FeedEvent, feedBuffer)
+	h.subs[ch] = filter
+
+	if h.stop == nil {
+		ctx, stop := context.WithCancel(context.Background())
+		h.stop = stop
+		go h.tail(ctx)
+	}
+
+	return ch
+}
+
+func (h *feedHub) unsubscribe(ch chan domain.FeedEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+
+	if len(h.subs) == 0 && h.stop != nil {
+		h.stop()
+		h.stop = nil
+	}
+}
+
+// broadcast hands the event to every subscriber that wants it, one that isn't keeping up is dropped rather
+// than holding up the rest
+func (h *feedHub) broadcast(e domain.FeedEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch, filter := range h.subs {
+		if !filter.Matches(e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// tail follows the feed from now on until ctx is cancelled. A tailable cursor dies when the collection is empty
+// or after a failover, it's opened again where the last one stopped, backing off while Mongo is failing. When
+// that event has been overwritten meanwhile the new cursor starts a little earlier, the repeats are dropped.
+func (h *feedHub) tail(ctx context.Context) {
+	var after primitive.ObjectID
+	seen := newRecentIds(feedSeen)
+	wait := time.Second
+
+	for ctx.Err() == nil {
+		var err error
+		after, err = h.repo.Tail(ctx, after, func(e domain.FeedEvent) {
+			if seen.add(e.Id) {
+				h.broadcast(e)
+			}
+		})
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			if wait < 30*time.Second {
+				wait *= 2
+			}
+			logger.L().Warn("error tailing the moderation feed", "error", err, "retry_in", wait.String())
+		default:
+			wait = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+}
+
+// recentIds remembers the latest ids it was given, up to a limit. Events are told apart by id rather than by
+// comparing ids, which aren't made in the order the events are written.
+type recentIds struct {
+	ids  map[primitive.ObjectID]bool
+	ring []primitive.ObjectID
+	next int
+}
+
+func newRecentIds(limit int) *recentIds {
+	return &recentIds{ids: map[primitive.ObjectID]bool{}, ring: make([]primitive.ObjectID, limit)}
+}
+
+// add remembers the id, forgetting the oldest one when full, and reports whether it's new
+func (r *recentIds) add(id primitive.ObjectID) bool {
+	if r.ids[id] {
+		return false
+	}
+
+	delete(r.ids, r.ring[r.next])
+	r.ring[r.next] = id
+	r.next = (r.next + 1) % len(r.ring)
+	r.ids[id] = true
+	return true
+}
+
+func NewFeedService(repository repo.FeedRepo) DefaultFeedService {
+	return DefaultFeedService{hub: &feedHub{repo: repository, subs: map[chan domain.FeedEvent]domain.FeedFilter{}}}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/repo"
+	"math"
+	"time"
+)
+
+const (
+	// outboxLease is how long a claimed entry is left alone, longer than the producer takes to give up on a send
+	outboxLease = time.Minute
+	// the wait before sending a failed entry again starts at relayRetryBase and doubles up to relayRetryMax
+	relayRetryBase = time.Second
+	relayRetryMax  = 5 * time.Minute
+)
+
+type OutboxService interface {
+	RunRelay(context.Context, time.Duration)
+}
+
+type DefaultOutboxService struct {
+	repo repo.OutboxRepo
+}
+
+// RunRelay sends the outbox to kafka, checking for due entries every interval until ctx is done. An entry is only
+// marked sent once kafka has acknowledged it, so it's sent again if the process dies in between and consumers
+// drop the copy by its X-Message-ID.
+func (o DefaultOutboxService) RunRelay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			entry, err := o.repo.ClaimDue(ctx, time.Now(), outboxLease)
+			if err != nil {
+				logger.FromContext(ctx).Error("error claiming outbox entry", "error", err)
+				break
+			}
+			if entry == nil {
+				break
+			}
+
+			o.relay(ctx, entry)
+		}
+	}
+}
+
+// relay sends one entry and records the outcome, a failed entry stays pending and is retried with backoff
+func (o DefaultOutboxService) relay(ctx context.Context, entry *domain.OutboxEntry) {
+	log := logger.FromContext(ctx)
+
+	if err := o.repo.Publish(ctx, entry); err != nil {
+		next := time.Now().Add(relayBackoff(entry.Attempts + 1))
+		log.Warn("outbox entry not sent, retrying", "message_id", entry.Id.Hex(), "topic", entry.Topic,
+			"attempt", entry.Attempts+1, "retry_at", next, "error", err)
+
+		if err = o.repo.MarkFailed(ctx, entry.Id, err, next); err != nil {
+			log.Error("error recording outbox failure", "message_id", entry.Id.Hex(), "error", err)
+		}
+		return
+	}
+
+	if err := o.repo.MarkSent(ctx, entry.Id); err != nil {
+		// the lease runs out and the entry is sent again
+		log.Error("error marking outbox entry sent", "message_id", entry.Id.Hex(), "error", err)
+	}
+}
+
+// relayBackoff is the wait after the nth failed send
+func relayBackoff(n int) time.Duration {
+	return time.Duration(math.Min(float64(relayRetryBase)*math.Pow(2, float64(n-1)), float64(relayRetryMax)))
+}
+
+func NewOutboxService(repository repo.OutboxRepo) DefaultOutboxService {
+	return DefaultOutboxService{repo: repository}
+}
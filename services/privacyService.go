@@ -0,0 +1,147 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/app/config"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type PrivacyService interface {
+	Export(context.Context, primitive.ObjectID, string) ([]byte, error)
+	RequestErasure(context.Context, primitive.ObjectID, string) (*domain.ErasureRequest, error)
+	FindErasureById(context.Context, primitive.ObjectID) (*domain.ErasureRequest, error)
+	CancelErasure(context.Context, primitive.ObjectID) error
+	RunErasures(context.Context, time.Duration)
+}
+
+type DefaultPrivacyService struct {
+	repo repo.PrivacyRepo
+}
+
+// Export returns a zip archive with the user as user.json and each collection as newline delimited json
+func (p DefaultPrivacyService) Export(ctx context.Context, id primitive.ObjectID, actor string) ([]byte, error) {
+	export, err := p.repo.Export(ctx, id, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	archive := zip.NewWriter(buf)
+
+	w, err := archive.Create("user.json")
+	if err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(export.User); err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		docs []bson.M
+	}{
+		{"stories.ndjson", export.Stories},
+		{"comments.ndjson", export.Comments},
+		{"replies.ndjson", export.Replies},
+		{"flags_filed.ndjson", export.FlagsFiled},
+		{"flags_against.ndjson", export.FlagsAgainst},
+		{"audit.ndjson", export.Audit},
+	}
+
+	for _, f := range files {
+		w, err := archive.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		// Encode writes one document per line
+		enc := json.NewEncoder(w)
+		for _, doc := range f.docs {
+			if err = enc.Encode(doc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// RequestErasure schedules the erasure after the cooling off period set by ERASURE_COOLING_OFF_HOURS
+func (p DefaultPrivacyService) RequestErasure(ctx context.Context, userId primitive.ObjectID, actor string) (*domain.ErasureRequest, error) {
+	coolingOff := time.Duration(config.Get().ErasureCoolingOffHours) * time.Hour
+
+	request := &domain.ErasureRequest{UserId: userId, RequestedBy: actor, ScheduledFor: time.Now().Add(coolingOff)}
+
+	err := p.repo.CreateErasure(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (p DefaultPrivacyService) FindErasureById(ctx context.Context, id primitive.ObjectID) (*domain.ErasureRequest, error) {
+	request, err := p.repo.FindErasureById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (p DefaultPrivacyService) CancelErasure(ctx context.Context, id primitive.ObjectID) error {
+	err := p.repo.CancelErasure(ctx, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RunErasures carries out due erasure requests, checking for new ones every interval until ctx is done
+func (p DefaultPrivacyService) RunErasures(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			request, err := p.repo.ClaimDueErasure(ctx, time.Now())
+			if err != nil {
+				logger.FromContext(ctx).Error("error claiming erasure request", "error", err)
+				break
+			}
+
+			if request == nil {
+				break
+			}
+
+			_, err = p.repo.Erase(ctx, request)
+			if err != nil {
+				logger.FromContext(ctx).Error("erasure failed", "erasure_id", request.Id, "error", err)
+				_ = p.repo.FailErasure(ctx, request.Id, err)
+			}
+		}
+	}
+}
+
+func NewPrivacyService(repository repo.PrivacyRepo) DefaultPrivacyService {
+	return DefaultPrivacyService{repository}
+}
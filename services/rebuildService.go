@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"example.com/app/apperrors"
+	"example.com/app/config"
+	"example.com/app/domain"
+	"example.com/app/events"
+	"example.com/app/logger"
+	"example.com/app/repo"
+	"example.com/app/validation"
+	"fmt"
+	"github.com/Shopify/sarama"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	// rebuildProgressInterval is how often a rebuild saves its progress, well within the time after which it's
+	// taken to have died
+	rebuildProgressInterval = 5 * time.Second
+	// rebuildKeptErrors is how many of the latest errors a rebuild keeps
+	rebuildKeptErrors = 20
+	// rebuildHoldWait is how long a rebuild about to swap waits for the consumers of the other instances to see
+	// it and hold off, a few rounds of HoldConsumer's interval
+	rebuildHoldWait = 3 * time.Second
+)
+
+type RebuildService interface {
+	Start(context.Context, *domain.RebuildRequest, string) (*domain.RebuildJob, error)
+	FindById(context.Context, primitive.ObjectID) (*domain.RebuildJob, error)
+	Cancel(context.Context, primitive.ObjectID) error
+	HoldConsumer(context.Context, time.Duration)
+}
+
+// DefaultRebuildService rebuilds the users collection in the background by replaying the topics through
+// ProcessMessage into a shadow collection, which replaces the users collection once it has caught up
+type DefaultRebuildService struct {
+	repo repo.RebuildRepo
+}
+
+func (r DefaultRebuildService) Start(ctx context.Context, request *domain.RebuildRequest, actor string) (*domain.RebuildJob, error) {
+	err := validateRebuildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := request.Topics
+	if len(topics) == 0 {
+		topics = []string{config.Get().ConsumerTopic}
+	}
+
+	replayer, err := events.NewReplayer()
+	if err != nil {
+		return nil, apperrors.Unavailable("kafka_unavailable", "cannot reach kafka")
+	}
+
+	plan, err := replayer.Plan(topics, request.FromOffset, request.FromTime)
+	if err != nil {
+		_ = replayer.Close()
+		if err == sarama.ErrUnknownTopicOrPartition {
+			return nil, apperrors.Validation("unknown_topic", "unknown topic").WithField("topics", "must be existing topics")
+		}
+		return nil, apperrors.Unavailable("kafka_unavailable", "cannot reach kafka")
+	}
+
+	job := &domain.RebuildJob{Topics: topics, FromOffset: request.FromOffset, FromTime: request.FromTime, CreatedBy: actor}
+	err = r.repo.Create(ctx, job)
+	if err != nil {
+		_ = replayer.Close()
+		return nil, err
+	}
+	job.Partitions = plan
+
+	// the rebuild outlives the request that started it
+	jobCtx, cancel := context.WithCancel(context.Background())
+	runningJobs.Lock()
+	runningJobs.cancel[job.Id] = cancel
+	runningJobs.Unlock()
+
+	// the job returned is answered while the rebuild moves its own copy on
+	running := *job
+	running.Partitions = append([]domain.RebuildPartition{}, plan...)
+	go r.run(jobCtx, &running, replayer)
+	go r.watch(jobCtx, job.Id, cancel)
+
+	return job, nil
+}
+
+func (r DefaultRebuildService) FindById(ctx context.Context, id primitive.ObjectID) (*domain.RebuildJob, error) {
+	job, err := r.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Cancel stops a running rebuild and drops its shadow, the users collection is left as it was unless the swap
+// has already started. The cancel is saved on the job for whichever instance runs it, this one stops straight
+// away.
+func (r DefaultRebuildService) Cancel(ctx context.Context, id primitive.ObjectID) error {
+	if err := r.repo.RequestCancel(ctx, id); err != nil {
+		return err
+	}
+
+	runningJobs.Lock()
+	cancel, ok := runningJobs.cancel[id]
+	runningJobs.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return nil
+}
+
+// HoldConsumer holds off this instance's consumer while a rebuild on another instance swaps its shadow in, so
+// nothing it applies goes to the collection that is about to be replaced. It checks every interval until ctx is
+// done.
+func (r DefaultRebuildService) HoldConsumer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var resume func()
+	defer func() {
+		if resume != nil {
+			resume()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		job, err := r.repo.Swapping(ctx)
+		if err != nil {
+			logger.FromContext(ctx).Error("error checking for a rebuild swapping", "error", err)
+			continue
+		}
+
+		// a rebuild of this instance holds the consumer itself
+		if job != nil {
+			runningJobs.Lock()
+			_, local := runningJobs.cancel[job.Id]
+			runningJobs.Unlock()
+			if local {
+				job = nil
+			}
+		}
+
+		switch {
+		case job != nil && resume == nil:
+			logger.FromContext(ctx).Info("holding off the consumer for a rebuild swap", "rebuild_id", job.Id.Hex())
+			resume = events.PauseConsuming()
+		case job == nil && resume != nil:
+			resume()
+			resume = nil
+		}
+	}
+}
+
+// watch stops the rebuild when it's cancelled on any instance, or failed as abandoned by one that took it to
+// have died, checking as often as the rebuild saves its progress
+func (r DefaultRebuildService) watch(ctx context.Context, id primitive.ObjectID, cancel context.CancelFunc) {
+	ticker := time.NewTicker(rebuildProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		job, err := r.repo.FindById(ctx, id)
+		if err != nil {
+			continue
+		}
+
+		if job.CancelRequested || job.Status != domain.JobRunning {
+			cancel()
+			return
+		}
+	}
+}
+
+func (r DefaultRebuildService) run(ctx context.Context, job *domain.RebuildJob, replayer *events.Replayer) {
+	log := logger.FromContext(ctx).With("rebuild_id", job.Id.Hex())
+
+	defer func() {
+		_ = replayer.Close()
+		runningJobs.Lock()
+		cancel := runningJobs.cancel[job.Id]
+		delete(runningJobs.cancel, job.Id)
+		runningJobs.Unlock()
+		// stops the watch
+		cancel()
+	}()
+
+	status, cause := domain.JobCompleted, ""
+
+	if err := r.rebuild(ctx, job, replayer); err != nil {
+		status, cause = domain.JobFailed, err.Error()
+		if ctx.Err() != nil {
+			status, cause = domain.JobCancelled, ""
+		}
+
+		if err := r.repo.DropShadow(context.Background(), job.Shadow); err != nil {
+			log.Error("error dropping rebuild shadow", "shadow", job.Shadow, "error", err)
+		}
+	}
+
+	if err := r.repo.Finish(context.Background(), job.Id, status, cause); err != nil {
+		log.Error("error finishing rebuild", "error", err)
+	}
+
+	log.Info("rebuild finished", "status", status, "applied", job.Applied, "skipped", job.Skipped,
+		"failed", job.Failed, "error", cause)
+}
+
+// rebuild replays the planned offsets, catches up with what was written meanwhile and swaps the shadow in. The
+// last catch up and the swap hold off this instance's consumer, and the swapping phase is saved first so the
+// other instances hold off theirs, nothing they apply is lost with the old collection. Once the last catch up is
+// done the swap goes through even if the rebuild is cancelled.
+func (r DefaultRebuildService) rebuild(ctx context.Context, job *domain.RebuildJob, replayer *events.Replayer) error {
+	// a replay from the oldest message rebuilds from nothing, one from a later point applies on top of the users
+	seed := job.FromOffset != nil || job.FromTime != nil
+
+	err := r.repo.PrepareShadow(ctx, job.Shadow, seed)
+	if err != nil {
+		return err
+	}
+
+	if err = r.replay(ctx, job, replayer); err != nil {
+		return err
+	}
+
+	job.Phase = domain.RebuildCatchingUp
+	if err = r.catchUp(ctx, job, replayer); err != nil {
+		return err
+	}
+
+	job.Phase = domain.RebuildSwapping
+	if err = r.progress(ctx, job); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(rebuildHoldWait):
+	}
+
+	resume := events.PauseConsuming()
+	defer resume()
+
+	if err = r.catchUp(ctx, job, replayer); err != nil {
+		return err
+	}
+
+	return r.repo.Swap(context.Background(), job.Id, job.Shadow)
+}
+
+func (r DefaultRebuildService) catchUp(ctx context.Context, job *domain.RebuildJob, replayer *events.Replayer) error {
+	if err := replayer.Extend(job.Partitions); err != nil {
+		return apperrors.Unavailable("kafka_unavailable", "cannot reach kafka")
+	}
+	return r.replay(ctx, job, replayer)
+}
+
+// replay applies every partition up to its end, saving the progress as it goes
+func (r DefaultRebuildService) replay(ctx context.Context, job *domain.RebuildJob, replayer *events.Replayer) error {
+	saved := time.Now()
+
+	for i := range job.Partitions {
+		err := replayer.Replay(ctx, &job.Partitions[i], func(message *sarama.ConsumerMessage) error {
+			if err := r.apply(ctx, job, message); err != nil {
+				return err
+			}
+
+			if time.Since(saved) < rebuildProgressInterval {
+				return nil
+			}
+			saved = time.Now()
+			return r.progress(ctx, job)
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.progress(ctx, job)
+}
+
+// apply applies one message to the shadow. Like the consumer it passes over messages that fail validation, and
+// ones about anything but users are skipped since only the users collection is rebuilt. A message that can't be
+// read or applied is counted as failed, only errors that would fail every message stop the rebuild.
+func (r DefaultRebuildService) apply(ctx context.Context, job *domain.RebuildJob, message *sarama.ConsumerMessage) error {
+	envelope, err := events.DecodeMessage(message)
+	if err != nil {
+		r.fail(job, message, err)
+		return nil
+	}
+
+	switch envelope.Type {
+	case domain.MessageUserCreated, domain.MessageUserUpdated, domain.MessageUserDeleted:
+	default:
+		job.Skipped++
+		return nil
+	}
+
+	if err = validation.Message(*envelope); err != nil {
+		job.Skipped++
+		return nil
+	}
+
+	err = r.repo.Apply(ctx, job.Shadow, *envelope)
+
+	switch {
+	case err == nil:
+		job.Applied++
+	case apperrors.Is(err, apperrors.KindInternal), apperrors.Is(err, apperrors.KindTimeout),
+		apperrors.Is(err, apperrors.KindUnavailable):
+		return err
+	default:
+		r.fail(job, message, err)
+	}
+
+	return nil
+}
+
+// fail counts a failed message and keeps its error with where it is in the topic
+func (r DefaultRebuildService) fail(job *domain.RebuildJob, message *sarama.ConsumerMessage, err error) {
+	job.Failed++
+	job.Errors = append(job.Errors, fmt.Sprintf("%s/%d/%d: %v", message.Topic, message.Partition, message.Offset, err))
+	if len(job.Errors) > rebuildKeptErrors {
+		job.Errors = job.Errors[len(job.Errors)-rebuildKeptErrors:]
+	}
+}
+
+// progress saves the job with the share of the planned messages read so far, as a percentage
+func (r DefaultRebuildService) progress(ctx context.Context, job *domain.RebuildJob) error {
+	var read, total int64
+	for _, p := range job.Partitions {
+		total += p.End - p.Start
+		if p.Offset < p.End {
+			read += p.Offset - p.Start
+		} else {
+			read += p.End - p.Start
+		}
+	}
+
+	job.Progress = 100
+	if total > 0 {
+		job.Progress = float64(read) / float64(total) * 100
+	}
+
+	return r.repo.Update(ctx, job)
+}
+
+func validateRebuildRequest(request *domain.RebuildRequest) error {
+	if request.FromOffset != nil && request.FromTime != nil {
+		return apperrors.Validation("invalid_rebuild_start", "cannot start from both an offset and a time").
+			WithField("fromTime", "must not be set with fromOffset")
+	}
+
+	if request.FromOffset != nil && *request.FromOffset < 0 {
+		return apperrors.Validation("invalid_rebuild_start", "invalid offset").WithField("fromOffset", "must be at least 0")
+	}
+
+	return nil
+}
+
+func NewRebuildService(repository repo.RebuildRepo) DefaultRebuildService {
+	return DefaultRebuildService{repo: repository}
+}
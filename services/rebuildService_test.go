@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"example.com/app/domain"
+	"example.com/app/events"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"testing"
+	"time"
+)
+
+// swappingRebuildRepo reports the rebuild it's given as swapping, the rest of the repo isn't used
+type swappingRebuildRepo struct {
+	repo.RebuildRepo
+	mu  sync.Mutex
+	job *domain.RebuildJob
+}
+
+func (s *swappingRebuildRepo) set(job *domain.RebuildJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.job = job
+}
+
+func (s *swappingRebuildRepo) Swapping(context.Context) (*domain.RebuildJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job, nil
+}
+
+// consumes reports whether the consumer could apply a message within a while
+func consumes() bool {
+	done := make(chan struct{})
+	go func() {
+		events.Consuming()()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(200 * time.Millisecond):
+		return false
+	}
+}
+
+func TestHoldConsumerDuringRemoteSwap(t *testing.T) {
+	rebuilds := &swappingRebuildRepo{}
+	service := NewRebuildService(rebuilds)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.HoldConsumer(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	defer func() { cancel(); <-done }()
+
+	if !consumes() {
+		t.Fatal("the consumer is held with no rebuild swapping")
+	}
+
+	rebuilds.set(&domain.RebuildJob{Id: primitive.NewObjectID(), Status: domain.JobRunning, Phase: domain.RebuildSwapping})
+	time.Sleep(50 * time.Millisecond)
+	if consumes() {
+		t.Fatal("the consumer applied a message while another instance swaps")
+	}
+
+	rebuilds.set(nil)
+	if !consumes() {
+		t.Fatal("the consumer is still held after the swap")
+	}
+
+	// a rebuild of this instance holds the consumer itself
+	local := &domain.RebuildJob{Id: primitive.NewObjectID(), Status: domain.JobRunning, Phase: domain.RebuildSwapping}
+	runningJobs.Lock()
+	runningJobs.cancel[local.Id] = func() {}
+	runningJobs.Unlock()
+	defer func() {
+		runningJobs.Lock()
+		delete(runningJobs.cancel, local.Id)
+		runningJobs.Unlock()
+	}()
+
+	rebuilds.set(local)
+	time.Sleep(50 * time.Millisecond)
+	if !consumes() {
+		t.Fatal("the consumer is held for a rebuild of this instance")
+	}
+}
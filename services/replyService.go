@@ -1,24 +1,26 @@
 package services
 
 import (
+	"context"
+	"example.com/app/domain"
 	"example.com/app/repo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ReplyService interface {
-	DeleteById(id primitive.ObjectID, username string) error
+	DeleteById(ctx context.Context, id primitive.ObjectID, username string) (*domain.CascadeResult, error)
 }
 
 type DefaultReplyService struct {
 	repo repo.ReplyRepo
 }
 
-func (r DefaultReplyService) DeleteById(id primitive.ObjectID, username string) error {
-	err := r.repo.DeleteById(id, username)
+func (r DefaultReplyService) DeleteById(ctx context.Context, id primitive.ObjectID, username string) (*domain.CascadeResult, error) {
+	result, err := r.repo.DeleteById(ctx, id, username)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return result, nil
 }
 
 func NewReplyService(repository repo.ReplyRepo) DefaultReplyService {
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"example.com/app/domain"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReviewService interface {
+	FindAll(context.Context, string) (*[]domain.ReviewItem, error)
+	Confirm(context.Context, primitive.ObjectID, string) error
+	Reverse(context.Context, primitive.ObjectID, string) error
+}
+
+type DefaultReviewService struct {
+	repo repo.ReviewRepo
+}
+
+func (r DefaultReviewService) FindAll(ctx context.Context, page string) (*[]domain.ReviewItem, error) {
+	items, err := r.repo.FindAll(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r DefaultReviewService) Confirm(ctx context.Context, id primitive.ObjectID, username string) error {
+	err := r.repo.Confirm(ctx, id, username)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r DefaultReviewService) Reverse(ctx context.Context, id primitive.ObjectID, username string) error {
+	err := r.repo.Reverse(ctx, id, username)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func NewReviewService(repository repo.ReviewRepo) DefaultReviewService {
+	return DefaultReviewService{repository}
+}
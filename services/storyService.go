@@ -1,45 +1,69 @@
 package services
 
 import (
+	"context"
 	"example.com/app/domain"
 	"example.com/app/repo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type StoryService interface {
-	FindAll(string, bool) (*[]domain.Story, error)
-	FindById(primitive.ObjectID) (*domain.StoryDto, error)
-	DeleteById(primitive.ObjectID) error
+	FindAll(context.Context, string, bool) (*[]domain.Story, error)
+	ListStories(context.Context, string, int) (*domain.StoryPage, error)
+	FindById(context.Context, primitive.ObjectID) (*domain.StoryDto, error)
+	DeleteById(context.Context, primitive.ObjectID, string) (*domain.CascadeResult, error)
 }
 
 type DefaultStoryService struct {
-	repo repo.StoryRepo
+	repo    repo.StoryRepo
+	actions repo.ModerationActionRepo
 }
 
-func (s DefaultStoryService) FindAll(page string, newStoriesQuery bool) (*[]domain.Story, error) {
-	story, err := s.repo.FindAll(page, newStoriesQuery)
+func (s DefaultStoryService) FindAll(ctx context.Context, page string, newStoriesQuery bool) (*[]domain.Story, error) {
+	story, err := s.repo.FindAll(ctx, page, newStoriesQuery)
 	if err != nil {
 		return nil, err
 	}
 	return story, nil
 }
 
-func (s DefaultStoryService) FindById(id primitive.ObjectID) (*domain.StoryDto, error) {
-	story, err := s.repo.FindById(id)
+// ListStories returns the page of at most limit stories after cursor, newest first
+func (s DefaultStoryService) ListStories(ctx context.Context, cursor string, limit int) (*domain.StoryPage, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	stories, err := s.repo.FindAfter(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.StoryPage{Items: stories}
+	if len(stories) > limit {
+		page.Items = stories[:limit]
+		page.NextCursor = encodeCursor(page.Items[limit-1].Id)
+	}
+	return page, nil
+}
+
+func (s DefaultStoryService) FindById(ctx context.Context, id primitive.ObjectID) (*domain.StoryDto, error) {
+	story, err := s.repo.FindById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return story, nil
 }
 
-func (s DefaultStoryService) DeleteById(id primitive.ObjectID) error {
-	err := s.repo.DeleteById(id)
+// DeleteById records the delete as a moderation action so it can be appealed
+func (s DefaultStoryService) DeleteById(ctx context.Context, id primitive.ObjectID, actor string) (*domain.CascadeResult, error) {
+	action, err := s.actions.Snapshot(ctx, domain.ActionDelete, "story", id, actor)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return s.repo.DeleteById(ctx, id, action)
 }
 
-func NewStoryService(repository repo.StoryRepo) DefaultStoryService {
-	return DefaultStoryService{repository}
+func NewStoryService(repository repo.StoryRepo, actions repo.ModerationActionRepo) DefaultStoryService {
+	return DefaultStoryService{repository, actions}
 }
@@ -4,37 +4,99 @@ import (
 	"context"
 	"example.com/app/domain"
 	"example.com/app/repo"
+	"example.com/app/tracing"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserService interface {
-	GetAllUsers(string, context.Context) (*domain.UserResponse, error)
-	DeleteByID(primitive.ObjectID) error
+	GetAllUsers(context.Context, string) (*domain.UserResponse, error)
+	ListUsers(context.Context, string, int) (*domain.UserPage, error)
+	DeleteByID(context.Context, primitive.ObjectID) error
+	LockByID(context.Context, primitive.ObjectID, string) error
+	FindByUsername(context.Context, string) (*domain.UserDto, error)
+	PurgeByID(context.Context, primitive.ObjectID, string, string) (*domain.PurgeResult, error)
 }
 
 // DefaultUserService the service has a dependency of the repo
 type DefaultUserService struct {
-	repo repo.UserRepo
+	repo    repo.UserRepo
+	actions repo.ModerationActionRepo
 }
 
-func (s DefaultUserService) GetAllUsers(page string, ctx context.Context) (*domain.UserResponse, error) {
-	//childSpan := opentracing.StartSpan("child", opentracing.ChildOf(span.Context()))
-	//defer childSpan.Finish()
-	u, err := s.repo.FindAll(page, ctx)
+func (s DefaultUserService) GetAllUsers(ctx context.Context, page string) (*domain.UserResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAllUsers", tracing.KindInternal, "page", page)
+	defer span.End()
+
+	u, err := s.repo.FindAll(ctx, page)
 	if err != nil {
 		return nil, err
 	}
 	return  u, nil
 }
 
-func (s DefaultUserService) DeleteByID(id primitive.ObjectID) error {
-	err := s.repo.DeleteByID(id)
+// ListUsers returns the page of at most limit users after cursor, newest first
+func (s DefaultUserService) ListUsers(ctx context.Context, cursor string, limit int) (*domain.UserPage, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.repo.FindAfter(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.UserPage{Items: users}
+	if len(users) > limit {
+		page.Items = users[:limit]
+		page.NextCursor = encodeCursor(page.Items[limit-1].Id)
+	}
+	return page, nil
+}
+
+func (s DefaultUserService) DeleteByID(ctx context.Context, id primitive.ObjectID) error {
+	err := s.repo.DeleteByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func NewUserService(repository repo.UserRepo) DefaultUserService {
-	return DefaultUserService{repository}
+// LockByID locks the account and records the lock as a moderation action so it can be appealed
+func (s DefaultUserService) LockByID(ctx context.Context, id primitive.ObjectID, actor string) error {
+	action, err := s.actions.Snapshot(ctx, domain.ActionLock, "user", id, actor)
+	if err != nil {
+		return err
+	}
+	err = s.repo.LockByID(ctx, id, true)
+	if err != nil {
+		return err
+	}
+	return s.actions.Create(ctx, action)
+}
+
+func (s DefaultUserService) FindByUsername(ctx context.Context, username string) (*domain.UserDto, error) {
+	u, err := s.repo.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// PurgeByID removes the user and their content and records it as a moderation action. A purge that deletes the
+// content can be appealed and undone, anonymised content can't be given back its author.
+func (s DefaultUserService) PurgeByID(ctx context.Context, id primitive.ObjectID, mode string, actor string) (*domain.PurgeResult, error) {
+	kind := domain.ActionDelete
+	if mode == domain.PurgeAnonymise {
+		kind = domain.ActionAnonymise
+	}
+	action, err := s.actions.Snapshot(ctx, kind, "user", id, actor)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.PurgeByID(ctx, id, mode, action)
+}
+
+func NewUserService(repository repo.UserRepo, actions repo.ModerationActionRepo) DefaultUserService {
+	return DefaultUserService{repository, actions}
 }
\ No newline at end of file
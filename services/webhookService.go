@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"example.com/app/apperrors"
+	"example.com/app/config"
+	"example.com/app/domain"
+	"example.com/app/logger"
+	"example.com/app/metrics"
+	"example.com/app/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"io/ioutil"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// deliveryWorkers is how many deliveries an instance sends at once, so one slow endpoint doesn't hold up the rest
+	deliveryWorkers = 8
+	// the wait before a retry starts at retryBase and doubles with every attempt up to retryMax
+	retryBase = 30 * time.Second
+	retryMax  = 6 * time.Hour
+	// responseLog is how much of a response body the delivery log keeps
+	responseLog = 1024
+)
+
+type WebhookService interface {
+	Create(context.Context, *domain.Webhook) error
+	FindAll(context.Context, string) (*[]domain.Webhook, error)
+	FindById(context.Context, primitive.ObjectID) (*domain.Webhook, error)
+	DeleteById(context.Context, primitive.ObjectID) error
+	FindDeliveries(context.Context, primitive.ObjectID, string) (*[]domain.WebhookDelivery, error)
+	Redeliver(context.Context, primitive.ObjectID) (*domain.WebhookDelivery, error)
+	RunDeliveries(context.Context, time.Duration)
+}
+
+type DefaultWebhookService struct {
+	repo   repo.WebhookRepo
+	client *http.Client
+}
+
+// Create registers a webhook, a secret is generated when it's left out and returned this once
+func (w DefaultWebhookService) Create(ctx context.Context, webhook *domain.Webhook) error {
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return apperrors.Internal(err)
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	return w.repo.Create(ctx, webhook)
+}
+
+func (w DefaultWebhookService) FindAll(ctx context.Context, page string) (*[]domain.Webhook, error) {
+	return w.repo.FindAll(ctx, page)
+}
+
+// FindById returns the webhook without its secret
+func (w DefaultWebhookService) FindById(ctx context.Context, id primitive.ObjectID) (*domain.Webhook, error) {
+	webhook, err := w.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (w DefaultWebhookService) DeleteById(ctx context.Context, id primitive.ObjectID) error {
+	return w.repo.DeleteById(ctx, id)
+}
+
+func (w DefaultWebhookService) FindDeliveries(ctx context.Context, id primitive.ObjectID, page string) (*[]domain.WebhookDelivery, error) {
+	if _, err := w.repo.FindById(ctx, id); err != nil {
+		return nil, err
+	}
+	return w.repo.FindDeliveries(ctx, id, page)
+}
+
+// Redeliver sends a delivery again whatever happened to it before, the worker picks it up on its next round
+func (w DefaultWebhookService) Redeliver(ctx context.Context, id primitive.ObjectID) (*domain.WebhookDelivery, error) {
+	return w.repo.Redeliver(ctx, id)
+}
+
+// RunDeliveries sends due deliveries, checking for new ones every interval until ctx is done
+func (w DefaultWebhookService) RunDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	workers := make(chan struct{}, deliveryWorkers)
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	// a claimed delivery isn't tried again until the attempt has had time to time out
+	lease := w.client.Timeout + 30*time.Second
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			workers <- struct{}{}
+
+			delivery, err := w.repo.ClaimDueDelivery(ctx, time.Now(), lease)
+			if err != nil || delivery == nil {
+				<-workers
+				if err != nil {
+					logger.FromContext(ctx).Error("error claiming webhook delivery", "error", err)
+				}
+				break
+			}
+
+			wg.Add(1)
+			go func() {
+				defer func() { <-workers; wg.Done() }()
+				w.deliver(ctx, delivery)
+			}()
+		}
+	}
+}
+
+// deliver makes one attempt and records it. A 2xx response is a success, anything else is retried with
+// exponential backoff until WEBHOOK_MAX_ATTEMPTS is used up.
+func (w DefaultWebhookService) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	log := logger.FromContext(ctx)
+	attempt := domain.DeliveryAttempt{At: time.Now()}
+
+	webhook, err := w.repo.FindById(ctx, delivery.WebhookId)
+	if err == nil {
+		attempt.StatusCode, attempt.Response, err = w.send(ctx, webhook, delivery)
+	}
+	attempt.DurationMs = time.Since(attempt.At).Milliseconds()
+
+	status, next, result := domain.DeliverySucceeded, time.Time{}, "succeeded"
+	switch {
+	case err == nil && attempt.StatusCode >= 200 && attempt.StatusCode < 300:
+	// a webhook deleted while its delivery was claimed has nowhere to go
+	case len(delivery.Attempts)+1 >= config.Get().WebhookMaxAttempts || apperrors.Is(err, apperrors.KindNotFound):
+		status, result = domain.DeliveryFailed, "failed"
+	default:
+		status, next, result = domain.DeliveryPending, time.Now().Add(backoff(len(delivery.Attempts)+1)), "retrying"
+	}
+
+	if err != nil {
+		attempt.Error = err.Error()
+	} else if status != domain.DeliverySucceeded {
+		attempt.Error = "endpoint answered " + strconv.Itoa(attempt.StatusCode)
+	}
+
+	metrics.WebhookAttempts.Inc(result)
+
+	if status != domain.DeliverySucceeded {
+		log.Warn("webhook delivery attempt failed", "delivery_id", delivery.Id.Hex(), "webhook_id", delivery.WebhookId.Hex(),
+			"attempt", len(delivery.Attempts)+1, "status", status, "error", attempt.Error)
+	}
+
+	if err = w.repo.RecordAttempt(ctx, delivery.Id, attempt, status, next); err != nil {
+		log.Error("error recording webhook delivery attempt", "delivery_id", delivery.Id.Hex(), "error", err)
+	}
+}
+
+// send posts the event signed with the webhook's secret. X-Webhook-Signature is sha256= and the HMAC-SHA256 of
+// the timestamp, a dot and the body, so a receiver can reject old or replayed deliveries. X-Webhook-Delivery
+// stays the same across retries and redeliveries.
+func (w DefaultWebhookService) send(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, string, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature, err := domain.Sign([]byte(webhook.Secret), append([]byte(timestamp+"."), body...))
+	if err != nil {
+		return 0, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "control-service-webhooks")
+	req.Header.Set("X-Webhook-Id", webhook.Id.Hex())
+	req.Header.Set("X-Webhook-Delivery", delivery.Id.Hex())
+	req.Header.Set("X-Webhook-Event", delivery.Event.Type)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+string(signature))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	response, _ := ioutil.ReadAll(io.LimitReader(res.Body, responseLog))
+	// drain the rest so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	return res.StatusCode, string(response), nil
+}
+
+// backoff is the wait after the nth failed attempt, with a fifth either way so retries of many deliveries to the
+// same endpoint spread out
+func backoff(n int) time.Duration {
+	wait := math.Min(float64(retryBase)*math.Pow(2, float64(n-1)), float64(retryMax))
+	return time.Duration(wait * (0.8 + 0.4*mathrand.Float64()))
+}
+
+func NewWebhookService(repository repo.WebhookRepo) DefaultWebhookService {
+	return DefaultWebhookService{
+		repo:   repository,
+		client: &http.Client{Timeout: time.Duration(config.Get().WebhookTimeout) * time.Second},
+	}
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("SECRET", "test-secret")
+	os.Setenv("EXPIRATION", "5")
+	os.Setenv("DB_NAME", "mongodb://")
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", ":27017")
+	os.Setenv("WEBHOOK_MAX_ATTEMPTS", "3")
+	os.Exit(m.Run())
+}
+
+// memoryWebhookRepo keeps one webhook and its deliveries, claiming them like the Mongo repo does
+type memoryWebhookRepo struct {
+	mu         sync.Mutex
+	webhook    domain.Webhook
+	deliveries map[primitive.ObjectID]*domain.WebhookDelivery
+	leases     []time.Duration
+}
+
+func newMemoryWebhookRepo(url string) *memoryWebhookRepo {
+	return &memoryWebhookRepo{
+		webhook:    domain.Webhook{Id: primitive.NewObjectID(), URL: url, Secret: "webhook-secret", Active: true},
+		deliveries: map[primitive.ObjectID]*domain.WebhookDelivery{},
+	}
+}
+
+func (m *memoryWebhookRepo) add(event domain.FeedEvent) *domain.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery := &domain.WebhookDelivery{Id: primitive.NewObjectID(), WebhookId: m.webhook.Id, Event: event,
+		Status: domain.DeliveryPending, Attempts: []domain.DeliveryAttempt{}, NextAttemptAt: time.Now()}
+	m.deliveries[delivery.Id] = delivery
+	return delivery
+}
+
+func (m *memoryWebhookRepo) get(id primitive.ObjectID) domain.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.deliveries[id]
+}
+
+func (m *memoryWebhookRepo) Create(context.Context, *domain.Webhook) error { return nil }
+
+func (m *memoryWebhookRepo) FindAll(context.Context, string) (*[]domain.Webhook, error) {
+	return &[]domain.Webhook{m.webhook}, nil
+}
+
+func (m *memoryWebhookRepo) FindById(_ context.Context, id primitive.ObjectID) (*domain.Webhook, error) {
+	if id != m.webhook.Id {
+		return nil, apperrors.NotFound("webhook_not_found", "cannot find webhook")
+	}
+	webhook := m.webhook
+	return &webhook, nil
+}
+
+func (m *memoryWebhookRepo) DeleteById(context.Context, primitive.ObjectID) error { return nil }
+
+func (m *memoryWebhookRepo) FindDeliveries(context.Context, primitive.ObjectID, string) (*[]domain.WebhookDelivery, error) {
+	return &[]domain.WebhookDelivery{}, nil
+}
+
+func (m *memoryWebhookRepo) Enqueue(context.Context, domain.FeedEvent) error { return nil }
+
+func (m *memoryWebhookRepo) ClaimDueDelivery(_ context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leases = append(m.leases, lease)
+	for _, d := range m.deliveries {
+		if d.Status == domain.DeliveryPending && !d.NextAttemptAt.After(now) {
+			d.NextAttemptAt = now.Add(lease)
+			claimed := *d
+			claimed.Attempts = append([]domain.DeliveryAttempt{}, d.Attempts...)
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryWebhookRepo) RecordAttempt(_ context.Context, id primitive.ObjectID, attempt domain.DeliveryAttempt, status string, next time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d := m.deliveries[id]
+	d.Attempts = append(d.Attempts, attempt)
+	d.Status = status
+	d.NextAttemptAt = next
+	return nil
+}
+
+func (m *memoryWebhookRepo) Redeliver(context.Context, primitive.ObjectID) (*domain.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func testEvent() domain.FeedEvent {
+	return domain.FeedEvent{Id: primitive.NewObjectID(), Type: "story.deleted", ResourceType: "story",
+		ResourceId: primitive.NewObjectID(), CreatedAt: time.Now()}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := newMemoryWebhookRepo(receiver.URL)
+	delivery := repo.add(testEvent())
+	service := DefaultWebhookService{repo: repo, client: receiver.Client()}
+
+	service.deliver(context.Background(), delivery)
+
+	if got == nil {
+		t.Fatal("the receiver got no request")
+	}
+
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write([]byte(got.Header.Get("X-Webhook-Timestamp") + "."))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if signature := got.Header.Get("X-Webhook-Signature"); signature != want {
+		t.Fatalf("X-Webhook-Signature = %q, want %q", signature, want)
+	}
+
+	timestamp, err := strconv.ParseInt(got.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Fatalf("X-Webhook-Timestamp = %q, want the time it was sent", got.Header.Get("X-Webhook-Timestamp"))
+	}
+
+	headers := map[string]string{
+		"X-Webhook-Id":       repo.webhook.Id.Hex(),
+		"X-Webhook-Delivery": delivery.Id.Hex(),
+		"X-Webhook-Event":    "story.deleted",
+		"Content-Type":       "application/json",
+	}
+	for name, want := range headers {
+		if got.Header.Get(name) != want {
+			t.Errorf("%s = %q, want %q", name, got.Header.Get(name), want)
+		}
+	}
+
+	var event domain.FeedEvent
+	if err = json.Unmarshal(body, &event); err != nil || event.Id != delivery.Event.Id {
+		t.Fatalf("body = %s, want the event", body)
+	}
+
+	if d := repo.get(delivery.Id); d.Status != domain.DeliverySucceeded || len(d.Attempts) != 1 || d.Attempts[0].StatusCode != 204 {
+		t.Fatalf("delivery = %s with %d attempts, want succeeded after one", d.Status, len(d.Attempts))
+	}
+}
+
+func TestWebhookRetryAndGiveUp(t *testing.T) {
+	requests := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	repo := newMemoryWebhookRepo(receiver.URL)
+	delivery := repo.add(testEvent())
+	service := DefaultWebhookService{repo: repo, client: receiver.Client()}
+
+	// WEBHOOK_MAX_ATTEMPTS is 3, the first two failures are retried after 30s and 60s give or take a fifth
+	for n, wait := range []time.Duration{retryBase, 2 * retryBase} {
+		before := time.Now()
+		claimed := repo.get(delivery.Id)
+		service.deliver(context.Background(), &claimed)
+
+		d := repo.get(delivery.Id)
+		if d.Status != domain.DeliveryPending || len(d.Attempts) != n+1 {
+			t.Fatalf("after attempt %d delivery = %s with %d attempts, want pending", n+1, d.Status, len(d.Attempts))
+		}
+		if d.Attempts[n].StatusCode != 503 || d.Attempts[n].Error != "endpoint answered 503" {
+			t.Fatalf("attempt %d = %+v, want the 503 recorded", n+1, d.Attempts[n])
+		}
+
+		next := d.NextAttemptAt.Sub(before)
+		if next < wait*8/10 || next > wait*12/10+time.Second {
+			t.Fatalf("after attempt %d next attempt in %s, want about %s", n+1, next, wait)
+		}
+	}
+
+	claimed := repo.get(delivery.Id)
+	service.deliver(context.Background(), &claimed)
+
+	d := repo.get(delivery.Id)
+	if d.Status != domain.DeliveryFailed || len(d.Attempts) != 3 || !d.NextAttemptAt.IsZero() {
+		t.Fatalf("after the last attempt delivery = %s with %d attempts, want failed", d.Status, len(d.Attempts))
+	}
+	if requests != 3 {
+		t.Fatalf("receiver got %d requests, want 3", requests)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for n, want := range map[int]time.Duration{1: retryBase, 2: 2 * retryBase, 4: 8 * retryBase, 20: retryMax} {
+		for i := 0; i < 100; i++ {
+			if wait := backoff(n); wait < want*8/10 || wait > want*12/10 {
+				t.Fatalf("backoff(%d) = %s, want within a fifth of %s", n, wait, want)
+			}
+		}
+	}
+}
+
+func TestWebhookClaimLease(t *testing.T) {
+	requests := make(chan struct{}, 10)
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- struct{}{}:
+		default:
+		}
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	var releaseOnce sync.Once
+	defer releaseOnce.Do(func() { close(release) })
+
+	repo := newMemoryWebhookRepo(receiver.URL)
+	delivery := repo.add(testEvent())
+	client := receiver.Client()
+	client.Timeout = 5 * time.Second
+	service := DefaultWebhookService{repo: repo, client: client}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		service.RunDeliveries(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delivery was never sent")
+	}
+
+	// the claim holds the delivery while the attempt is in flight, the worker keeps polling meanwhile
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-requests:
+		t.Fatal("the delivery was sent again while claimed")
+	default:
+	}
+
+	releaseOnce.Do(func() { close(release) })
+	cancel()
+	<-done
+
+	repo.mu.Lock()
+	lease := repo.leases[0]
+	repo.mu.Unlock()
+	if lease != client.Timeout+30*time.Second {
+		t.Fatalf("lease = %s, want the client timeout and 30s", lease)
+	}
+
+	if d := repo.get(delivery.Id); d.Status != domain.DeliveryPending || len(d.Attempts) != 1 || time.Until(d.NextAttemptAt) < retryBase/2 {
+		t.Fatalf("delivery = %s with %d attempts due %s, want pending with a retry after backoff", d.Status,
+			len(d.Attempts), d.NextAttemptAt)
+	}
+}
@@ -0,0 +1,265 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// exporter sends a batch of finished spans somewhere
+type exporter interface {
+	export(ctx context.Context, spans []*Span) error
+}
+
+const (
+	batchSize     = 512
+	queueSize     = 2048
+	flushInterval = 5 * time.Second
+)
+
+var (
+	mu          sync.Mutex
+	queue       chan *Span
+	done        chan struct{}
+	sampleRatio = 1.0
+)
+
+// Init starts sending spans to the exporter named by kind: "stdout", "otlp" or "none".
+// endpoint is the OTLP/HTTP traces url, ratio is the share of new traces that are sampled.
+func Init(kind string, endpoint string, serviceName string, ratio float64) error {
+	var e exporter
+
+	switch kind {
+	case "", "none":
+		return nil
+	case "stdout":
+		e = &stdoutExporter{out: os.Stdout, serviceName: serviceName}
+	case "otlp":
+		e = &otlpExporter{endpoint: endpoint, serviceName: serviceName, client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", kind)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	sampleRatio = ratio
+	queue = make(chan *Span, queueSize)
+	done = make(chan struct{})
+
+	go run(e, queue, done)
+
+	return nil
+}
+
+// Shutdown sends the spans still queued, waiting up to the deadline of ctx
+func Shutdown(ctx context.Context) {
+	mu.Lock()
+	q, d := queue, done
+	queue = nil
+	mu.Unlock()
+
+	if q == nil {
+		return
+	}
+
+	close(q)
+
+	select {
+	case <-d:
+	case <-ctx.Done():
+	}
+}
+
+func sampled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return queue != nil && rand.Float64() < sampleRatio
+}
+
+// export queues a span, it's dropped when the queue is full rather than slowing the request down
+func export(span *Span) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if queue == nil {
+		return
+	}
+
+	select {
+	case queue <- span:
+	default:
+	}
+}
+
+func run(e exporter, queue chan *Span, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := e.export(ctx, batch); err != nil {
+			fmt.Fprintf(os.Stderr, "tracing: export failed: %v\n", err)
+		}
+		cancel()
+		batch = make([]*Span, 0, batchSize)
+	}
+
+	for {
+		select {
+		case span, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+type stdoutExporter struct {
+	out         io.Writer
+	serviceName string
+}
+
+// export writes one JSON object per span
+func (e *stdoutExporter) export(_ context.Context, spans []*Span) error {
+	enc := json.NewEncoder(e.out)
+	for _, s := range spans {
+		s.mu.Lock()
+		entry := map[string]interface{}{
+			"service":    e.serviceName,
+			"name":       s.name,
+			"kind":       s.kind,
+			"traceId":    s.context.TraceID.String(),
+			"spanId":     s.context.SpanID.String(),
+			"start":      s.start.UTC().Format(time.RFC3339Nano),
+			"durationMs": float64(s.end.Sub(s.start).Microseconds()) / 1000,
+			"attributes": s.attributes,
+		}
+		if s.parent != (SpanID{}) {
+			entry["parentSpanId"] = s.parent.String()
+		}
+		if s.err != "" {
+			entry["error"] = s.err
+		}
+		s.mu.Unlock()
+
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type otlpExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// export posts the spans in the OTLP/HTTP JSON encoding
+func (e *otlpExporter) export(ctx context.Context, spans []*Span) error {
+	otlpSpans := make([]map[string]interface{}, len(spans))
+
+	for i, s := range spans {
+		s.mu.Lock()
+		span := map[string]interface{}{
+			"traceId":           s.context.TraceID.String(),
+			"spanId":            s.context.SpanID.String(),
+			"name":              s.name,
+			"kind":              int(s.kind),
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+		}
+		if s.parent != (SpanID{}) {
+			span["parentSpanId"] = s.parent.String()
+		}
+		if s.err != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.err}
+		}
+		s.mu.Unlock()
+		otlpSpans[i] = span
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": e.serviceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "example.com/app/tracing"},
+				"spans": otlpSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("collector answered %s", res.Status)
+	}
+	return nil
+}
+
+func otlpAttributes(attributes map[string]interface{}) []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(attributes))
+
+	for k, v := range attributes {
+		var value map[string]interface{}
+
+		switch v := v.(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int32:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+
+		list = append(list, map[string]interface{}{"key": k, "value": value})
+	}
+
+	return list
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/event"
+	"sync"
+)
+
+// MongoMonitor starts a client span for every command, as a child of the span in the operation's context
+func MongoMonitor() *event.CommandMonitor {
+	var started sync.Map
+
+	finished := func(requestId int64, err string) {
+		v, ok := started.LoadAndDelete(requestId)
+		if !ok {
+			return
+		}
+		span := v.(*Span)
+		if err != "" {
+			span.mu.Lock()
+			span.err = err
+			span.mu.Unlock()
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			// commands outside a traced operation, like the driver's own heartbeats, aren't worth a trace
+			if SpanFromContext(ctx) == nil {
+				return
+			}
+
+			collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+
+			_, span := Start(ctx, "mongo."+e.CommandName, KindClient,
+				"db.system", "mongodb",
+				"db.name", e.DatabaseName,
+				"db.operation", e.CommandName,
+				"db.mongodb.collection", collection)
+
+			started.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.RequestID, "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.RequestID, e.Failure)
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+)
+
+// TraceparentHeader is the W3C trace context header, used on HTTP requests and kafka messages
+const TraceparentHeader = "traceparent"
+
+// Inject writes the trace context of the span in ctx with set
+func Inject(ctx context.Context, set func(key string, value string)) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	flags := "00"
+	if span.context.Sampled {
+		flags = "01"
+	}
+
+	set(TraceparentHeader, "00-"+span.context.TraceID.String()+"-"+span.context.SpanID.String()+"-"+flags)
+}
+
+// Extract reads a trace context with get, the next span started from the returned context continues that trace
+func Extract(ctx context.Context, get func(key string) string) context.Context {
+	sc, ok := parseTraceparent(get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// parseTraceparent reads version 00 of the header: 00-<trace id>-<parent id>-<flags>
+func parseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	return sc, sc.IsValid()
+}
@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"example.com/app/apperrors"
+	"example.com/app/domain"
+)
+
+// Message checks a message from the user topic before it's processed. Only the payload the message type says
+// is in use is checked, a deleted user only needs its id.
+func Message(m domain.Envelope) error {
+	switch m.Type {
+	case domain.MessageUserCreated, domain.MessageUserUpdated:
+		if m.User == nil {
+			return missing("user")
+		}
+		return Prefixed("user", *m.User)
+	case domain.MessageUserDeleted:
+		if m.User == nil || m.User.Id.IsZero() {
+			return missing("user.id")
+		}
+		return nil
+	case domain.MessageFlagCreated:
+		if m.Flag == nil {
+			return missing("flag")
+		}
+		return Prefixed("flag", *m.Flag)
+	case domain.MessageAppealCreated:
+		if m.Appeal == nil {
+			return missing("appeal")
+		}
+		return Prefixed("appeal", *m.Appeal)
+	}
+
+	return apperrors.Validation("unknown_message", "cannot process this message").
+		WithField("type", "must be user.created, user.updated, user.deleted, flag.created or appeal.created")
+}
+
+func missing(field string) error {
+	return apperrors.Validation("invalid_request", "request failed validation").WithField(field, "is required")
+}
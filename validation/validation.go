@@ -0,0 +1,220 @@
+package validation
+
+import (
+	"example.com/app/apperrors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Struct checks v against the validate tags on its fields and returns one validation error listing every field
+// that failed, keyed by its json name. Nested structs, pointers to them and slices of them are checked as well,
+// a field tagged validate:"-" is skipped.
+//
+// The rules are comma separated:
+//
+//	required      the field can't be its zero value
+//	min=n, max=n  the length of a string or slice, or the value of a number
+//	email         the string is an email address
+//	url           the string is an absolute http or https url
+//	oneof=a b c   the string is one of the space separated values, ignoring case
+//	unique        the slice has no duplicates, ignoring case
+//
+// Rules other than required don't apply to an empty string or slice, so optional fields only need to be valid
+// when they're set. Numbers are always checked against min and max.
+func Struct(v interface{}) error {
+	return Prefixed("", v)
+}
+
+// Prefixed is Struct with the field names prefixed, for a payload nested in a message
+func Prefixed(prefix string, v interface{}) error {
+	fields := map[string]string{}
+	check(prefix, reflect.ValueOf(v), fields)
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	err := apperrors.Validation("invalid_request", "request failed validation")
+	for field, problem := range fields {
+		err.WithField(field, problem)
+	}
+	return err
+}
+
+func check(path string, v reflect.Value, fields map[string]string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			rules := field.Tag.Get("validate")
+
+			if field.PkgPath != "" || rules == "-" {
+				continue
+			}
+
+			name := join(path, fieldName(field))
+
+			if problem := apply(rules, v.Field(i)); problem != "" {
+				fields[name] = problem
+				continue
+			}
+
+			if nested(field.Type) {
+				check(name, v.Field(i), fields)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if !nested(v.Type().Elem()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			check(fmt.Sprintf("%s[%d]", path, i), v.Index(i), fields)
+		}
+	}
+}
+
+// nested reports whether values of t hold fields of their own to check
+func nested(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	// an ObjectID is an array and time.Time is a struct, neither carries rules
+	return t.Kind() == reflect.Struct && t.PkgPath() == "example.com/app/domain"
+}
+
+// apply runs the rules against one field and returns the first problem
+func apply(rules string, v reflect.Value) string {
+	if rules == "" {
+		return ""
+	}
+
+	zero := v.IsZero()
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		if name == "required" {
+			if zero {
+				return "is required"
+			}
+			continue
+		}
+
+		if zero && !number(v) {
+			continue
+		}
+
+		var problem string
+		switch name {
+		case "min":
+			problem = bound(v, arg, func(n, limit float64) bool { return n >= limit }, "at least")
+		case "max":
+			problem = bound(v, arg, func(n, limit float64) bool { return n <= limit }, "at most")
+		case "email":
+			if a, err := mail.ParseAddress(v.String()); err != nil || a.Address != v.String() {
+				problem = "must be an email address"
+			}
+		case "url":
+			if u, err := url.Parse(v.String()); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				problem = "must be an http or https url"
+			}
+		case "oneof":
+			problem = "must be one of " + strings.ReplaceAll(arg, " ", ", ")
+			for _, allowed := range strings.Fields(arg) {
+				if strings.EqualFold(v.String(), allowed) {
+					problem = ""
+				}
+			}
+		case "unique":
+			seen := map[string]bool{}
+			for i := 0; i < v.Len(); i++ {
+				key := strings.ToLower(fmt.Sprint(v.Index(i).Interface()))
+				if seen[key] {
+					problem = "must not contain duplicates"
+					break
+				}
+				seen[key] = true
+			}
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q", name))
+		}
+
+		if problem != "" {
+			return problem
+		}
+	}
+
+	return ""
+}
+
+// bound compares a string's length in characters, a slice's length or a number against the rule's limit
+func bound(v reflect.Value, arg string, ok func(n, limit float64) bool, word string) string {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: %q is not a number", arg))
+	}
+
+	var n float64
+	unit := ""
+
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	}
+
+	if ok(n, limit) {
+		return ""
+	}
+	return fmt.Sprintf("must be %s %s%s", word, arg, unit)
+}
+
+func number(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// fieldName is the name a client sends the field as
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query", "bson"} {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}